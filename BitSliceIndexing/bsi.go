@@ -1,13 +1,16 @@
 package roaring
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"math/bits"
 	"runtime"
 	"sync"
 	"sync/atomic"
 
 	"github.com/RoaringBitmap/roaring/v2"
+	"github.com/RoaringBitmap/roaring/v2/internal"
 )
 
 const (
@@ -654,6 +657,7 @@ func (b *BSI) ParOr(parallelism int, bsis ...*BSI) {
 
 // UnmarshalBinary de-serialize a BSI.  The value at bitData[0] is the EBM.  Other indices are in least to most
 // significance order starting at bitData[1] (bit position 0).
+// Errors are *roaring.DeserializationError whose Container is the index in bitData.
 func (b *BSI) UnmarshalBinary(bitData [][]byte) error {
 
 	for i := 1; i < len(bitData); i++ {
@@ -668,7 +672,7 @@ func (b *BSI) UnmarshalBinary(bitData [][]byte) error {
 			b.bA = append(b.bA, newBm)
 		}
 		if err := b.bA[i-1].UnmarshalBinary(bitData[i]); err != nil {
-			return err
+			return innerError(0, i, fmt.Errorf("reading bit slice index %v: %w", i-1, err))
 		}
 		if b.runOptimized {
 			b.bA[i-1].RunOptimize()
@@ -684,7 +688,7 @@ func (b *BSI) UnmarshalBinary(bitData [][]byte) error {
 		return nil
 	}
 	if err := b.eBM.UnmarshalBinary(bitData[0]); err != nil {
-		return err
+		return innerError(0, 0, fmt.Errorf("reading existence bitmap: %w", err))
 	}
 	if b.runOptimized {
 		b.eBM.RunOptimize()
@@ -712,6 +716,98 @@ func (b *BSI) MarshalBinary() ([][]byte, error) {
 	return data, nil
 }
 
+// ReadFrom reads a serialized version of this BSI from stream, as written by WriteTo.
+// The layout is the same as for roaring64.BSI:
+//   - a 20-byte header: the cookie "BSIS", MinValue and MaxValue, all little endian
+//   - the existence bitmap
+//   - every bit slice, in least to most significance order, until the end of the stream
+//
+// Streams without the header, which start with the existence bitmap, are read as well and
+// leave MinValue and MaxValue unchanged.
+// Errors are *roaring.DeserializationError whose Container is the index of the bitmap
+// (0 for the existence bitmap, i+1 for the bit slice i), or -1 for the header.
+func (b *BSI) ReadFrom(stream io.Reader) (p int64, err error) {
+	header, stream, nh, err := internal.ReadBSIHeader(stream)
+	p += int64(nh)
+	if err == io.EOF {
+		// the stream ended cleanly, before the BSI
+		return
+	}
+	if err != nil {
+		err = innerError(p, -1, fmt.Errorf("reading header: %w", err))
+		return
+	}
+
+	bm, n, err := readBSIContainerFromStream(stream)
+	p += n
+	if err != nil {
+		err = innerError(p-n, 0, fmt.Errorf("reading existence bitmap: %w", err))
+		return
+	}
+	if header != nil {
+		b.MinValue, b.MaxValue = header.MinValue, header.MaxValue
+	}
+	b.eBM = bm
+	b.bA = b.bA[:0]
+	for {
+		bm, n, err = readBSIContainerFromStream(stream)
+		p += n
+		if n == 0 && errors.Is(err, io.EOF) {
+			err = nil
+			break
+		}
+		if err != nil {
+			err = innerError(p-n, len(b.bA)+1, fmt.Errorf("reading bit slice index %v: %w", len(b.bA), err))
+			return
+		}
+		b.bA = append(b.bA, bm)
+	}
+	return
+}
+
+// innerError reports the failure err of the bitmap i of the BSI (-1 for the header),
+// which starts at offset.
+// The error keeps the kind of the bitmap error, with an offset relative to the whole input.
+func innerError(offset int64, i int, err error) error {
+	var inner *roaring.DeserializationError
+	if !errors.As(err, &inner) {
+		kind := roaring.ReadFailure
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			kind = roaring.TruncatedInput
+		}
+		return &roaring.DeserializationError{Kind: kind, Offset: offset, Container: i, Err: err}
+	}
+	return &roaring.DeserializationError{Kind: inner.Kind, Offset: offset + inner.Offset, Container: i, Err: err}
+}
+
+func readBSIContainerFromStream(r io.Reader) (bm *roaring.Bitmap, p int64, err error) {
+	bm = roaring.NewBitmap()
+	p, err = bm.ReadFrom(r)
+	return
+}
+
+// WriteTo writes a serialized version of this BSI to stream, see ReadFrom for the format.
+func (b *BSI) WriteTo(w io.Writer) (n int64, err error) {
+	nh, err := internal.WriteBSIHeader(w, internal.BSIHeader{MinValue: b.MinValue, MaxValue: b.MaxValue})
+	n += int64(nh)
+	if err != nil {
+		return
+	}
+	n1, err := b.eBM.WriteTo(w)
+	n += n1
+	if err != nil {
+		return
+	}
+	for _, bm := range b.bA {
+		n1, err = bm.WriteTo(w)
+		n += n1
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
 // BatchEqual returns a bitmap containing the column IDs where the values are contained within the list of values provided.
 func (b *BSI) BatchEqual(parallelism int, values []int64) *roaring.Bitmap {
 
@@ -772,6 +868,20 @@ func (b *BSI) ClearValues(foundSet *roaring.Bitmap) {
 	wg.Wait()
 }
 
+// Retains only values found in retain. Returns how many values were not retained.
+func (b *BSI) Retain(retain *roaring.Bitmap) (dropped uint64) {
+	preCard := b.eBM.GetCardinality()
+	b.eBM.And(retain)
+	dropped = preCard - b.eBM.GetCardinality()
+	if dropped == 0 {
+		return
+	}
+	for i := range b.bA {
+		b.bA[i].And(retain)
+	}
+	return
+}
+
 // NewBSIRetainSet - Construct a new BSI from a clone of existing BSI, retain only values contained
 // in foundSet
 func (b *BSI) NewBSIRetainSet(foundSet *roaring.Bitmap) *BSI {
@@ -855,3 +965,35 @@ func (b *BSI) Increment(foundSet *roaring.Bitmap) {
 func (b *BSI) IncrementAll() {
 	b.Increment(b.GetExistenceBitmap())
 }
+
+// Equals - Check for semantic equality of two BSIs.
+func (b *BSI) Equals(other *BSI) bool {
+	if !b.eBM.Equals(other.eBM) {
+		return false
+	}
+	for i := 0; i < len(b.bA) || i < len(other.bA); i++ {
+		if i >= len(b.bA) {
+			if !other.bA[i].IsEmpty() {
+				return false
+			}
+		} else if i >= len(other.bA) {
+			if !b.bA[i].IsEmpty() {
+				return false
+			}
+		} else {
+			if !b.bA[i].Equals(other.bA[i]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// GetSizeInBytes - the size in bytes of the data structure
+func (b *BSI) GetSizeInBytes() int {
+	size := b.eBM.GetSizeInBytes()
+	for _, bm := range b.bA {
+		size += bm.GetSizeInBytes()
+	}
+	return int(size)
+}
@@ -0,0 +1,89 @@
+package roaring
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/RoaringBitmap/roaring/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFrozenBSIView(t *testing.T) {
+	bsi := setupRandom()
+	bsi.RunOptimize()
+
+	buf, err := bsi.Freeze()
+	require.NoError(t, err)
+	assert.EqualValues(t, bsi.GetFrozenSizeInBytes(), len(buf))
+	orig := append([]byte(nil), buf...)
+
+	view, err := FrozenBSIView(buf)
+	require.NoError(t, err)
+	assert.True(t, bsi.Equals(view))
+	assert.Equal(t, bsi.MinValue, view.MinValue)
+	assert.Equal(t, bsi.MaxValue, view.MaxValue)
+	assert.Equal(t, bsi.BitCount(), view.BitCount())
+
+	for _, op := range []Operation{LT, LE, EQ, GE, GT} {
+		expected := bsi.CompareValue(0, op, 10, 0, nil)
+		assert.True(t, expected.Equals(view.CompareValue(0, op, 10, 0, nil)), "op %v", op)
+	}
+	assert.True(t, bsi.CompareValue(0, RANGE, -10, 10, nil).Equals(view.CompareValue(0, RANGE, -10, 10, nil)))
+
+	sum, count := bsi.Sum(bsi.GetExistenceBitmap())
+	vsum, vcount := view.Sum(view.GetExistenceBitmap())
+	assert.Equal(t, sum, vsum)
+	assert.Equal(t, count, vcount)
+
+	assert.Equal(t, bsi.MinValue, view.MinMax(0, MIN, view.GetExistenceBitmap()))
+	assert.Equal(t, bsi.MaxValue, view.MinMax(0, MAX, view.GetExistenceBitmap()))
+	assert.Equal(t, orig, buf)
+
+	t.Run("write frozen", func(t *testing.T) {
+		var out bytes.Buffer
+		n, err := bsi.WriteFrozenTo(&out)
+		require.NoError(t, err)
+		assert.Equal(t, len(buf), n)
+		assert.Equal(t, buf, out.Bytes())
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		buf, err := NewDefaultBSI().Freeze()
+		require.NoError(t, err)
+		view, err := FrozenBSIView(buf)
+		require.NoError(t, err)
+		assert.EqualValues(t, 0, view.GetCardinality())
+		assert.Equal(t, 0, view.BitCount())
+	})
+
+	t.Run("buffer too small", func(t *testing.T) {
+		_, err := bsi.FreezeTo(make([]byte, len(buf)-1))
+		assert.ErrorIs(t, err, roaring.ErrFrozenBitmapBufferTooSmall)
+	})
+}
+
+func TestFrozenBSIViewErrors(t *testing.T) {
+	buf, err := setup().Freeze()
+	require.NoError(t, err)
+
+	_, err = FrozenBSIView(buf[:8])
+	assert.ErrorIs(t, err, roaring.ErrFrozenBitmapIncomplete)
+
+	_, err = FrozenBSIView(buf[:len(buf)-1])
+	assert.ErrorIs(t, err, roaring.ErrFrozenBitmapIncomplete)
+
+	_, err = FrozenBSIView(append(append([]byte(nil), buf...), 0))
+	assert.ErrorIs(t, err, roaring.ErrFrozenBitmapUnexpectedData)
+
+	bad := append([]byte(nil), buf...)
+	bad[0]++
+	_, err = FrozenBSIView(bad)
+	assert.ErrorIs(t, err, roaring.ErrFrozenBitmapInvalidCookie)
+
+	bad = append([]byte(nil), buf...)
+	binary.LittleEndian.PutUint32(bad[4:], 1<<31)
+	_, err = FrozenBSIView(bad)
+	assert.ErrorIs(t, err, roaring.ErrFrozenBitmapIncomplete)
+}
@@ -1,7 +1,11 @@
 package roaring
 
 import (
+	"bytes"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"math/rand"
 	"os"
@@ -9,6 +13,7 @@ import (
 	"time"
 
 	"github.com/RoaringBitmap/roaring/v2"
+	"github.com/RoaringBitmap/roaring/v2/roaring64"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -455,6 +460,214 @@ func TestMinMaxWithRandom(t *testing.T) {
 	assert.Equal(t, bsi.MaxValue, bsi.MinMax(0, MAX, bsi.GetExistenceBitmap()))
 }
 
+func TestBSIWriteToReadFrom(t *testing.T) {
+	bsi := setupRandom()
+
+	var buf bytes.Buffer
+	n, err := bsi.WriteTo(&buf)
+	require.NoError(t, err)
+	assert.EqualValues(t, buf.Len(), n)
+	data := buf.Bytes()
+
+	bsi2 := NewDefaultBSI()
+	p, err := bsi2.ReadFrom(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, n, p)
+	assert.Equal(t, bsi.BitCount(), bsi2.BitCount())
+	assert.Equal(t, bsi.MinValue, bsi2.MinValue)
+	assert.Equal(t, bsi.MaxValue, bsi2.MaxValue)
+	assert.True(t, bsi.Equals(bsi2))
+	for _, col := range bsi.GetExistenceBitmap().ToArray() {
+		v, _ := bsi.GetValue(uint64(col))
+		v2, ok := bsi2.GetValue(uint64(col))
+		assert.True(t, ok)
+		assert.Equal(t, v, v2)
+	}
+
+	t.Run("empty", func(t *testing.T) {
+		var buf bytes.Buffer
+		_, err := NewDefaultBSI().WriteTo(&buf)
+		require.NoError(t, err)
+
+		bsi := NewBSI(10, 1)
+		_, err = bsi.ReadFrom(&buf)
+		require.NoError(t, err)
+		assert.Equal(t, 0, bsi.BitCount())
+		assert.EqualValues(t, 0, bsi.GetCardinality())
+		assert.EqualValues(t, 0, bsi.MinValue)
+		assert.EqualValues(t, 0, bsi.MaxValue)
+	})
+
+	t.Run("set after reload", func(t *testing.T) {
+		reload := func(bsi *BSI) *BSI {
+			var buf bytes.Buffer
+			_, err := bsi.WriteTo(&buf)
+			require.NoError(t, err)
+			reloaded := NewDefaultBSI()
+			_, err = reloaded.ReadFrom(&buf)
+			require.NoError(t, err)
+			assert.Equal(t, bsi.MinValue, reloaded.MinValue)
+			assert.Equal(t, bsi.MaxValue, reloaded.MaxValue)
+			return reloaded
+		}
+
+		// an auto-sized BSI keeps growing its bit slices
+		bsi := NewDefaultBSI()
+		bsi.SetValue(1, 5)
+		bsi = reload(bsi)
+		bsi.SetValue(2, 100)
+		v, ok := bsi.GetValue(2)
+		assert.True(t, ok)
+		assert.EqualValues(t, 100, v)
+
+		// a fixed-width BSI keeps its declared range
+		bsi = NewBSI(1000, -5)
+		bsi.SetValue(1, 3)
+		bsi = reload(bsi)
+		assert.EqualValues(t, 1000, bsi.MaxValue)
+		assert.EqualValues(t, -5, bsi.MinValue)
+		bsi.SetValue(2, 900)
+		v, ok = bsi.GetValue(2)
+		assert.True(t, ok)
+		assert.EqualValues(t, 900, v)
+		v, _ = bsi.GetValue(1)
+		assert.EqualValues(t, 3, v)
+	})
+
+	t.Run("documented layout", func(t *testing.T) {
+		// header, existence bitmap and bit slices, as documented by both BSI implementations
+		var buf bytes.Buffer
+		buf.WriteString("BSIS")
+		require.NoError(t, binary.Write(&buf, binary.LittleEndian, []int64{-5, 1000}))
+		_, err := roaring.BitmapOf(1, 2, 7).WriteTo(&buf)
+		require.NoError(t, err)
+		for _, slice := range []*roaring.Bitmap{roaring.BitmapOf(1, 7), roaring.BitmapOf(2, 7)} {
+			_, err = slice.WriteTo(&buf)
+			require.NoError(t, err)
+		}
+		layout := buf.Bytes()
+
+		bsi := NewDefaultBSI()
+		_, err = bsi.ReadFrom(bytes.NewReader(layout))
+		require.NoError(t, err)
+		assert.EqualValues(t, -5, bsi.MinValue)
+		assert.EqualValues(t, 1000, bsi.MaxValue)
+		for col, expected := range map[uint64]int64{1: 1, 2: 2, 7: 3} {
+			v, ok := bsi.GetValue(col)
+			assert.True(t, ok)
+			assert.Equal(t, expected, v)
+		}
+
+		var written bytes.Buffer
+		_, err = bsi.WriteTo(&written)
+		require.NoError(t, err)
+		assert.Equal(t, layout, written.Bytes())
+
+		// roaring64.BSI writes the same header
+		var written64 bytes.Buffer
+		_, err = roaring64.NewBSI(1000, -5).WriteTo(&written64)
+		require.NoError(t, err)
+		assert.Equal(t, layout[:20], written64.Bytes()[:20])
+
+		// streams without the header keep MinValue and MaxValue
+		bsi = NewBSI(10, 1)
+		_, err = bsi.ReadFrom(bytes.NewReader(layout[20:]))
+		require.NoError(t, err)
+		assert.EqualValues(t, 1, bsi.MinValue)
+		assert.EqualValues(t, 10, bsi.MaxValue)
+		v, ok := bsi.GetValue(7)
+		assert.True(t, ok)
+		assert.EqualValues(t, 3, v)
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		_, err := NewDefaultBSI().ReadFrom(bytes.NewReader(data[:len(data)-1]))
+		var de *roaring.DeserializationError
+		require.True(t, errors.As(err, &de))
+		assert.Equal(t, roaring.TruncatedInput, de.Kind)
+		assert.Equal(t, bsi.BitCount(), de.Container)
+		assert.Less(t, de.Offset, int64(len(data)))
+
+		_, err = NewDefaultBSI().ReadFrom(bytes.NewReader(nil))
+		assert.Equal(t, io.EOF, err)
+
+		_, err = NewDefaultBSI().ReadFrom(bytes.NewReader(data[:20]))
+		require.True(t, errors.As(err, &de))
+		assert.Equal(t, roaring.TruncatedInput, de.Kind)
+		assert.Equal(t, 0, de.Container)
+
+		_, err = NewDefaultBSI().ReadFrom(bytes.NewReader(data[:10]))
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+		require.True(t, errors.As(err, &de))
+		assert.Equal(t, -1, de.Container)
+
+		_, err = NewDefaultBSI().ReadFrom(bytes.NewReader(data[:24]))
+		require.True(t, errors.As(err, &de))
+		assert.Equal(t, 0, de.Container)
+		assert.GreaterOrEqual(t, de.Offset, int64(20))
+	})
+
+	t.Run("unmarshal", func(t *testing.T) {
+		bitData, err := bsi.MarshalBinary()
+		require.NoError(t, err)
+		bitData[2] = []byte{1, 2, 3, 4}
+		err = NewDefaultBSI().UnmarshalBinary(bitData)
+		var de *roaring.DeserializationError
+		require.True(t, errors.As(err, &de))
+		assert.Equal(t, roaring.InvalidCookie, de.Kind)
+		assert.Equal(t, 2, de.Container)
+	})
+}
+
+// Test that the BSI can be mutated and still be equal to a fresh BSI with the same values.
+func TestMutatedBsiEquality(t *testing.T) {
+	mutated := NewDefaultBSI()
+	mutated.SetValue(0, 2)
+	mutated.SetValue(0, 1)
+	fresh := NewDefaultBSI()
+	fresh.SetValue(0, 1)
+	assert.True(t, fresh.Equals(mutated))
+	fresh.SetValue(0, 2)
+	assert.False(t, fresh.Equals(mutated))
+	// Now fresh has been mutated in the same pattern as mutated.
+	fresh.SetValue(0, 1)
+	assert.True(t, fresh.Equals(mutated))
+	fresh.SetValue(1, 1)
+	assert.False(t, fresh.Equals(mutated))
+}
+
+func TestRetain(t *testing.T) {
+	bsi := setup()
+	retain := roaring.BitmapOf(50, 51, 52, 1000)
+
+	assert.EqualValues(t, 0, bsi.Clone().Retain(bsi.GetExistenceBitmap()))
+
+	dropped := bsi.Retain(retain)
+	assert.Equal(t, uint64(97), dropped)
+	assert.EqualValues(t, 3, bsi.GetCardinality())
+	for _, col := range []uint64{50, 51, 52} {
+		v, ok := bsi.GetValue(col)
+		assert.True(t, ok)
+		assert.EqualValues(t, col, v)
+	}
+	_, ok := bsi.GetValue(53)
+	assert.False(t, ok)
+	assert.True(t, bsi.Equals(setup().NewBSIRetainSet(retain)))
+}
+
+func TestGetSizeInBytes(t *testing.T) {
+	bsi := setup()
+	size := bsi.GetSizeInBytes()
+	expected := bsi.GetExistenceBitmap().GetSizeInBytes()
+	for i := 0; i < bsi.BitCount(); i++ {
+		expected += bsi.bA[i].GetSizeInBytes()
+	}
+	assert.EqualValues(t, expected, size)
+
+	bsi.Retain(roaring.New())
+	assert.Less(t, bsi.GetSizeInBytes(), size)
+}
+
 func BenchmarkSetRoaring(b *testing.B) {
 	b.StopTimer()
 	r := rand.New(rand.NewSource(0))
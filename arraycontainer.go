@@ -49,11 +49,25 @@ func (ac *arrayContainer) iterate(cb func(x uint16) bool) bool {
 	return true
 }
 
+func (ac *arrayContainer) iterateRuns(cb func(start, endx int) bool) bool {
+	for i := 0; i < len(ac.content); {
+		start := int(ac.content[i])
+		endx := start + 1
+		for i++; i < len(ac.content) && int(ac.content[i]) == endx; i++ {
+			endx++
+		}
+		if !cb(start, endx) {
+			return false
+		}
+	}
+	return true
+}
+
 func (ac *arrayContainer) getShortIterator() shortPeekable {
 	return &shortIterator{ac.content, 0}
 }
 
-func (ac *arrayContainer) getReverseIterator() shortIterable {
+func (ac *arrayContainer) getReverseIterator() shortReversePeekable {
 	return &reverseIterator{ac.content, len(ac.content) - 1}
 }
 
@@ -1088,7 +1102,7 @@ func (ac *arrayContainer) nextAbsentValue(target uint16) int {
 
 	if result.index == cardinality-2 {
 		if ac.maximum() != result.value+1 {
-			return int(result.value + 1)
+			return int(result.value) + 1
 		}
 	}
 
@@ -1113,10 +1127,10 @@ func (ac *arrayContainer) nextAbsentValue(target uint16) int {
 	}
 
 	if low == cardinality-1 {
-		return int(ac.content[cardinality-1] + 1)
+		return int(ac.content[cardinality-1]) + 1
 	}
 
-	return int(ac.content[low] + 1)
+	return int(ac.content[low]) + 1
 }
 
 // nextValue returns either the target if found or the next larger value.
@@ -130,6 +130,35 @@ func (bc *bitmapContainer) iterate(cb func(x uint16) bool) bool {
 	return true
 }
 
+func (bc *bitmapContainer) iterateRuns(cb func(start, endx int) bool) bool {
+	for start := bc.NextSetBit(0); start >= 0; {
+		endx := bc.nextUnsetBit(uint(start))
+		if !cb(start, endx) {
+			return false
+		}
+		if endx >= maxCapacity {
+			break
+		}
+		start = bc.NextSetBit(uint(endx))
+	}
+	return true
+}
+
+// nextUnsetBit returns the first value from i that is not in the container,
+// or maxCapacity if there is none.
+func (bc *bitmapContainer) nextUnsetBit(i uint) int {
+	x := i / 64
+	if w := ^bc.bitmap[x] >> (i % 64); w != 0 {
+		return int(i) + countTrailingZeros(w)
+	}
+	for x++; x < uint(len(bc.bitmap)); x++ {
+		if w := ^bc.bitmap[x]; w != 0 {
+			return int(x*64) + countTrailingZeros(w)
+		}
+	}
+	return maxCapacity
+}
+
 type bitmapContainerShortIterator struct {
 	ptr *bitmapContainer
 	i   int
@@ -182,6 +211,16 @@ func (bcsi *reverseBitmapContainerShortIterator) hasNext() bool {
 	return bcsi.i >= 0
 }
 
+func (bcsi *reverseBitmapContainerShortIterator) peekNext() uint16 {
+	return uint16(bcsi.i)
+}
+
+func (bcsi *reverseBitmapContainerShortIterator) retreatIfNeeded(maxval uint16) {
+	if bcsi.hasNext() && bcsi.peekNext() > maxval {
+		bcsi.i = bcsi.ptr.PrevSetBit(int(maxval))
+	}
+}
+
 func newReverseBitmapContainerShortIterator(a *bitmapContainer) *reverseBitmapContainerShortIterator {
 	if a.cardinality == 0 {
 		return &reverseBitmapContainerShortIterator{a, -1}
@@ -189,7 +228,7 @@ func newReverseBitmapContainerShortIterator(a *bitmapContainer) *reverseBitmapCo
 	return &reverseBitmapContainerShortIterator{a, int(a.maximum())}
 }
 
-func (bc *bitmapContainer) getReverseIterator() shortIterable {
+func (bc *bitmapContainer) getReverseIterator() shortReversePeekable {
 	return newReverseBitmapContainerShortIterator(bc)
 }
 
@@ -262,6 +301,68 @@ func (bc *bitmapContainer) getManyIterator() manyIterable {
 	return newBitmapContainerManyIterator(bc)
 }
 
+type reverseBitmapContainerManyIterator struct {
+	ptr    *bitmapContainer
+	base   int
+	bitset uint64
+}
+
+// nextMany returns the number of values added to the buffer, in decreasing order
+func (bcmi *reverseBitmapContainerManyIterator) nextMany(hs uint32, buf []uint32) int {
+	n := 0
+	base := bcmi.base
+	bitset := bcmi.bitset
+
+	for n < len(buf) {
+		if bitset == 0 {
+			base--
+			if base < 0 {
+				bcmi.base = base
+				bcmi.bitset = bitset
+				return n
+			}
+			bitset = bcmi.ptr.bitmap[base]
+			continue
+		}
+		i := 63 - countLeadingZeros(bitset)
+		buf[n] = uint32((base*64)+i) | hs
+		n = n + 1
+		bitset ^= uint64(1) << uint(i)
+	}
+
+	bcmi.base = base
+	bcmi.bitset = bitset
+	return n
+}
+
+// nextMany64 returns the number of values added to the buffer, in decreasing order
+func (bcmi *reverseBitmapContainerManyIterator) nextMany64(hs uint64, buf []uint64) int {
+	n := 0
+	base := bcmi.base
+	bitset := bcmi.bitset
+
+	for n < len(buf) {
+		if bitset == 0 {
+			base--
+			if base < 0 {
+				bcmi.base = base
+				bcmi.bitset = bitset
+				return n
+			}
+			bitset = bcmi.ptr.bitmap[base]
+			continue
+		}
+		i := 63 - countLeadingZeros(bitset)
+		buf[n] = uint64((base*64)+i) | hs
+		n = n + 1
+		bitset ^= uint64(1) << uint(i)
+	}
+
+	bcmi.base = base
+	bcmi.bitset = bitset
+	return n
+}
+
 func (bc *bitmapContainer) getSizeInBytes() int {
 	return len(bc.bitmap) * 8
 }
@@ -527,6 +628,59 @@ func (bc *bitmapContainer) lazyOR(a container) container {
 	panic("unsupported container type")
 }
 
+// lazyIAND computes the intersection with a in place, without computing
+// the cardinality or converting bc to another container type.
+func (bc *bitmapContainer) lazyIAND(a container) {
+	switch x := a.(type) {
+	case *arrayContainer:
+		i := 0
+		for k := range bc.bitmap {
+			mask := uint64(0)
+			for i < len(x.content) && int(x.content[i]>>6) == k {
+				mask |= uint64(1) << (x.content[i] % 64)
+				i++
+			}
+			bc.bitmap[k] &= mask
+		}
+	case *bitmapContainer:
+		for k := range bc.bitmap {
+			bc.bitmap[k] &= x.bitmap[k]
+		}
+	case *runContainer16:
+		lastEnd := 0
+		for _, iv := range x.iv {
+			resetBitmapRange(bc.bitmap, lastEnd, int(iv.start))
+			lastEnd = int(iv.last()) + 1
+		}
+		resetBitmapRange(bc.bitmap, lastEnd, maxCapacity)
+	default:
+		panic("unsupported container type")
+	}
+	bc.cardinality = invalidCardinality
+}
+
+// lazyIXOR computes the symmetric difference with a in place, without
+// computing the cardinality or converting bc to another container type.
+func (bc *bitmapContainer) lazyIXOR(a container) {
+	switch x := a.(type) {
+	case *arrayContainer:
+		for _, v := range x.content {
+			bc.bitmap[v>>6] ^= uint64(1) << (v % 64)
+		}
+	case *bitmapContainer:
+		for k := range bc.bitmap {
+			bc.bitmap[k] ^= x.bitmap[k]
+		}
+	case *runContainer16:
+		for _, iv := range x.iv {
+			flipBitmapRange(bc.bitmap, int(iv.start), int(iv.last())+1)
+		}
+	default:
+		panic("unsupported container type")
+	}
+	bc.cardinality = invalidCardinality
+}
+
 func (bc *bitmapContainer) orArray(value2 *arrayContainer) container {
 	answer := bc.clone().(*bitmapContainer)
 	c := value2.getCardinality()
@@ -0,0 +1,255 @@
+package roaring
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/RoaringBitmap/roaring/v2/internal"
+)
+
+// decoderBufferSize is the number of values decoded ahead by Decoder.Next
+const decoderBufferSize = 64
+
+// Decoder reads the values of a bitmap serialized in the portable format (as written by WriteTo)
+// straight from a stream, in increasing order, without building the bitmap.
+// Only the descriptive header and one container are held in memory at any time, so a Decoder
+// can scan serialized bitmaps much larger than the available memory.
+//
+// Like ReadFrom, the Decoder checks the framing of the input (cookie, sizes, key order) but
+// trusts the content of the containers. Errors are reported by Err as a *DeserializationError.
+type Decoder struct {
+	stream  internal.ByteInput
+	adapter internal.ByteInputAdapter
+	err     error
+
+	started      bool
+	size         int
+	keycard      []uint16
+	isRunBitmap  []byte
+	headerOffset int64
+	pos          int
+
+	hs         uint32
+	iter       manyIterable
+	shortIter  shortIterator
+	runIter    runIterator16
+	bitmapIter bitmapContainerManyIterator
+	rc         runContainer16
+	bc         bitmapContainer
+	scratch    []byte
+
+	values    [decoderBufferSize]uint32
+	valuesPos int
+	valuesLen int
+}
+
+// NewDecoder returns a Decoder reading a serialized bitmap from reader.
+// Nothing is read before the first call to HasNext, Next or NextMany.
+func NewDecoder(reader io.Reader) *Decoder {
+	d := &Decoder{}
+	d.Reset(reader)
+	return d
+}
+
+// NewDecoderFromBuffer returns a Decoder reading a serialized bitmap from buf.
+// The containers are not copied: buf must not be modified while the Decoder is in use.
+func NewDecoderFromBuffer(buf []byte) *Decoder {
+	return NewDecoder(internal.NewByteBuffer(buf))
+}
+
+// Reset discards the state of the Decoder so that it reads a new serialized bitmap from reader.
+// The memory already allocated by the Decoder is reused.
+func (d *Decoder) Reset(reader io.Reader) {
+	if stream, ok := reader.(internal.ByteInput); ok {
+		d.stream = stream
+	} else {
+		d.adapter.Reset(reader)
+		d.stream = &d.adapter
+	}
+	d.err = nil
+	d.started = false
+	d.size = 0
+	d.keycard = nil
+	d.isRunBitmap = nil
+	d.pos = 0
+	d.iter = nil
+	d.valuesPos, d.valuesLen = 0, 0
+}
+
+// HasNext returns true if there are more values to read. It returns false at the end of the
+// bitmap and when the input is invalid, see Err.
+func (d *Decoder) HasNext() bool {
+	return d.valuesPos < d.valuesLen || d.fill()
+}
+
+// Next returns the next value, HasNext must have returned true.
+func (d *Decoder) Next() uint32 {
+	if d.valuesPos == d.valuesLen && !d.fill() {
+		return 0
+	}
+	x := d.values[d.valuesPos]
+	d.valuesPos++
+	return x
+}
+
+// NextMany reads as many values as possible into buf and returns the number of values read.
+// It returns less than len(buf) only at the end of the bitmap or when the input is invalid, see Err.
+func (d *Decoder) NextMany(buf []uint32) int {
+	n := copy(buf, d.values[d.valuesPos:d.valuesLen])
+	d.valuesPos += n
+	for n < len(buf) {
+		if d.iter == nil && !d.nextContainer() {
+			break
+		}
+		moreN := d.iter.nextMany(d.hs, buf[n:])
+		if moreN == 0 {
+			d.iter = nil
+		}
+		n += moreN
+	}
+	return n
+}
+
+// Err returns the error that stopped the Decoder, or nil if it did not stop or
+// reached the end of a valid bitmap.
+func (d *Decoder) Err() error {
+	return d.err
+}
+
+// ReadBytes returns the number of bytes read from the input so far. Once HasNext returned false
+// without error, it is the size of the serialized bitmap.
+func (d *Decoder) ReadBytes() int64 {
+	return d.stream.GetReadBytes()
+}
+
+// fill decodes the next values into the buffer of Next
+func (d *Decoder) fill() bool {
+	d.valuesPos, d.valuesLen = 0, 0
+	for d.iter != nil || d.nextContainer() {
+		if n := d.iter.nextMany(d.hs, d.values[:]); n > 0 {
+			d.valuesLen = n
+			return true
+		}
+		d.iter = nil
+	}
+	return false
+}
+
+// fail stops the Decoder with a DeserializationError
+func (d *Decoder) fail(kind DeserializationErrorKind, offset int64, container int, err error) bool {
+	d.err = &DeserializationError{Kind: kind, Offset: offset, Container: container, Err: err}
+	d.iter = nil
+	return false
+}
+
+// next returns the next n bytes of the input, they are only valid until the next call
+func (d *Decoder) next(n int) ([]byte, error) {
+	reader, ok := d.stream.(io.Reader)
+	if !ok || !d.stream.NextReturnsSafeSlice() {
+		return d.stream.Next(n)
+	}
+	// Next would allocate a new slice, reuse the scratch buffer instead
+	if cap(d.scratch) < n {
+		d.scratch = make([]byte, n)
+	}
+	d.scratch = d.scratch[:n]
+	_, err := io.ReadFull(reader, d.scratch)
+	return d.scratch, err
+}
+
+// readHeader reads the cookie and the descriptive header, skipping the offsets
+func (d *Decoder) readHeader() bool {
+	d.started = true
+	cookie, err := d.stream.ReadUInt32()
+	if err != nil {
+		return d.fail(readFailureKind(err), 0, -1, fmt.Errorf("could not read initial cookie: %w", err))
+	}
+
+	var size uint32
+	if cookie&0x0000FFFF == serialCookie {
+		size = cookie>>16 + 1
+		d.isRunBitmap, err = d.stream.Next((int(size) + 7) / 8)
+		if err != nil {
+			return d.fail(readFailureKind(err), 4, -1, fmt.Errorf("failed to read is-run bitmap: %w", err))
+		}
+	} else if cookie == serialCookieNoRunContainer {
+		size, err = d.stream.ReadUInt32()
+		if err != nil {
+			return d.fail(readFailureKind(err), 4, -1, fmt.Errorf("failed to read a bitmap size: %w", err))
+		}
+		if size > (1 << 16) {
+			return d.fail(InvalidContainerCount, 4, -1, fmt.Errorf("it is logically impossible to have more than (1<<16) containers"))
+		}
+	} else {
+		return d.fail(InvalidCookie, 0, -1, fmt.Errorf("did not find expected serialCookie in header"))
+	}
+
+	d.headerOffset = d.stream.GetReadBytes()
+	buf, err := d.stream.Next(2 * 2 * int(size))
+	if err != nil {
+		return d.fail(readFailureKind(err), d.headerOffset, -1, fmt.Errorf("failed to read descriptive header: %w", err))
+	}
+	d.keycard = byteSliceAsUint16Slice(buf)
+
+	if d.isRunBitmap == nil || size >= noOffsetThreshold {
+		offset := d.stream.GetReadBytes()
+		if _, err := d.next(int(size) * 4); err != nil {
+			return d.fail(readFailureKind(err), offset, -1, fmt.Errorf("failed to skip offsets: %w", err))
+		}
+	}
+	d.size = int(size)
+	return true
+}
+
+// nextContainer reads the next container and sets up the iterator over its values
+func (d *Decoder) nextContainer() bool {
+	if d.err != nil {
+		return false
+	}
+	if !d.started && !d.readHeader() {
+		return false
+	}
+	if d.pos == d.size {
+		return false
+	}
+
+	i := d.pos
+	key := d.keycard[2*i]
+	if i > 0 && key <= d.keycard[2*i-2] {
+		return d.fail(UnsortedKeys, d.headerOffset+4*int64(i), i, ErrKeySortOrder)
+	}
+	card := int(d.keycard[2*i+1]) + 1
+
+	offset := d.stream.GetReadBytes()
+	if d.isRunBitmap != nil && d.isRunBitmap[i/8]&(1<<(i%8)) != 0 {
+		nr, err := d.stream.ReadUInt16()
+		if err != nil {
+			return d.fail(readFailureKind(err), offset, i, fmt.Errorf("failed to read runtime container size: %w", err))
+		}
+		buf, err := d.next(int(nr) * 4)
+		if err != nil {
+			return d.fail(readFailureKind(err), offset, i, fmt.Errorf("failed to read runtime container content: %w", err))
+		}
+		d.rc.iv = byteSliceAsInterval16Slice(buf)
+		d.runIter = runIterator16{rc: &d.rc}
+		d.iter = &d.runIter
+	} else if card > arrayDefaultMaxSize {
+		buf, err := d.next(arrayDefaultMaxSize * 2)
+		if err != nil {
+			return d.fail(readFailureKind(err), offset, i, fmt.Errorf("failed to read bitmap container: %w", err))
+		}
+		d.bc = bitmapContainer{cardinality: card, bitmap: byteSliceAsUint64Slice(buf)}
+		d.bitmapIter = bitmapContainerManyIterator{&d.bc, -1, 0}
+		d.iter = &d.bitmapIter
+	} else {
+		buf, err := d.next(card * 2)
+		if err != nil {
+			return d.fail(readFailureKind(err), offset, i, fmt.Errorf("failed to read array container: %w", err))
+		}
+		d.shortIter = shortIterator{byteSliceAsUint16Slice(buf), 0}
+		d.iter = &d.shortIter
+	}
+	d.hs = uint32(key) << 16
+	d.pos++
+	return true
+}
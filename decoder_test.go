@@ -0,0 +1,166 @@
+package roaring
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecoder(t *testing.T) {
+	many := New()
+	for i := uint32(0); i < 10; i++ {
+		many.AddRange(uint64(i)<<16+5, uint64(i)<<16+100)
+	}
+
+	mixed := New()
+	mixed.AddRange(1, 100)
+	mixed.Add(200)
+	for i := uint32(0); i < 20000; i += 3 {
+		mixed.Add(1<<16 + i)
+	}
+	mixed.AddMany([]uint32{5 << 16, 5<<16 + 7, MaxUint32})
+
+	bitmaps := map[string]*Bitmap{
+		"empty":  New(),
+		"array":  BitmapOf(1, 2, 3, 1000, 1<<20),
+		"mixed":  mixed,
+		"many":   many,
+		"runs":   many.Clone(),
+		"single": BitmapOf(MaxUint32),
+	}
+	bitmaps["runs"].RunOptimize()
+	bitmaps["mixed runs"] = mixed.Clone()
+	bitmaps["mixed runs"].RunOptimize()
+
+	for name, rb := range bitmaps {
+		t.Run(name, func(t *testing.T) {
+			buf, err := rb.ToBytes()
+			require.NoError(t, err)
+			expected := rb.ToArray()
+
+			decoders := map[string]func() *Decoder{
+				"reader": func() *Decoder { return NewDecoder(bytes.NewReader(buf)) },
+				"buffer": func() *Decoder { return NewDecoderFromBuffer(buf) },
+			}
+			for dname, newDecoder := range decoders {
+				t.Run(dname, func(t *testing.T) {
+					d := newDecoder()
+					var values []uint32
+					for d.HasNext() {
+						values = append(values, d.Next())
+					}
+					require.NoError(t, d.Err())
+					assert.Equal(t, len(expected), len(values))
+					if len(expected) > 0 {
+						assert.Equal(t, expected, values)
+					}
+					assert.EqualValues(t, len(buf), d.ReadBytes())
+
+					for _, size := range []int{1, 7, 1000} {
+						d = newDecoder()
+						values = values[:0]
+						batch := make([]uint32, size)
+						for {
+							// mixing Next and NextMany must not lose values
+							if d.HasNext() {
+								values = append(values, d.Next())
+							}
+							n := d.NextMany(batch)
+							values = append(values, batch[:n]...)
+							if n < size {
+								break
+							}
+						}
+						require.NoError(t, d.Err())
+						assert.Equal(t, len(expected), len(values))
+						if len(expected) > 0 {
+							assert.Equal(t, expected, values)
+						}
+					}
+				})
+			}
+		})
+	}
+
+	t.Run("trailing data", func(t *testing.T) {
+		buf, err := mixed.ToBytes()
+		require.NoError(t, err)
+		d := NewDecoder(bytes.NewReader(append(buf, 1, 2, 3)))
+		n := 0
+		for d.HasNext() {
+			d.Next()
+			n++
+		}
+		require.NoError(t, d.Err())
+		assert.EqualValues(t, mixed.GetCardinality(), n)
+		assert.EqualValues(t, len(buf), d.ReadBytes())
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		first, err := BitmapOf(1, 2, 3).ToBytes()
+		require.NoError(t, err)
+		second, err := BitmapOf(4, 5).ToBytes()
+		require.NoError(t, err)
+
+		d := NewDecoder(bytes.NewReader(first))
+		assert.Equal(t, uint32(1), d.Next())
+		d.Reset(bytes.NewReader(second))
+		buf := make([]uint32, 10)
+		assert.Equal(t, []uint32{4, 5}, buf[:d.NextMany(buf)])
+	})
+}
+
+func TestDecoderErrors(t *testing.T) {
+	buf, err := BitmapOf(1, 2, 3, 1<<16+1).ToBytes()
+	require.NoError(t, err)
+
+	// decode reads all the values and returns the error of the decoder
+	decode := func(b []byte) (*DeserializationError, int) {
+		d := NewDecoder(bytes.NewReader(b))
+		n := 0
+		for d.HasNext() {
+			d.Next()
+			n++
+		}
+		assert.False(t, d.HasNext())
+		var de *DeserializationError
+		if d.Err() != nil {
+			require.True(t, errors.As(d.Err(), &de))
+		}
+		return de, n
+	}
+
+	t.Run("truncated", func(t *testing.T) {
+		de, n := decode(buf[:len(buf)-1])
+		require.NotNil(t, de)
+		assert.Equal(t, TruncatedInput, de.Kind)
+		assert.Equal(t, 1, de.Container)
+		// the values of the first container are still delivered
+		assert.Equal(t, 3, n)
+
+		de, _ = decode(nil)
+		require.NotNil(t, de)
+		assert.Equal(t, TruncatedInput, de.Kind)
+	})
+
+	t.Run("invalid cookie", func(t *testing.T) {
+		de, n := decode([]byte{1, 2, 3, 4, 5, 6, 7, 8})
+		require.NotNil(t, de)
+		assert.Equal(t, InvalidCookie, de.Kind)
+		assert.Equal(t, 0, n)
+	})
+
+	t.Run("unsorted keys", func(t *testing.T) {
+		// no run container: 8 bytes of cookie and size, then 4 bytes of key and cardinality per container
+		bad := append([]byte(nil), buf...)
+		bad[12] = 0
+		de, _ := decode(bad)
+		require.NotNil(t, de)
+		assert.Equal(t, UnsortedKeys, de.Kind)
+		assert.Equal(t, 1, de.Container)
+		assert.EqualValues(t, 12, de.Offset)
+	})
+}
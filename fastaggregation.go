@@ -2,6 +2,7 @@ package roaring
 
 import (
 	"container/heap"
+	"math/bits"
 )
 
 // Or function that requires repairAfterLazy
@@ -198,6 +199,299 @@ func HeapXor(bitmaps ...*Bitmap) *Bitmap {
 	return heap.Pop(&pq).(*item).value
 }
 
+// FastXor computes the symmetric difference between many bitmaps quickly, as opposed to having to call Xor repeatedly.
+// The containers sharing a key are combined lazily into a single bitmap container,
+// whose cardinality is only computed once. It might be faster than HeapXor.
+func FastXor(bitmaps ...*Bitmap) *Bitmap {
+	if len(bitmaps) == 0 {
+		return NewBitmap()
+	} else if len(bitmaps) == 1 {
+		return bitmaps[0].Clone()
+	}
+
+	answer := NewBitmap()
+	h := newBitmapContainerHeap(bitmaps...)
+	containers := make([]container, 0, len(bitmaps))
+	for h.Len() > 0 {
+		ck := h.Next(containers[:0])
+		containers = ck.containers
+
+		var c container
+		switch len(ck.containers) {
+		case 1:
+			c = ck.containers[0].clone()
+		case 2:
+			c = ck.containers[0].xor(ck.containers[1])
+		default:
+			bc := newBitmapContainer()
+			bc.resetTo(ck.containers[0])
+			for _, next := range ck.containers[1:] {
+				bc.lazyIXOR(next)
+			}
+			c = repairAfterLazy(bc)
+		}
+		if !c.isEmpty() {
+			answer.highlowcontainer.appendContainer(ck.key, c, false)
+		}
+	}
+	return answer
+}
+
+// FastAndNot computes the values of base that are in none of the subtrahends,
+// that is AndNot(base, FastOr(subtrahends...)), without building the union:
+// only the keys of base are visited, and the subtrahend containers sharing a
+// key are merged lazily into a single scratch container. Bitmaps are not modified.
+func FastAndNot(base *Bitmap, subtrahends ...*Bitmap) *Bitmap {
+	answer := NewBitmap()
+	positions := make([]int, len(subtrahends))
+	keyContainers := make([]container, 0, len(subtrahends))
+	var tmp *bitmapContainer
+
+	for pos := 0; pos < base.highlowcontainer.size(); pos++ {
+		key := base.highlowcontainer.getKeyAtIndex(pos)
+
+		full := false
+		for i, s := range subtrahends {
+			ra := &s.highlowcontainer
+			if positions[i] < ra.size() && ra.getKeyAtIndex(positions[i]) < key {
+				positions[i] = ra.advanceUntil(key, positions[i])
+			}
+			if positions[i] < ra.size() && ra.getKeyAtIndex(positions[i]) == key {
+				c := ra.getContainerAtIndex(positions[i])
+				full = full || c.isFull()
+				keyContainers = append(keyContainers, c)
+			}
+		}
+
+		var c container
+		switch {
+		case full:
+			// nothing can remain
+		case len(keyContainers) == 0:
+			answer.highlowcontainer.appendCopy(base.highlowcontainer, pos)
+		case len(keyContainers) == 1:
+			c = base.highlowcontainer.getContainerAtIndex(pos).andNot(keyContainers[0])
+		default:
+			if tmp == nil {
+				tmp = newBitmapContainer()
+			}
+			tmp.resetTo(keyContainers[0])
+			for _, next := range keyContainers[1:] {
+				tmp.lazyIOR(next)
+			}
+			tmp.computeCardinality()
+			c = base.highlowcontainer.getContainerAtIndex(pos).andNot(tmp)
+		}
+		if c != nil && !c.isEmpty() {
+			answer.highlowcontainer.appendContainer(key, c, false)
+		}
+		keyContainers = keyContainers[:0]
+	}
+	return answer
+}
+
+// FastOrCardinality returns the cardinality of the union between many
+// bitmaps, as FastOr(bitmaps...).GetCardinality() would, but without
+// building the union: the containers sharing a key are merged lazily into
+// a single scratch container and only counted.
+func FastOrCardinality(bitmaps ...*Bitmap) uint64 {
+	return heapCardinality(func(containers []container, tmp *bitmapContainer) int {
+		switch len(containers) {
+		case 1:
+			return containers[0].getCardinality()
+		case 2:
+			return containers[0].orCardinality(containers[1])
+		}
+		for _, c := range containers {
+			if c.isFull() {
+				return maxCapacity
+			}
+		}
+		tmp.resetTo(containers[0])
+		for _, c := range containers[1:] {
+			tmp.lazyIOR(c)
+		}
+		return int(popcntSlice(tmp.bitmap))
+	}, bitmaps...)
+}
+
+// FastAndCardinality returns the cardinality of the intersection between
+// many bitmaps, as FastAnd(bitmaps...).GetCardinality() would, but without
+// building the intersection.
+func FastAndCardinality(bitmaps ...*Bitmap) uint64 {
+	if len(bitmaps) == 0 {
+		return 0
+	}
+	for _, bm := range bitmaps {
+		if bm.IsEmpty() {
+			return 0
+		}
+	}
+	return heapCardinality(func(containers []container, tmp *bitmapContainer) int {
+		switch {
+		case len(containers) < len(bitmaps):
+			return 0
+		case len(containers) == 1:
+			return containers[0].getCardinality()
+		case len(containers) == 2:
+			return containers[0].andCardinality(containers[1])
+		}
+		tmp.resetTo(containers[0])
+		for _, c := range containers[1:] {
+			tmp.lazyIAND(c)
+		}
+		return int(popcntSlice(tmp.bitmap))
+	}, bitmaps...)
+}
+
+// FastXorCardinality returns the cardinality of the symmetric difference
+// between many bitmaps, as HeapXor(bitmaps...).GetCardinality() would, but
+// without building the symmetric difference.
+func FastXorCardinality(bitmaps ...*Bitmap) uint64 {
+	return heapCardinality(func(containers []container, tmp *bitmapContainer) int {
+		switch len(containers) {
+		case 1:
+			return containers[0].getCardinality()
+		case 2:
+			c1, c2 := containers[0], containers[1]
+			return c1.getCardinality() + c2.getCardinality() - 2*c1.andCardinality(c2)
+		}
+		tmp.resetTo(containers[0])
+		for _, c := range containers[1:] {
+			tmp.lazyIXOR(c)
+		}
+		return int(popcntSlice(tmp.bitmap))
+	}, bitmaps...)
+}
+
+// heapCardinality walks the keys of all bitmaps in increasing order and sums
+// count over the containers found under each key. tmp is a scratch
+// container shared by all calls to count, it is nil for less than three
+// bitmaps since two containers can always be counted directly.
+func heapCardinality(count func(containers []container, tmp *bitmapContainer) int, bitmaps ...*Bitmap) uint64 {
+	var tmp *bitmapContainer
+	if len(bitmaps) > 2 {
+		tmp = newBitmapContainer()
+	}
+	answer := uint64(0)
+	h := newBitmapContainerHeap(bitmaps...)
+	containers := make([]container, 0, len(bitmaps))
+	for h.Len() > 0 {
+		ck := h.Next(containers[:0])
+		answer += uint64(count(ck.containers, tmp))
+		containers = ck.containers
+	}
+	return answer
+}
+
+// FastThreshold computes the values that are present in at least k of the
+// given bitmaps. With k <= 1 it is equivalent to FastOr, with k equal to the
+// number of bitmaps it is equivalent to FastAnd, and it returns an empty
+// bitmap when k exceeds the number of bitmaps.
+//
+// The containers sharing a key are visited together, as in HeapOr; keys held
+// by fewer than k containers are skipped, and the others are counted with a
+// bit-sliced adder.
+func FastThreshold(k int, bitmaps ...*Bitmap) *Bitmap {
+	if k <= 1 {
+		return FastOr(bitmaps...)
+	} else if k > len(bitmaps) {
+		return NewBitmap()
+	} else if k == len(bitmaps) {
+		return FastAnd(bitmaps...)
+	}
+
+	answer := NewBitmap()
+	h := newBitmapContainerHeap(bitmaps...)
+	containers := make([]container, 0, len(bitmaps))
+	var counter bitSlicedCounter
+	for h.Len() > 0 {
+		ck := h.Next(containers[:0])
+		containers = ck.containers
+		if len(ck.containers) < k {
+			continue
+		}
+
+		var c container
+		if len(ck.containers) == k {
+			c = ck.containers[0].and(ck.containers[1])
+			for _, next := range ck.containers[2:] {
+				if c.isEmpty() {
+					break
+				}
+				c = c.and(next)
+			}
+		} else {
+			counter.reset(len(ck.containers))
+			for _, next := range ck.containers {
+				counter.add(next)
+			}
+			c = repairAfterLazy(counter.atLeast(k))
+		}
+		if !c.isEmpty() {
+			answer.highlowcontainer.appendContainer(ck.key, c, false)
+		}
+	}
+	return answer
+}
+
+// bitSlicedCounter counts, for each of the 2^16 values of a container, how
+// many of the added containers hold it. The counts are stored as bit slices,
+// slices[i] holding bit i of every count, so that adding a container is a
+// ripple-carry addition over its words.
+type bitSlicedCounter struct {
+	slices [][]uint64
+	tmp    *bitmapContainer
+}
+
+// reset clears the counter so that it can count up to maxCount containers.
+func (bsc *bitSlicedCounter) reset(maxCount int) {
+	n := bits.Len(uint(maxCount))
+	for len(bsc.slices) < n {
+		bsc.slices = append(bsc.slices, make([]uint64, bitmapContainerSize))
+	}
+	bsc.slices = bsc.slices[:n]
+	for _, slice := range bsc.slices {
+		fill(slice, 0)
+	}
+	if bsc.tmp == nil {
+		bsc.tmp = newBitmapContainer()
+	}
+}
+
+func (bsc *bitSlicedCounter) add(c container) {
+	bsc.tmp.resetTo(c)
+	for w, carry := range bsc.tmp.bitmap {
+		for _, slice := range bsc.slices {
+			if carry == 0 {
+				break
+			}
+			slice[w], carry = slice[w]^carry, slice[w]&carry
+		}
+	}
+}
+
+// atLeast returns a lazy bitmap container (see repairAfterLazy) holding the
+// values counted at least k times.
+func (bsc *bitSlicedCounter) atLeast(k int) *bitmapContainer {
+	answer := newBitmapContainer()
+	for w := range answer.bitmap {
+		// compare the counts with k from the most significant bit down
+		gt, eq := uint64(0), ^uint64(0)
+		for i := len(bsc.slices) - 1; i >= 0; i-- {
+			if k&(1<<uint(i)) != 0 {
+				eq &= bsc.slices[i][w]
+			} else {
+				gt |= eq & bsc.slices[i][w]
+				eq &^= bsc.slices[i][w]
+			}
+		}
+		answer.bitmap[w] = gt | eq
+	}
+	answer.cardinality = invalidCardinality
+	return answer
+}
+
 // AndAny provides a result equivalent to x1.And(FastOr(bitmaps)).
 // It's optimized to minimize allocations. It also might be faster than separate calls.
 func (x1 *Bitmap) AndAny(bitmaps ...*Bitmap) {
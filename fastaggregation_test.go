@@ -5,6 +5,7 @@ package roaring
 import (
 	"container/heap"
 	"github.com/stretchr/testify/assert"
+	"math/rand"
 	"testing"
 )
 
@@ -265,3 +266,85 @@ func TestFastAggregationsAndAny(t *testing.T) {
 
 	assert.True(t, fast.Equals(orFirst))
 }
+
+func TestFastAggregationsCardinality(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	bitmaps := make([]*Bitmap, 6)
+	for i := range bitmaps {
+		rb := NewBitmap()
+		// a full container in every bitmap, then a run, a bitmap and an array
+		// container at keys shared by some of the bitmaps
+		rb.AddRange(0, maxCapacity)
+		start := uint64(maxCapacity + r.Intn(maxCapacity))
+		rb.AddRange(start, start+uint64(r.Intn(3*maxCapacity)))
+		for j := 0; j < 2*arrayDefaultMaxSize; j++ {
+			rb.Add(uint32(4*maxCapacity + r.Intn(maxCapacity)))
+		}
+		for j := 0; j < 100; j++ {
+			rb.Add(uint32((5+r.Intn(i+1))*maxCapacity + r.Intn(maxCapacity)))
+		}
+		rb.RunOptimize()
+		bitmaps[i] = rb
+	}
+	bitmaps[1].Remove(7)
+
+	for n := 0; n <= len(bitmaps); n++ {
+		input := bitmaps[:n]
+		assert.Equal(t, FastOr(input...).GetCardinality(), FastOrCardinality(input...), "%d bitmaps", n)
+		assert.Equal(t, FastAnd(input...).GetCardinality(), FastAndCardinality(input...), "%d bitmaps", n)
+		assert.Equal(t, HeapXor(input...).GetCardinality(), FastXorCardinality(input...), "%d bitmaps", n)
+	}
+
+	assert.Zero(t, FastAndCardinality(bitmaps[0], NewBitmap(), bitmaps[1]))
+}
+
+func TestFastThreshold(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	bitmaps := make([]*Bitmap, 7)
+	for i := range bitmaps {
+		rb := NewBitmap()
+		rb.AddRange(0, maxCapacity)
+		start := uint64(maxCapacity + r.Intn(maxCapacity))
+		rb.AddRange(start, start+uint64(r.Intn(3*maxCapacity)))
+		for j := 0; j < 2*arrayDefaultMaxSize; j++ {
+			rb.Add(uint32(4*maxCapacity + r.Intn(maxCapacity)))
+		}
+		for j := 0; j < 500; j++ {
+			rb.Add(uint32((5+r.Intn(i+1))*maxCapacity + r.Intn(1000)))
+		}
+		rb.RunOptimize()
+		bitmaps[i] = rb
+	}
+
+	counts := make(map[uint32]int)
+	atLeast := func(k int) *Bitmap {
+		answer := NewBitmap()
+		for x, count := range counts {
+			if count >= k {
+				answer.Add(x)
+			}
+		}
+		return answer
+	}
+
+	for n := 0; n <= len(bitmaps); n++ {
+		if n > 0 {
+			bitmaps[n-1].Iterate(func(x uint32) bool {
+				counts[x]++
+				return true
+			})
+		}
+		for k := 0; k <= n+1; k++ {
+			expected := atLeast(k)
+			actual := FastThreshold(k, bitmaps[:n]...)
+			assert.True(t, expected.Equals(actual), "k=%d of %d bitmaps", k, n)
+			if !actual.IsEmpty() {
+				assert.NoError(t, actual.Validate())
+			}
+		}
+	}
+
+	assert.True(t, FastThreshold(1, bitmaps...).Equals(FastOr(bitmaps...)))
+	assert.True(t, FastThreshold(len(bitmaps), bitmaps...).Equals(FastAnd(bitmaps...)))
+	assert.True(t, FastThreshold(len(bitmaps)+1, bitmaps...).IsEmpty())
+}
@@ -0,0 +1,230 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// BSIStreamCookie starts the header of a BSI serialized by the WriteTo method of
+// BitSliceIndexing.BSI or roaring64.BSI ("BSIS" in little endian)
+const BSIStreamCookie = 0x53495342
+
+// BSIStreamHeaderSize is the size in bytes of the header of a serialized BSI: the cookie,
+// followed by MinValue and MaxValue
+const BSIStreamHeaderSize = 20
+
+// BSIHeader holds the metadata stored in the header of a serialized BSI
+type BSIHeader struct {
+	MinValue int64
+	MaxValue int64
+}
+
+// WriteBSIHeader writes the header of a serialized BSI to w, all integers are little endian
+func WriteBSIHeader(w io.Writer, header BSIHeader) (int, error) {
+	var buf [BSIStreamHeaderSize]byte
+	binary.LittleEndian.PutUint32(buf[0:], BSIStreamCookie)
+	binary.LittleEndian.PutUint64(buf[4:], uint64(header.MinValue))
+	binary.LittleEndian.PutUint64(buf[12:], uint64(header.MaxValue))
+	return w.Write(buf[:])
+}
+
+// ReadBSIHeader reads the header of a serialized BSI from stream and returns the reader
+// of the bitmaps which follow it, with the number of header bytes read.
+//
+// Streams written before the header was introduced start directly with the existence bitmap,
+// whose first 4 bytes do not match BSIStreamCookie: a 32-bit bitmap starts with its own cookie,
+// and a 64-bit one with its number of inner bitmaps. The header is then nil, and rest gives
+// back those 4 bytes before the remainder of stream.
+// When stream is empty, the error is io.EOF.
+func ReadBSIHeader(stream io.Reader) (header *BSIHeader, rest io.Reader, n int, err error) {
+	var buf [BSIStreamHeaderSize]byte
+	n, err = io.ReadFull(stream, buf[:4])
+	if err != nil {
+		return nil, nil, n, err
+	}
+	if binary.LittleEndian.Uint32(buf[:4]) != BSIStreamCookie {
+		return nil, io.MultiReader(bytes.NewReader(buf[:4]), stream), 0, nil
+	}
+	m, err := io.ReadFull(stream, buf[4:])
+	n += m
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, nil, n, err
+	}
+	header = &BSIHeader{
+		MinValue: int64(binary.LittleEndian.Uint64(buf[4:])),
+		MaxValue: int64(binary.LittleEndian.Uint64(buf[12:])),
+	}
+	return header, stream, n, nil
+}
+
+/* Frozen layout of a BSI, shared by BitSliceIndexing.BSI and roaring64.BSI.
+ *
+ * <cookie>     uint32, identifies the BSI implementation
+ * <slices>     uint32, number of bit slices
+ * <min>        int64, MinValue
+ * <max>        int64, MaxValue
+ * <sizes>      uint64[slices+1], size in bytes of every frozen bitmap below
+ * for the existence bitmap, then every bit slice in least to most significance order:
+ *   <padding>  so that the bitmap below starts on a 32-byte boundary
+ *   <bitmap>   a bitmap in the frozen format of the implementation
+ *
+ * All integers are little endian. Alignment is computed relative to the start
+ * of the buffer, so the buffer itself should be 32-byte aligned (as memory maps are).
+ */
+
+// FrozenBSIHeaderSize is the size in bytes of the header of a frozen BSI, before the sizes
+const FrozenBSIHeaderSize = 4 + 4 + 8 + 8
+
+// FrozenBitmap is a bitmap which can be serialized in a frozen format
+type FrozenBitmap interface {
+	GetFrozenSizeInBytes() uint64
+	FreezeTo(buf []byte) (int, error)
+	WriteFrozenTo(wr io.Writer) (int, error)
+}
+
+// frozenBSIPadding returns the number of padding bytes needed at offset
+// so that the frozen bitmap starting there is 32-byte aligned.
+func frozenBSIPadding(offset uint64) uint64 {
+	return (32 - offset%32) % 32
+}
+
+// FrozenBSISize returns the size in bytes of a frozen BSI made of bitmaps, the existence
+// bitmap followed by every bit slice
+func FrozenBSISize(bitmaps []FrozenBitmap) uint64 {
+	size := uint64(FrozenBSIHeaderSize) + 8*uint64(len(bitmaps))
+	for _, bm := range bitmaps {
+		size += frozenBSIPadding(size) + bm.GetFrozenSizeInBytes()
+	}
+	return size
+}
+
+// frozenBSIHeader returns the header of a frozen BSI, followed by the sizes of the bitmaps
+func frozenBSIHeader(buf []byte, cookie uint32, header BSIHeader, bitmaps []FrozenBitmap) []byte {
+	binary.LittleEndian.PutUint32(buf, cookie)
+	binary.LittleEndian.PutUint32(buf[4:], uint32(len(bitmaps)-1))
+	binary.LittleEndian.PutUint64(buf[8:], uint64(header.MinValue))
+	binary.LittleEndian.PutUint64(buf[16:], uint64(header.MaxValue))
+	for i, bm := range bitmaps {
+		binary.LittleEndian.PutUint64(buf[FrozenBSIHeaderSize+8*i:], bm.GetFrozenSizeInBytes())
+	}
+	return buf[:FrozenBSIHeaderSize+8*len(bitmaps)]
+}
+
+// FreezeBSITo serializes a BSI in the frozen layout to buf, which must hold at least
+// FrozenBSISize(bitmaps) bytes.
+func FreezeBSITo(buf []byte, cookie uint32, header BSIHeader, bitmaps []FrozenBitmap) (int, error) {
+	offset := uint64(len(frozenBSIHeader(buf, cookie, header, bitmaps)))
+	for _, bm := range bitmaps {
+		padding := frozenBSIPadding(offset)
+		for j := offset; j < offset+padding; j++ {
+			buf[j] = 0
+		}
+		offset += padding
+
+		n, err := bm.FreezeTo(buf[offset:])
+		if err != nil {
+			return 0, err
+		}
+		offset += uint64(n)
+	}
+	return int(offset), nil
+}
+
+// WriteFrozenBSITo serializes a BSI in the frozen layout to wr.
+func WriteFrozenBSITo(wr io.Writer, cookie uint32, header BSIHeader, bitmaps []FrozenBitmap) (int, error) {
+	var padding [32]byte
+	written, err := wr.Write(frozenBSIHeader(make([]byte, FrozenBSIHeaderSize+8*len(bitmaps)), cookie, header, bitmaps))
+	if err != nil {
+		return written, err
+	}
+
+	for _, bm := range bitmaps {
+		n, err := wr.Write(padding[:frozenBSIPadding(uint64(written))])
+		written += n
+		if err != nil {
+			return written, err
+		}
+
+		n, err = bm.WriteFrozenTo(wr)
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// FrozenBSIProblem tells why a buffer does not hold a frozen BSI
+type FrozenBSIProblem int
+
+const (
+	// FrozenBSITruncated means that the buffer ends before the BSI
+	FrozenBSITruncated FrozenBSIProblem = iota
+	// FrozenBSIInvalidCookie means that the buffer does not start with the expected cookie
+	FrozenBSIInvalidCookie
+	// FrozenBSITrailingData means that the buffer continues after the BSI
+	FrozenBSITrailingData
+)
+
+// FrozenBSIFailure locates the problem found by ParseFrozenBSI
+type FrozenBSIFailure struct {
+	Problem FrozenBSIProblem
+	Offset  int
+	// Bitmap is the index of the bitmap (0 for the existence bitmap, i+1 for the bit slice i),
+	// or -1 for the header
+	Bitmap int
+}
+
+// FrozenBSI is a BSI in the frozen layout, as parsed by ParseFrozenBSI
+type FrozenBSI struct {
+	BSIHeader
+	// Bitmaps holds the frozen existence bitmap, then every frozen bit slice, as sub-slices of the buffer
+	Bitmaps [][]byte
+	// Offsets holds the offset in the buffer of every bitmap
+	Offsets []int64
+}
+
+// ParseFrozenBSI checks the frozen layout of buf, which must start with cookie, and
+// locates its bitmaps without reading them.
+func ParseFrozenBSI(buf []byte, cookie uint32) (*FrozenBSI, *FrozenBSIFailure) {
+	if len(buf) < FrozenBSIHeaderSize {
+		return nil, &FrozenBSIFailure{Problem: FrozenBSITruncated, Offset: len(buf), Bitmap: -1}
+	}
+	if binary.LittleEndian.Uint32(buf) != cookie {
+		return nil, &FrozenBSIFailure{Problem: FrozenBSIInvalidCookie, Offset: 0, Bitmap: -1}
+	}
+	slices := uint64(binary.LittleEndian.Uint32(buf[4:]))
+	if slices+1 > uint64(len(buf)-FrozenBSIHeaderSize)/8 {
+		return nil, &FrozenBSIFailure{Problem: FrozenBSITruncated, Offset: len(buf), Bitmap: -1}
+	}
+
+	f := &FrozenBSI{
+		BSIHeader: BSIHeader{
+			MinValue: int64(binary.LittleEndian.Uint64(buf[8:])),
+			MaxValue: int64(binary.LittleEndian.Uint64(buf[16:])),
+		},
+		Bitmaps: make([][]byte, slices+1),
+		Offsets: make([]int64, slices+1),
+	}
+	sizes := buf[FrozenBSIHeaderSize:]
+	offset := FrozenBSIHeaderSize + 8*(slices+1)
+	for i := uint64(0); i <= slices; i++ {
+		offset += frozenBSIPadding(offset)
+		size := binary.LittleEndian.Uint64(sizes[8*i:])
+		if offset > uint64(len(buf)) || size > uint64(len(buf))-offset {
+			return nil, &FrozenBSIFailure{Problem: FrozenBSITruncated, Offset: len(buf), Bitmap: int(i)}
+		}
+		f.Bitmaps[i] = buf[offset : offset+size]
+		f.Offsets[i] = int64(offset)
+		offset += size
+	}
+
+	if offset != uint64(len(buf)) {
+		return nil, &FrozenBSIFailure{Problem: FrozenBSITrailingData, Offset: int(offset), Bitmap: -1}
+	}
+	return f, nil
+}
@@ -30,3 +30,29 @@ func (si *shortIterator) nextMany64(hs uint64, buf []uint64) int {
 	si.loc = l
 	return n
 }
+
+func (si *reverseIterator) nextMany(hs uint32, buf []uint32) int {
+	n := 0
+	l := si.loc
+	s := si.slice
+	for n < len(buf) && l >= 0 {
+		buf[n] = uint32(s[l]) | hs
+		l--
+		n++
+	}
+	si.loc = l
+	return n
+}
+
+func (si *reverseIterator) nextMany64(hs uint64, buf []uint64) int {
+	n := 0
+	l := si.loc
+	s := si.slice
+	for n < len(buf) && l >= 0 {
+		buf[n] = uint64(s[l]) | hs
+		l--
+		n++
+	}
+	si.loc = l
+	return n
+}
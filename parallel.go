@@ -54,7 +54,7 @@ func (h bitmapContainerHeap) Peek() bitmapContainerKey {
 func (h *bitmapContainerHeap) popIncrementing() (key uint16, container container) {
 	k := h.Peek()
 	key = k.key
-	container = k.bitmap.highlowcontainer.containers[k.idx]
+	container = k.bitmap.highlowcontainer.getContainerAtIndex(k.idx)
 
 	newIdx := k.idx + 1
 	if newIdx < k.bitmap.highlowcontainer.size() {
@@ -162,10 +162,9 @@ func appenderRoutine(bitmapChan chan<- *Bitmap, resultChan <-chan keyedContainer
 	}
 	answer := &Bitmap{
 		roaringArray{
-			make([]uint16, 0, expectedKeys),
-			make([]container, 0, expectedKeys),
-			make([]bool, 0, expectedKeys),
-			false,
+			keys:            make([]uint16, 0, expectedKeys),
+			containers:      make([]container, 0, expectedKeys),
+			needCopyOnWrite: make([]bool, 0, expectedKeys),
 		},
 	}
 	for i := range keys {
@@ -364,6 +363,122 @@ func ParOr(parallelism int, bitmaps ...*Bitmap) *Bitmap {
 		return FastOr(bitmaps...)
 	}
 
+	return parAggregateOnRanges(parallelism, lKey, hKey, func(start, last uint16) *roaringArray {
+		ra := lazyOrOnRange(&bitmaps[0].highlowcontainer, &bitmaps[1].highlowcontainer, start, last)
+		for _, b := range bitmaps[2:] {
+			ra = lazyIOrOnRange(ra, &b.highlowcontainer, start, last)
+		}
+
+		for i, c := range ra.containers {
+			ra.containers[i] = repairAfterLazy(c)
+		}
+		return ra
+	})
+}
+
+// ParXor computes the symmetric difference (XOR) of all provided bitmaps in parallel,
+// where the parameter "parallelism" determines how many workers are to be used
+// (if it is set to 0, a default number of workers is chosen)
+// Like ParOr, it splits the keys into ranges, each of them aggregated with FastXor.
+func ParXor(parallelism int, bitmaps ...*Bitmap) *Bitmap {
+	var lKey uint16 = MaxUint16
+	var hKey uint16
+
+	bitmapsFiltered := make([]*Bitmap, 0, len(bitmaps))
+	for _, b := range bitmaps {
+		if !b.IsEmpty() {
+			bitmapsFiltered = append(bitmapsFiltered, b)
+		}
+	}
+	bitmaps = bitmapsFiltered
+
+	for _, b := range bitmaps {
+		lKey = minOfUint16(lKey, b.highlowcontainer.keys[0])
+		hKey = maxOfUint16(hKey, b.highlowcontainer.keys[b.highlowcontainer.size()-1])
+	}
+
+	if len(bitmaps) == 0 {
+		return New()
+	} else if len(bitmaps) == 1 {
+		return bitmaps[0].Clone()
+	} else if lKey == hKey {
+		return FastXor(bitmaps...)
+	}
+
+	return parAggregateOnRanges(parallelism, lKey, hKey, func(start, last uint16) *roaringArray {
+		views := make([]*Bitmap, len(bitmaps))
+		for i, b := range bitmaps {
+			views[i] = parRangeView(&b.highlowcontainer, start, last)
+		}
+		return &FastXor(views...).highlowcontainer
+	})
+}
+
+// ParAndNot computes the values of base that are in none of the subtrahends in parallel,
+// where the parameter "parallelism" determines how many workers are to be used
+// (if it is set to 0, a default number of workers is chosen)
+// Like ParOr, it splits the keys of base into ranges, each of them aggregated with FastAndNot.
+func ParAndNot(parallelism int, base *Bitmap, subtrahends ...*Bitmap) *Bitmap {
+	if base.IsEmpty() {
+		return New()
+	} else if len(subtrahends) == 0 {
+		return base.Clone()
+	}
+
+	lKey := base.highlowcontainer.keys[0]
+	hKey := base.highlowcontainer.keys[base.highlowcontainer.size()-1]
+	if lKey == hKey {
+		return FastAndNot(base, subtrahends...)
+	}
+
+	return parAggregateOnRanges(parallelism, lKey, hKey, func(start, last uint16) *roaringArray {
+		views := make([]*Bitmap, len(subtrahends))
+		for i, b := range subtrahends {
+			views[i] = parRangeView(&b.highlowcontainer, start, last)
+		}
+		return &FastAndNot(parRangeView(&base.highlowcontainer, start, last), views...).highlowcontainer
+	})
+}
+
+// parRangeView returns a bitmap sharing the containers of ra whose keys are
+// between start and last, inclusive. The view must not be modified.
+func parRangeView(ra *roaringArray, start, last uint16) *Bitmap {
+	lo := ra.getIndex(start)
+	if lo < 0 {
+		lo = -lo - 1
+	}
+	hi := ra.getIndex(last)
+	if hi < 0 {
+		hi = -hi - 1
+	} else {
+		hi++
+	}
+	if hi < lo {
+		// the last chunks may start after the last key
+		hi = lo
+	}
+
+	view := &Bitmap{
+		roaringArray{
+			keys:            ra.keys[lo:hi:hi],
+			containers:      ra.containers[lo:hi:hi],
+			needCopyOnWrite: ra.needCopyOnWrite[lo:hi:hi],
+			copyOnWrite:     ra.copyOnWrite,
+		},
+	}
+	// containers appended after a lazy read have no entry in ra.lazy
+	if lazyHi := minOfInt(hi, len(ra.lazy)); lo < lazyHi {
+		view.highlowcontainer.lazy = ra.lazy[lo:lazyHi:lazyHi]
+	}
+	return view
+}
+
+// parAggregateOnRanges splits the keys from lKey to hKey into chunks, calls
+// aggregate on each chunk in parallel and concatenates the results, which
+// must only hold keys within their chunk.
+func parAggregateOnRanges(parallelism int, lKey, hKey uint16, aggregate func(start, last uint16) *roaringArray) *Bitmap {
+	keyRange := int(hKey) - int(lKey) + 1
+
 	if parallelism == 0 {
 		parallelism = defaultWorkerCount
 	}
@@ -388,23 +503,14 @@ func ParOr(parallelism int, bitmaps ...*Bitmap) *Bitmap {
 	chunkSpecChan := make(chan parChunkSpec, minOfInt(maxOfInt(64, 2*parallelism), int(chunkCount)))
 	chunkChan := make(chan parChunk, minOfInt(32, int(chunkCount)))
 
-	orFunc := func() {
+	aggregateFunc := func() {
 		for spec := range chunkSpecChan {
-			ra := lazyOrOnRange(&bitmaps[0].highlowcontainer, &bitmaps[1].highlowcontainer, spec.start, spec.end)
-			for _, b := range bitmaps[2:] {
-				ra = lazyIOrOnRange(ra, &b.highlowcontainer, spec.start, spec.end)
-			}
-
-			for i, c := range ra.containers {
-				ra.containers[i] = repairAfterLazy(c)
-			}
-
-			chunkChan <- parChunk{ra, spec.idx}
+			chunkChan <- parChunk{aggregate(spec.start, spec.end), spec.idx}
 		}
 	}
 
 	for i := 0; i < parallelism; i++ {
-		go orFunc()
+		go aggregateFunc()
 	}
 
 	go func() {
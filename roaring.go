@@ -185,6 +185,7 @@ func (rb *Bitmap) FromDense(bitmap []uint64, doCopy bool) {
 // Useful to convert a roaring bitmap to a format that can be used by other libraries
 // like https://github.com/bits-and-blooms/bitset or https://github.com/kelindar/bitmap
 func (rb *Bitmap) WriteDenseTo(bitmap []uint64) {
+	rb.highlowcontainer.materialize()
 	for i, ct := range rb.highlowcontainer.containers {
 		hb := uint32(rb.highlowcontainer.keys[i]) << 16
 
@@ -237,6 +238,8 @@ func (rb *Bitmap) Checksum() uint64 {
 		prime  = 1099511628211
 	)
 
+	rb.highlowcontainer.materialize()
+
 	var bytes []byte
 
 	hash := uint64(offset)
@@ -306,6 +309,39 @@ func (rb *Bitmap) FromUnsafeBytes(data []byte, cookieHeader ...byte) (p int64, e
 // we add cookieHeader to accept the 4-byte data that has been read in roaring64.ReadFrom.
 // It is not necessary to pass cookieHeader when call roaring.ReadFrom to read the roaring32 data directly.
 func (rb *Bitmap) ReadFrom(reader io.Reader, cookieHeader ...byte) (p int64, err error) {
+	return rb.readFrom(reader, false, nil, cookieHeader...)
+}
+
+// ReadFromLazy reads a serialized version of this bitmap from stream, like ReadFrom,
+// but only parses the header upfront: the offset table of the header locates every
+// container, the data of the containers is read at once, and each container is
+// decoded on first access. GetCardinality is answered from the cardinalities
+// stored in the header, without decoding any container.
+// This is useful when many large bitmaps are loaded but only few of their
+// containers are ever accessed. Bitmaps with run containers and fewer than 4
+// containers have no offset table: they are decoded upfront, as by ReadFrom.
+//
+// Since containers are decoded when they are read, a lazily read bitmap is not
+// safe for concurrent use, even by readers only, until every container has been
+// decoded (which, e.g., Clone, Validate or WriteTo do).
+func (rb *Bitmap) ReadFromLazy(reader io.Reader, cookieHeader ...byte) (p int64, err error) {
+	return rb.readFrom(reader, true, nil, cookieHeader...)
+}
+
+// ReadFromWithOptions reads a serialized version of this bitmap from stream, like ReadFrom,
+// but does not trust the input: the number of containers, the number of values and the
+// size of the decoded bitmap are checked against opts before anything is allocated for them,
+// and every container is checked as it is decoded (sorted keys and values, non-overlapping
+// runs, cardinalities matching the header).
+// The returned *DeserializationError wraps one of ErrContainerLimit, ErrCardinalityLimit, ErrAllocationLimit,
+// ErrKeySortOrder, ErrArrayIncorrectSort, ErrCardinalityMismatch or one of the ErrRun errors
+// when the input is rejected, so that it can be tested with errors.Is.
+// The bitmap must not be used after an error.
+func (rb *Bitmap) ReadFromWithOptions(reader io.Reader, opts ReadOptions, cookieHeader ...byte) (p int64, err error) {
+	return rb.readFrom(reader, false, &opts, cookieHeader...)
+}
+
+func (rb *Bitmap) readFrom(reader io.Reader, lazy bool, opts *ReadOptions, cookieHeader ...byte) (p int64, err error) {
 	stream, ok := reader.(internal.ByteInput)
 	if !ok {
 		byteInputAdapter := internal.ByteInputAdapterPool.Get().(*internal.ByteInputAdapter)
@@ -313,7 +349,7 @@ func (rb *Bitmap) ReadFrom(reader io.Reader, cookieHeader ...byte) (p int64, err
 		stream = byteInputAdapter
 	}
 
-	p, err = rb.highlowcontainer.readFrom(stream, cookieHeader...)
+	p, err = rb.highlowcontainer.readFrom(stream, lazy, opts, cookieHeader...)
 
 	if !ok {
 		internal.ByteInputAdapterPool.Put(stream.(*internal.ByteInputAdapter))
@@ -363,7 +399,7 @@ func (rb *Bitmap) FromBuffer(buf []byte) (p int64, err error) {
 	stream := internal.ByteBufferPool.Get().(*internal.ByteBuffer)
 	stream.Reset(buf)
 
-	p, err = rb.highlowcontainer.readFrom(stream)
+	p, err = rb.highlowcontainer.readFrom(stream, false, nil)
 	internal.ByteBufferPool.Put(stream)
 
 	return
@@ -433,9 +469,20 @@ func (rb *Bitmap) ToArray() []uint32 {
 	return array
 }
 
+// ToIntervals creates a new slice containing the maximal runs of consecutive values in the bitmap, in sorted order
+func (rb *Bitmap) ToIntervals() []Interval {
+	var intervals []Interval
+	rb.IterateRanges(func(start, endExclusive uint64) bool {
+		intervals = append(intervals, Interval{start, endExclusive})
+		return true
+	})
+	return intervals
+}
+
 // GetSizeInBytes estimates the memory usage of the Bitmap. Note that this
 // might differ slightly from the amount of bytes required for persistent storage
 func (rb *Bitmap) GetSizeInBytes() uint64 {
+	rb.highlowcontainer.materialize()
 	size := uint64(8)
 	for _, c := range rb.highlowcontainer.containers {
 		size += uint64(2) + uint64(c.getSizeInBytes())
@@ -547,8 +594,9 @@ func (ii *intIterator) PeekNext() uint32 {
 func (ii *intIterator) AdvanceIfNeeded(minval uint32) {
 	to := minval & 0xffff0000
 
-	for ii.HasNext() && ii.hs < to {
-		ii.pos++
+	if ii.HasNext() && ii.hs < to {
+		// gallop over the keys to the first container which may hold minval
+		ii.pos = ii.highlowcontainer.advanceUntil(highbits(minval), ii.pos)
 		ii.init()
 	}
 
@@ -574,10 +622,20 @@ func (ii *intIterator) Initialize(a *Bitmap) {
 	ii.init()
 }
 
+// IntReversePeekable allows you to look at the next value without advancing and
+// retreat as long as the next value is larger than maxval
+type IntReversePeekable interface {
+	IntIterable
+	// PeekNext peeks the next value without advancing the iterator
+	PeekNext() uint32
+	// RetreatIfNeeded retreats as long as the next value is larger than maxval
+	RetreatIfNeeded(maxval uint32)
+}
+
 type intReverseIterator struct {
 	pos              int
 	hs               uint32
-	iter             shortIterable
+	iter             shortReversePeekable
 	highlowcontainer *roaringArray
 
 	shortIter  reverseIterator
@@ -631,6 +689,30 @@ func (ii *intReverseIterator) Next() uint32 {
 	return x
 }
 
+// PeekNext peeks the next value without advancing the iterator
+func (ii *intReverseIterator) PeekNext() uint32 {
+	return uint32(ii.iter.peekNext()&maxLowBit) | ii.hs
+}
+
+// RetreatIfNeeded retreats as long as the next value is larger than maxval
+func (ii *intReverseIterator) RetreatIfNeeded(maxval uint32) {
+	to := maxval & 0xffff0000
+
+	for ii.HasNext() && ii.hs > to {
+		ii.pos--
+		ii.init()
+	}
+
+	if ii.HasNext() && ii.hs == to {
+		ii.iter.retreatIfNeeded(lowbits(maxval))
+
+		if !ii.iter.hasNext() {
+			ii.pos--
+			ii.init()
+		}
+	}
+}
+
 // IntReverseIterator is meant to allow you to iterate through the values of a bitmap, see Initialize(a *Bitmap)
 type IntReverseIterator = intReverseIterator
 
@@ -643,6 +725,114 @@ func (ii *intReverseIterator) Initialize(a *Bitmap) {
 	ii.init()
 }
 
+// intRangeIterator is an intIterator stopping at the first value not smaller than max
+type intRangeIterator struct {
+	intIterator
+	max uint64
+}
+
+// HasNext returns true if there are more integers to iterate over
+func (ii *intRangeIterator) HasNext() bool {
+	return ii.intIterator.HasNext() && uint64(ii.PeekNext()) < ii.max
+}
+
+// intReverseRangeIterator is an intReverseIterator stopping at the first value smaller than min
+type intReverseRangeIterator struct {
+	intReverseIterator
+	min uint64
+}
+
+// HasNext returns true if there are more integers to iterate over
+func (ii *intReverseRangeIterator) HasNext() bool {
+	return ii.intReverseIterator.HasNext() && uint64(ii.PeekNext()) >= ii.min
+}
+
+// Interval is the half-open range of values [Start, End), see ToIntervals and FromIntervals.
+type Interval struct {
+	Start uint64
+	End   uint64
+}
+
+// RangeIterator allows you to iterate over the maximal runs of consecutive values in a Bitmap
+type RangeIterator interface {
+	HasNext() bool
+	// Next returns the next run as the half-open interval [start, endExclusive)
+	Next() (start, endExclusive uint64)
+}
+
+type rangeIterator struct {
+	pos              int
+	highlowcontainer *roaringArray
+
+	// runs of the last container read, runs[runPos:] are still to be merged
+	runs   []Interval
+	runPos int
+
+	// the next merged run, and the run read after it
+	hasNext    bool
+	start, end uint64
+	lookahead  Interval
+	hasLook    bool
+}
+
+// HasNext returns true if there are more runs to iterate over
+func (ri *rangeIterator) HasNext() bool {
+	return ri.hasNext
+}
+
+// Next returns the next run as the half-open interval [start, endExclusive)
+func (ri *rangeIterator) Next() (start, endExclusive uint64) {
+	start, endExclusive = ri.start, ri.end
+	ri.advance()
+	return
+}
+
+// read returns the next run of a container, runs are not merged across containers.
+func (ri *rangeIterator) read() (Interval, bool) {
+	for ri.runPos == len(ri.runs) {
+		if ri.pos >= ri.highlowcontainer.size() {
+			return Interval{}, false
+		}
+		hs := uint64(ri.highlowcontainer.getKeyAtIndex(ri.pos)) << 16
+		ri.runs = ri.runs[:0]
+		ri.runPos = 0
+		ri.highlowcontainer.getContainerAtIndex(ri.pos).iterateRuns(func(start, endx int) bool {
+			ri.runs = append(ri.runs, Interval{hs + uint64(start), hs + uint64(endx)})
+			return true
+		})
+		ri.pos++
+	}
+	ri.runPos++
+	return ri.runs[ri.runPos-1], true
+}
+
+func (ri *rangeIterator) advance() {
+	ri.hasNext = ri.hasLook
+	if !ri.hasNext {
+		return
+	}
+	ri.start, ri.end = ri.lookahead.Start, ri.lookahead.End
+	for {
+		ri.lookahead, ri.hasLook = ri.read()
+		if !ri.hasLook || ri.lookahead.Start != ri.end {
+			return
+		}
+		ri.end = ri.lookahead.End
+	}
+}
+
+// Initialize configures the existing iterator so that it can iterate through the runs of
+// the provided bitmap.
+// The iteration results are undefined if the bitmap is modified (e.g., with Add or Remove).
+func (ri *rangeIterator) Initialize(a *Bitmap) {
+	ri.pos = 0
+	ri.highlowcontainer = &a.highlowcontainer
+	ri.runs = ri.runs[:0]
+	ri.runPos = 0
+	ri.lookahead, ri.hasLook = ri.read()
+	ri.advance()
+}
+
 // ManyIntIterable allows you to iterate over the values in a Bitmap
 type ManyIntIterable interface {
 	// NextMany fills buf up with values, returns how many values were returned
@@ -730,6 +920,96 @@ func (ii *manyIntIterator) Initialize(a *Bitmap) {
 	ii.init()
 }
 
+type reverseManyIntIterator struct {
+	pos              int
+	hs               uint32
+	iter             manyIterable
+	highlowcontainer *roaringArray
+
+	shortIter  reverseIterator
+	runIter    runReverseIterator16
+	bitmapIter reverseBitmapContainerManyIterator
+}
+
+func (ii *reverseManyIntIterator) init() {
+	if ii.pos >= 0 {
+		ii.hs = uint32(ii.highlowcontainer.getKeyAtIndex(ii.pos)) << 16
+		c := ii.highlowcontainer.getContainerAtIndex(ii.pos)
+		switch t := c.(type) {
+		case *arrayContainer:
+			ii.shortIter = reverseIterator{t.content, len(t.content) - 1}
+			ii.iter = &ii.shortIter
+		case *runContainer16:
+			index := int(len(t.iv)) - 1
+			pos := uint16(0)
+
+			if index >= 0 {
+				pos = t.iv[index].length
+			}
+
+			ii.runIter = runReverseIterator16{rc: t, curIndex: index, curPosInIndex: pos}
+			ii.iter = &ii.runIter
+		case *bitmapContainer:
+			ii.bitmapIter = reverseBitmapContainerManyIterator{t, len(t.bitmap), 0}
+			ii.iter = &ii.bitmapIter
+		}
+	} else {
+		ii.iter = nil
+	}
+}
+
+// NextMany fills buf up with values in decreasing order, returns how many values were returned
+func (ii *reverseManyIntIterator) NextMany(buf []uint32) int {
+	n := 0
+	for n < len(buf) {
+		if ii.iter == nil {
+			break
+		}
+		moreN := ii.iter.nextMany(ii.hs, buf[n:])
+		n += moreN
+		if moreN == 0 {
+			ii.pos = ii.pos - 1
+			ii.init()
+		}
+	}
+
+	return n
+}
+
+// NextMany64 fills up buf with 64 bit values in decreasing order, uses hs as a mask (OR),
+// returns how many values were returned
+func (ii *reverseManyIntIterator) NextMany64(hs64 uint64, buf []uint64) int {
+	n := 0
+	for n < len(buf) {
+		if ii.iter == nil {
+			break
+		}
+
+		hs := uint64(ii.hs) | hs64
+		moreN := ii.iter.nextMany64(hs, buf[n:])
+		n += moreN
+		if moreN == 0 {
+			ii.pos = ii.pos - 1
+			ii.init()
+		}
+	}
+
+	return n
+}
+
+// ReverseManyIntIterator is meant to allow you to iterate through the values of a bitmap
+// in decreasing order, see Initialize(a *Bitmap)
+type ReverseManyIntIterator = reverseManyIntIterator
+
+// Initialize configures the existing iterator so that it can iterate through the values of
+// the provided bitmap.
+// The iteration results are undefined if the bitmap is modified (e.g., with Add or Remove).
+func (ii *reverseManyIntIterator) Initialize(a *Bitmap) {
+	ii.highlowcontainer = &a.highlowcontainer
+	ii.pos = a.highlowcontainer.size() - 1
+	ii.init()
+}
+
 // String creates a string representation of the Bitmap
 func (rb *Bitmap) String() string {
 	// inspired by https://github.com/fzandona/goroar/
@@ -788,6 +1068,36 @@ func (rb *Bitmap) Iterate(cb func(x uint32) bool) {
 	}
 }
 
+// IterateRanges iterates over the maximal runs of consecutive values in the bitmap, in sorted order,
+// calling the given callback with each run as the half-open interval [start, endExclusive).
+// Runs spanning several containers are reported once. If the callback returns false, the iteration is halted.
+// The iteration results are undefined if the bitmap is modified (e.g., with Add or Remove).
+func (rb *Bitmap) IterateRanges(cb func(start, endExclusive uint64) bool) {
+	var pending Interval
+	hasPending := false
+	for i := 0; i < rb.highlowcontainer.size(); i++ {
+		hs := uint64(rb.highlowcontainer.getKeyAtIndex(i)) << 16
+		shouldContinue := rb.highlowcontainer.getContainerAtIndex(i).iterateRuns(func(start, endx int) bool {
+			run := Interval{hs + uint64(start), hs + uint64(endx)}
+			if hasPending && pending.End == run.Start {
+				pending.End = run.End
+				return true
+			}
+			if hasPending && !cb(pending.Start, pending.End) {
+				return false
+			}
+			pending, hasPending = run, true
+			return true
+		})
+		if !shouldContinue {
+			return
+		}
+	}
+	if hasPending {
+		cb(pending.Start, pending.End)
+	}
+}
+
 // Iterator creates a new IntPeekable to iterate over the integers contained in the bitmap, in sorted order;
 // the iterator becomes invalid if the bitmap is modified (e.g., with Add or Remove).
 func (rb *Bitmap) Iterator() IntPeekable {
@@ -804,6 +1114,37 @@ func (rb *Bitmap) ReverseIterator() IntIterable {
 	return p
 }
 
+// IteratorRange creates a new IntPeekable to iterate over the integers of the bitmap within [min, max), in sorted order;
+// the iterator becomes invalid if the bitmap is modified (e.g., with Add or Remove).
+func (rb *Bitmap) IteratorRange(min, max uint64) IntPeekable {
+	p := new(intRangeIterator)
+	p.Initialize(rb)
+	if min > MaxUint32 || min >= max {
+		return p
+	}
+	p.max = max
+	p.AdvanceIfNeeded(uint32(min))
+	return p
+}
+
+// ReverseIteratorRange creates a new IntReversePeekable to iterate over the integers of the bitmap
+// within [min, max), in decreasing order;
+// the iterator becomes invalid if the bitmap is modified (e.g., with Add or Remove).
+func (rb *Bitmap) ReverseIteratorRange(min, max uint64) IntReversePeekable {
+	p := new(intReverseRangeIterator)
+	p.Initialize(rb)
+	if min > MaxUint32 || min >= max {
+		p.min = MaxRange
+		return p
+	}
+	if max > MaxRange {
+		max = MaxRange
+	}
+	p.min = min
+	p.RetreatIfNeeded(uint32(max - 1))
+	return p
+}
+
 // ManyIterator creates a new ManyIntIterable to iterate over the integers contained in the bitmap, in sorted order;
 // the iterator becomes invalid if the bitmap is modified (e.g., with Add or Remove).
 func (rb *Bitmap) ManyIterator() ManyIntIterable {
@@ -812,6 +1153,22 @@ func (rb *Bitmap) ManyIterator() ManyIntIterable {
 	return p
 }
 
+// ReverseManyIterator creates a new ManyIntIterable to iterate over the integers contained in the bitmap,
+// in decreasing order; the iterator becomes invalid if the bitmap is modified (e.g., with Add or Remove).
+func (rb *Bitmap) ReverseManyIterator() ManyIntIterable {
+	p := new(reverseManyIntIterator)
+	p.Initialize(rb)
+	return p
+}
+
+// Ranges creates a new RangeIterator to iterate over the maximal runs of consecutive values in the bitmap,
+// in sorted order; the iterator becomes invalid if the bitmap is modified (e.g., with Add or Remove).
+func (rb *Bitmap) Ranges() RangeIterator {
+	p := new(rangeIterator)
+	p.Initialize(rb)
+	return p
+}
+
 // Clone creates a copy of the Bitmap
 func (rb *Bitmap) Clone() *Bitmap {
 	ptr := new(Bitmap)
@@ -824,7 +1181,7 @@ func (rb *Bitmap) Minimum() uint32 {
 	if len(rb.highlowcontainer.containers) == 0 {
 		panic("Empty bitmap")
 	}
-	return uint32(rb.highlowcontainer.containers[0].minimum()) | (uint32(rb.highlowcontainer.keys[0]) << 16)
+	return uint32(rb.highlowcontainer.getContainerAtIndex(0).minimum()) | (uint32(rb.highlowcontainer.keys[0]) << 16)
 }
 
 // Maximum get the largest value stored in this roaring bitmap, assumes that it is not empty
@@ -833,7 +1190,7 @@ func (rb *Bitmap) Maximum() uint32 {
 		panic("Empty bitmap")
 	}
 	lastindex := len(rb.highlowcontainer.containers) - 1
-	return uint32(rb.highlowcontainer.containers[lastindex].maximum()) | (uint32(rb.highlowcontainer.keys[lastindex]) << 16)
+	return uint32(rb.highlowcontainer.getContainerAtIndex(lastindex).maximum()) | (uint32(rb.highlowcontainer.keys[lastindex]) << 16)
 }
 
 // Contains returns true if the integer is contained in the bitmap
@@ -1026,8 +1383,8 @@ func (rb *Bitmap) IsEmpty() bool {
 // GetCardinality returns the number of integers contained in the bitmap
 func (rb *Bitmap) GetCardinality() uint64 {
 	size := uint64(0)
-	for _, c := range rb.highlowcontainer.containers {
-		size += uint64(c.getCardinality())
+	for i := range rb.highlowcontainer.containers {
+		size += uint64(rb.highlowcontainer.getCardinalityAtIndex(i))
 	}
 	return size
 }
@@ -1052,6 +1409,89 @@ func (rb *Bitmap) Rank(x uint32) uint64 {
 	return size
 }
 
+// RangeCardinality returns the number of integers in the bitmap which are in the
+// range [rangeStart, rangeEnd). Containers entirely within the range are counted
+// from their cardinality: only the containers at the boundaries are inspected.
+func (rb *Bitmap) RangeCardinality(rangeStart, rangeEnd uint64) uint64 {
+	if rangeEnd > MaxUint32+1 {
+		rangeEnd = MaxUint32 + 1
+	}
+	if rangeStart >= rangeEnd {
+		return 0
+	}
+
+	hbStart := highbits(uint32(rangeStart))
+	lbStart := int(lowbits(uint32(rangeStart)))
+	hbLast := highbits(uint32(rangeEnd - 1))
+	lbEnd := int(lowbits(uint32(rangeEnd-1))) + 1
+
+	answer := uint64(0)
+	i := rb.highlowcontainer.getIndex(hbStart)
+	if i < 0 {
+		i = -i - 1
+	}
+	for ; i < rb.highlowcontainer.size(); i++ {
+		key := rb.highlowcontainer.getKeyAtIndex(i)
+		if key > hbLast {
+			break
+		}
+		start, end := 0, MaxUint16+1
+		if key == hbStart {
+			start = lbStart
+		}
+		if key == hbLast {
+			end = lbEnd
+		}
+		if start == 0 && end == MaxUint16+1 {
+			answer += uint64(rb.highlowcontainer.getCardinalityAtIndex(i))
+		} else {
+			answer += uint64(cardinalityInRange(rb.highlowcontainer.getContainerAtIndex(i), start, end))
+		}
+	}
+	return answer
+}
+
+// ContainsRange returns true if every integer in the range [rangeStart, rangeEnd)
+// is in the bitmap. An empty range is always contained.
+func (rb *Bitmap) ContainsRange(rangeStart, rangeEnd uint64) bool {
+	if rangeStart >= rangeEnd {
+		return true
+	}
+	if rangeEnd > MaxUint32+1 {
+		return false
+	}
+
+	hbStart := highbits(uint32(rangeStart))
+	lbStart := int(lowbits(uint32(rangeStart)))
+	hbLast := highbits(uint32(rangeEnd - 1))
+	lbEnd := int(lowbits(uint32(rangeEnd-1))) + 1
+
+	// every key between hbStart and hbLast must be present
+	first := rb.highlowcontainer.getIndex(hbStart)
+	last := first + int(hbLast-hbStart)
+	if first < 0 || last >= rb.highlowcontainer.size() || rb.highlowcontainer.getKeyAtIndex(last) != hbLast {
+		return false
+	}
+
+	for i := first; i <= last; i++ {
+		start, end := 0, MaxUint16+1
+		if i == first {
+			start = lbStart
+		}
+		if i == last {
+			end = lbEnd
+		}
+		if start == 0 && end == MaxUint16+1 {
+			if rb.highlowcontainer.getCardinalityAtIndex(i) != MaxUint16+1 {
+				return false
+			}
+		} else if cardinalityInRange(rb.highlowcontainer.getContainerAtIndex(i), start, end) != end-start {
+			return false
+		}
+	}
+	return true
+}
+
 // Select returns the xth integer in the bitmap. If you pass 0, you get
 // the smallest element. Note that this function differs in convention from
 // the Rank function which returns 1 on the smallest value.
@@ -1135,22 +1575,21 @@ main:
 
 			for {
 				if s1 < s2 {
-					answer += uint64(rb.highlowcontainer.getContainerAtIndex(pos1).getCardinality())
+					answer += uint64(rb.highlowcontainer.getCardinalityAtIndex(pos1))
 					pos1++
 					if pos1 == length1 {
 						break main
 					}
 					s1 = rb.highlowcontainer.getKeyAtIndex(pos1)
 				} else if s1 > s2 {
-					answer += uint64(x2.highlowcontainer.getContainerAtIndex(pos2).getCardinality())
+					answer += uint64(x2.highlowcontainer.getCardinalityAtIndex(pos2))
 					pos2++
 					if pos2 == length2 {
 						break main
 					}
 					s2 = x2.highlowcontainer.getKeyAtIndex(pos2)
 				} else {
-					// TODO: could be faster if we did not have to materialize the container
-					answer += uint64(rb.highlowcontainer.getContainerAtIndex(pos1).or(x2.highlowcontainer.getContainerAtIndex(pos2)).getCardinality())
+					answer += uint64(rb.highlowcontainer.getContainerAtIndex(pos1).orCardinality(x2.highlowcontainer.getContainerAtIndex(pos2)))
 					pos1++
 					pos2++
 					if (pos1 == length1) || (pos2 == length2) {
@@ -1165,10 +1604,10 @@ main:
 		}
 	}
 	for ; pos1 < length1; pos1++ {
-		answer += uint64(rb.highlowcontainer.getContainerAtIndex(pos1).getCardinality())
+		answer += uint64(rb.highlowcontainer.getCardinalityAtIndex(pos1))
 	}
 	for ; pos2 < length2; pos2++ {
-		answer += uint64(x2.highlowcontainer.getContainerAtIndex(pos2).getCardinality())
+		answer += uint64(x2.highlowcontainer.getCardinalityAtIndex(pos2))
 	}
 	return answer
 }
@@ -1219,6 +1658,55 @@ main:
 	return answer
 }
 
+// AndNotCardinality returns the cardinality of the difference between two bitmaps, bitmaps are not modified
+func (rb *Bitmap) AndNotCardinality(x2 *Bitmap) uint64 {
+	return rb.GetCardinality() - rb.AndCardinality(x2)
+}
+
+// XorCardinality returns the cardinality of the symmetric difference between two bitmaps, bitmaps are not modified
+func (rb *Bitmap) XorCardinality(x2 *Bitmap) uint64 {
+	return rb.GetCardinality() + x2.GetCardinality() - 2*rb.AndCardinality(x2)
+}
+
+// Jaccard returns the Jaccard index of two bitmaps, that is the cardinality
+// of their intersection divided by the cardinality of their union. It returns
+// 0 when both bitmaps are empty. Bitmaps are not modified.
+func (rb *Bitmap) Jaccard(x2 *Bitmap) float64 {
+	inter := rb.AndCardinality(x2)
+	union := rb.GetCardinality() + x2.GetCardinality() - inter
+	if union == 0 {
+		return 0
+	}
+	return float64(inter) / float64(union)
+}
+
+// Dice returns the Sørensen–Dice coefficient of two bitmaps, that is twice
+// the cardinality of their intersection divided by the sum of their
+// cardinalities. It returns 0 when both bitmaps are empty. Bitmaps are not
+// modified.
+func (rb *Bitmap) Dice(x2 *Bitmap) float64 {
+	total := rb.GetCardinality() + x2.GetCardinality()
+	if total == 0 {
+		return 0
+	}
+	return 2 * float64(rb.AndCardinality(x2)) / float64(total)
+}
+
+// OverlapCoefficient returns the overlap coefficient of two bitmaps, that is
+// the cardinality of their intersection divided by the smaller of their
+// cardinalities. It returns 0 when either bitmap is empty. Bitmaps are not
+// modified.
+func (rb *Bitmap) OverlapCoefficient(x2 *Bitmap) float64 {
+	smaller := rb.GetCardinality()
+	if card := x2.GetCardinality(); card < smaller {
+		smaller = card
+	}
+	if smaller == 0 {
+		return 0
+	}
+	return float64(rb.AndCardinality(x2)) / float64(smaller)
+}
+
 // IntersectsWithInterval checks whether a bitmap 'rb' and an open interval '[x,y)' intersect.
 func (rb *Bitmap) IntersectsWithInterval(x, y uint64) bool {
 	if x >= y {
@@ -1288,6 +1776,143 @@ main:
 	return false
 }
 
+// IsSubsetOf returns true if every value of the current bitmap is also in
+// other, bitmaps are not modified
+func (rb *Bitmap) IsSubsetOf(other *Bitmap) bool {
+	length1 := rb.highlowcontainer.size()
+	length2 := other.highlowcontainer.size()
+	if length1 > length2 {
+		return false
+	}
+
+	pos2 := -1
+	for pos1 := 0; pos1 < length1; pos1++ {
+		key := rb.highlowcontainer.getKeyAtIndex(pos1)
+		pos2 = other.highlowcontainer.advanceUntil(key, pos2)
+		if pos2 == length2 || other.highlowcontainer.getKeyAtIndex(pos2) != key {
+			return false
+		}
+		card := rb.highlowcontainer.getCardinalityAtIndex(pos1)
+		if card > other.highlowcontainer.getCardinalityAtIndex(pos2) {
+			return false
+		}
+		c1 := rb.highlowcontainer.getContainerAtIndex(pos1)
+		c2 := other.highlowcontainer.getContainerAtIndex(pos2)
+		if c1.andCardinality(c2) != card {
+			return false
+		}
+	}
+	return true
+}
+
+// IsStrictSubsetOf returns true if the current bitmap is a subset of other
+// and other contains at least one value that is not in the current bitmap,
+// bitmaps are not modified
+func (rb *Bitmap) IsStrictSubsetOf(other *Bitmap) bool {
+	return rb.IsSubsetOf(other) && rb.GetCardinality() < other.GetCardinality()
+}
+
+// SetRelation describes how two bitmaps relate to each other as sets, see Relation.
+type SetRelation int
+
+const (
+	// Disjoint means that the bitmaps have no value in common.
+	Disjoint SetRelation = iota
+	// Overlapping means that the bitmaps have values in common, but neither
+	// contains the other.
+	Overlapping
+	// Subset means that the first bitmap is a strict subset of the second.
+	Subset
+	// Superset means that the first bitmap is a strict superset of the second.
+	Superset
+	// Equal means that the bitmaps contain the same values.
+	Equal
+)
+
+func (r SetRelation) String() string {
+	switch r {
+	case Disjoint:
+		return "disjoint"
+	case Overlapping:
+		return "overlapping"
+	case Subset:
+		return "subset"
+	case Superset:
+		return "superset"
+	case Equal:
+		return "equal"
+	}
+	return fmt.Sprintf("SetRelation(%d)", int(r))
+}
+
+// Relation returns the set relation between a and b, bitmaps are not modified.
+// Containment takes precedence over disjointness: an empty bitmap is a
+// Subset of any non-empty bitmap, and two empty bitmaps are Equal.
+// The bitmaps are compared container by container, and the comparison
+// stops as soon as they are known to be Overlapping.
+func Relation(a, b *Bitmap) SetRelation {
+	pos1 := 0
+	pos2 := 0
+	length1 := a.highlowcontainer.size()
+	length2 := b.highlowcontainer.size()
+
+	aInB, bInA, intersects := true, true, false
+	for pos1 < length1 && pos2 < length2 {
+		s1 := a.highlowcontainer.getKeyAtIndex(pos1)
+		s2 := b.highlowcontainer.getKeyAtIndex(pos2)
+		if s1 < s2 {
+			aInB = false
+			pos1++
+		} else if s1 > s2 {
+			bInA = false
+			pos2++
+		} else {
+			c1 := a.highlowcontainer.getContainerAtIndex(pos1)
+			c2 := b.highlowcontainer.getContainerAtIndex(pos2)
+			if !aInB && !bInA {
+				// only an intersection can still change the answer
+				if c1.intersects(c2) {
+					return Overlapping
+				}
+			} else {
+				card := c1.andCardinality(c2)
+				if card > 0 {
+					intersects = true
+				}
+				if card < a.highlowcontainer.getCardinalityAtIndex(pos1) {
+					aInB = false
+				}
+				if card < b.highlowcontainer.getCardinalityAtIndex(pos2) {
+					bInA = false
+				}
+			}
+			pos1++
+			pos2++
+		}
+		if !aInB && !bInA && intersects {
+			return Overlapping
+		}
+	}
+	if pos1 < length1 {
+		aInB = false
+	}
+	if pos2 < length2 {
+		bInA = false
+	}
+
+	switch {
+	case aInB && bInA:
+		return Equal
+	case aInB:
+		return Subset
+	case bInA:
+		return Superset
+	case intersects:
+		return Overlapping
+	}
+	return Disjoint
+}
+
 // Xor computes the symmetric difference between two bitmaps and stores the result in the current bitmap
 func (rb *Bitmap) Xor(x2 *Bitmap) {
 	pos1 := 0
@@ -1644,6 +2269,16 @@ func BitmapOf(dat ...uint32) *Bitmap {
 	return ans
 }
 
+// FromIntervals generates a new bitmap holding the values of all the given intervals,
+// which may overlap and need not be sorted
+func FromIntervals(intervals ...Interval) *Bitmap {
+	ans := NewBitmap()
+	for _, iv := range intervals {
+		ans.AddRange(iv.Start, iv.End)
+	}
+	return ans
+}
+
 // Flip negates the bits in the given range (i.e., [rangeStart,rangeEnd)), any integer present in this range and in the bitmap is removed,
 // and any integer present in the range and not in the bitmap is added.
 // The function uses 64-bit parameters even though a Bitmap stores 32-bit values because it is allowed and meaningful to use [0,uint64(0x100000000)) as a range
@@ -1981,48 +2616,33 @@ func (rb *Bitmap) PreviousValue(target uint32) int64 {
 func (rb *Bitmap) NextAbsentValue(target uint32) int64 {
 	originalKey := highbits(target)
 	query := lowbits(target)
-	var nextValue int64
-	nextValue = -1
 
-	containerIndex := rb.highlowcontainer.advanceUntil(originalKey, -1)
-	if containerIndex == rb.highlowcontainer.size() {
+	containerIndex := rb.highlowcontainer.getIndex(originalKey)
+	if containerIndex < 0 {
 		// if we are here it means no container found, just return the target
 		return int64(target)
 	}
 
-	containerKey := rb.highlowcontainer.getKeyAtIndex(containerIndex)
-
-	keyspace := uint32(containerKey) << 16
-	if target < keyspace {
-		// target is less than the start of the keyspace start
-		// that means target cannot be in the keyspace
-		return int64(target)
-	}
-
-	container := rb.highlowcontainer.getContainer(containerKey)
-	nextValue = int64(container.nextAbsentValue(query))
+	containerKey := originalKey
 	for {
-		if nextValue != (1 << 16) {
-			return int64(combineLoHi32(uint32(nextValue), keyspace))
+		container := rb.highlowcontainer.getContainerAtIndex(containerIndex)
+		nextValue := container.nextAbsentValue(query)
+		if nextValue >= 0 && nextValue <= MaxUint16 {
+			return int64(combineLoHi32(uint32(nextValue), uint32(containerKey)))
 		}
 
-		if containerIndex == rb.highlowcontainer.size()-1 {
-			val, err := container.safeMaximum()
-			if err == nil {
-				return -1
-			}
-			return int64(val) + 1
+		// the container holds every value from query onwards
+		if containerKey == MaxUint16 {
+			return -1
 		}
 		containerIndex++
-		nextContainerKey := rb.highlowcontainer.getKeyAtIndex(containerIndex)
-		if containerKey < nextContainerKey {
+		if containerIndex == rb.highlowcontainer.size() || rb.highlowcontainer.getKeyAtIndex(containerIndex) != containerKey+1 {
 			// There is a gap between keys
 			// Just increment the current key and shift to get HoB
 			return int64(containerKey+1) << 16
 		}
-		containerKey = nextContainerKey
-		container = rb.highlowcontainer.getContainer(containerKey)
-		nextValue = int64(container.nextAbsentValue(0))
+		containerKey++
+		query = 0
 	}
 }
 
@@ -2033,55 +2653,33 @@ func (rb *Bitmap) NextAbsentValue(target uint32) int64 {
 func (rb *Bitmap) PreviousAbsentValue(target uint32) int64 {
 	originalKey := highbits(target)
 	query := lowbits(target)
-	var prevValue int64
-	prevValue = -1
-
-	containerIndex := rb.highlowcontainer.advanceUntil(originalKey, -1)
-
-	if containerIndex == rb.highlowcontainer.size() {
-		// if we are here it means no container found, just return the target
-		return int64(target)
-	}
 
-	if containerIndex == -1 {
+	containerIndex := rb.highlowcontainer.getIndex(originalKey)
+	if containerIndex < 0 {
 		// if we are here it means no container found, just return the target
 		return int64(target)
 	}
 
-	containerKey := rb.highlowcontainer.getKeyAtIndex(containerIndex)
-	keyspace := uint32(containerKey) << 16
-	if target < keyspace {
-		// target is less than the start of the keyspace start
-		// that means target cannot be in the keyspace
-		return int64(target)
-	}
-
-	container := rb.highlowcontainer.getContainer(containerKey)
-	prevValue = int64(container.previousAbsentValue(query))
+	containerKey := originalKey
 	for {
-		if prevValue != -1 {
-			return int64(combineLoHi32(uint32(prevValue), keyspace))
+		container := rb.highlowcontainer.getContainerAtIndex(containerIndex)
+		prevValue := container.previousAbsentValue(query)
+		if prevValue >= 0 && prevValue <= int(query) {
+			return int64(combineLoHi32(uint32(prevValue), uint32(containerKey)))
 		}
 
-		if containerIndex == 0 {
-			val, err := container.safeMinimum()
-			if err == nil {
-				// OR panic, Java panics
-				return -1
-			}
-			return int64(val) - 1
+		// the container holds every value up to query
+		if containerKey == 0 {
+			return -1
 		}
 		containerIndex--
-		nextContainerKey := rb.highlowcontainer.getKeyAtIndex(containerIndex)
-		if nextContainerKey < containerKey-1 {
+		if containerIndex < 0 || rb.highlowcontainer.getKeyAtIndex(containerIndex) != containerKey-1 {
 			// There is a gap between keys, eg missing container
 			// Just decrement the current key and shift to get HoB of the missing container
 			return (int64(containerKey) << 16) - 1
 		}
-		containerKey = nextContainerKey
-		container = rb.highlowcontainer.getContainer(containerKey)
-		highestPossible16 := (1 << 16) - 1
-		prevValue = int64(container.previousAbsentValue(uint16(highestPossible16)))
+		containerKey--
+		query = MaxUint16
 	}
 }
 
@@ -2110,6 +2708,7 @@ type Statistics struct {
 
 // Stats returns details on container type usage in a Statistics struct.
 func (rb *Bitmap) Stats() Statistics {
+	rb.highlowcontainer.materialize()
 	stats := Statistics{}
 	stats.Containers = uint64(len(rb.highlowcontainer.containers))
 	for _, c := range rb.highlowcontainer.containers {
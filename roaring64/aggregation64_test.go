@@ -5,6 +5,7 @@ package roaring64
 import (
 	"fmt"
 	"github.com/stretchr/testify/assert"
+	"math/rand"
 	"testing"
 )
 
@@ -226,16 +227,18 @@ func assertAggregation(t *testing.T, expected uint64, aggr func(bitmaps ...*Bitm
 
 func TestParAggregations(t *testing.T) {
 	for _, p := range [...]int{0, 1, 2, 4} {
-		//andFunc := func(bitmaps ...*Bitmap) *Bitmap {
-		//	return ParAnd(p, bitmaps...)
-		//}
+		andFunc := func(bitmaps ...*Bitmap) *Bitmap {
+			return ParAnd(p, bitmaps...)
+		}
 		orFunc := func(bitmaps ...*Bitmap) *Bitmap {
 			return ParOr(p, bitmaps...)
 		}
+		xorFunc := func(bitmaps ...*Bitmap) *Bitmap {
+			return ParXor(p, bitmaps...)
+		}
 
 		t.Run(fmt.Sprintf("par%d", p), func(t *testing.T) {
-			//testAggregations(t, andFunc, orFunc, nil)
-			testAggregations(t, nil, orFunc, nil)
+			testAggregations(t, andFunc, orFunc, xorFunc)
 		})
 	}
 }
@@ -249,6 +252,64 @@ func TestParAggregations2(t *testing.T) {
 	})
 }
 
+func TestParHeapAggregations(t *testing.T) {
+	orFunc := func(bitmaps ...*Bitmap) *Bitmap {
+		return ParHeapOr(0, bitmaps...)
+	}
+
+	testAggregations(t, nil, orFunc, nil)
+}
+
 func TestFastAggregations(t *testing.T) {
-	testAggregations(t, nil, FastOr, nil)
+	testAggregations(t, FastAnd, FastOr, nil)
+}
+
+func TestXorAndNotAggregations(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	bitmaps := make([]*Bitmap, 6)
+	for i := range bitmaps {
+		rb := NewBitmap()
+		for j := 0; j < 20000; j++ {
+			rb.Add(uint64(r.Intn(1<<18)) | uint64(r.Intn(8))<<32)
+		}
+		start := uint64(r.Intn(8))<<32 + uint64(r.Intn(1<<18))
+		rb.AddRange(start, start+uint64(r.Intn(1<<18)))
+		if i%2 == 0 {
+			rb.RunOptimize()
+		}
+		bitmaps[i] = rb
+	}
+
+	for n := 0; n <= len(bitmaps); n++ {
+		expected := HeapXor(bitmaps[:n]...)
+		assert.True(t, expected.Equals(FastXor(bitmaps[:n]...)), "xor of %d bitmaps", n)
+		assert.True(t, expected.Equals(ParXor(0, bitmaps[:n]...)), "xor of %d bitmaps", n)
+		assert.True(t, expected.Equals(ParXor(3, bitmaps[:n]...)), "xor of %d bitmaps", n)
+	}
+
+	base := bitmaps[0]
+	for n := 1; n <= len(bitmaps); n++ {
+		expected := AndNot(base, FastOr(bitmaps[1:n]...))
+		assert.True(t, expected.Equals(FastAndNot(base, bitmaps[1:n]...)), "and not %d bitmaps", n-1)
+		assert.True(t, expected.Equals(ParAndNot(0, base, bitmaps[1:n]...)), "and not %d bitmaps", n-1)
+		assert.True(t, expected.Equals(ParAndNot(3, base, bitmaps[1:n]...)), "and not %d bitmaps", n-1)
+	}
+	assert.True(t, FastAndNot(base, base, bitmaps[1]).IsEmpty())
+	assert.True(t, FastAndNot(NewBitmap(), base).IsEmpty())
+	assert.True(t, ParAndNot(0, NewBitmap(), base).IsEmpty())
+
+	// 13 keys in 8 chunks of 2 keys: the last chunks start after the last key
+	sparse, dense := NewBitmap(), NewBitmap()
+	for key := uint64(0); key <= 20; key++ {
+		if key <= 12 {
+			sparse.Add(key << 32)
+		}
+		dense.Add(key<<32 + 1)
+	}
+	assert.True(t, AndNot(sparse, dense).Equals(ParAndNot(2, sparse, dense)))
+	assert.True(t, Xor(sparse, dense).Equals(ParXor(2, sparse, dense)))
+}
+
+func TestHeapAggregations(t *testing.T) {
+	testAggregations(t, nil, HeapOr, HeapXor)
 }
@@ -1,12 +1,15 @@
 package roaring64
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"math/bits"
 	"runtime"
 	"sync"
 	"sync/atomic"
+
+	"github.com/RoaringBitmap/roaring/v2/internal"
 )
 
 const (
@@ -623,6 +626,7 @@ func (b *BSI) FromBitmaps(bms []Bitmap) {
 
 // UnmarshalBinary de-serialize a BSI.  The value at bitData[0] is the EBM.  Other indices are in least to most
 // significance order starting at bitData[1] (bit position 0).
+// Errors are *roaring.DeserializationError whose Container is the index in bitData.
 func (b *BSI) UnmarshalBinary(bitData [][]byte) error {
 
 	for i := 1; i < len(bitData); i++ {
@@ -637,7 +641,7 @@ func (b *BSI) UnmarshalBinary(bitData [][]byte) error {
 			b.bA = append(b.bA, newBm)
 		}
 		if err := b.bA[i-1].UnmarshalBinary(bitData[i]); err != nil {
-			return err
+			return innerError(0, i, fmt.Errorf("reading bit slice index %v: %w", i-1, err))
 		}
 		if b.runOptimized {
 			b.bA[i-1].RunOptimize()
@@ -653,7 +657,7 @@ func (b *BSI) UnmarshalBinary(bitData [][]byte) error {
 		return nil
 	}
 	if err := b.eBM.UnmarshalBinary(bitData[0]); err != nil {
-		return err
+		return innerError(0, 0, fmt.Errorf("reading existence bitmap: %w", err))
 	}
 	if b.runOptimized {
 		b.eBM.RunOptimize()
@@ -661,14 +665,37 @@ func (b *BSI) UnmarshalBinary(bitData [][]byte) error {
 	return nil
 }
 
-// ReadFrom reads a serialized version of this BSI from stream.
+// ReadFrom reads a serialized version of this BSI from stream, as written by WriteTo.
+// The layout is the same as for BitSliceIndexing.BSI:
+//   - a 20-byte header: the cookie "BSIS", MinValue and MaxValue, all little endian
+//   - the existence bitmap
+//   - every bit slice, in least to most significance order, until the end of the stream
+//
+// Streams without the header, as written by earlier versions, start with the existence
+// bitmap: they are read as well and leave MinValue and MaxValue unchanged.
+// Errors are *roaring.DeserializationError whose Container is the index of the bitmap
+// (0 for the existence bitmap, i+1 for the bit slice i), or -1 for the header.
 func (b *BSI) ReadFrom(stream io.Reader) (p int64, err error) {
+	header, stream, nh, err := internal.ReadBSIHeader(stream)
+	p += int64(nh)
+	if err == io.EOF {
+		// the stream ended cleanly, before the BSI
+		return
+	}
+	if err != nil {
+		err = readError(p, -1, fmt.Errorf("reading header: %w", err))
+		return
+	}
+
 	bm, n, err := readBSIContainerFromStream(stream)
 	p += n
 	if err != nil {
-		err = fmt.Errorf("reading existence bitmap: %w", err)
+		err = innerError(p-n, 0, fmt.Errorf("reading existence bitmap: %w", err))
 		return
 	}
+	if header != nil {
+		b.MinValue, b.MaxValue = header.MinValue, header.MaxValue
+	}
 	b.eBM = bm
 	b.bA = b.bA[:0]
 	for {
@@ -677,12 +704,12 @@ func (b *BSI) ReadFrom(stream io.Reader) (p int64, err error) {
 		var bm Bitmap
 		bm, n, err = readBSIContainerFromStream(stream)
 		p += n
-		if err == io.EOF {
+		if n == 0 && errors.Is(err, io.EOF) {
 			err = nil
 			return
 		}
 		if err != nil {
-			err = fmt.Errorf("reading bit slice index %v: %w", len(b.bA), err)
+			err = innerError(p-n, len(b.bA)+1, fmt.Errorf("reading bit slice index %v: %w", len(b.bA), err))
 			return
 		}
 		b.bA = append(b.bA, bm)
@@ -714,8 +741,13 @@ func (b *BSI) MarshalBinary() ([][]byte, error) {
 	return data, nil
 }
 
-// WriteTo writes a serialized version of this BSI to stream.
+// WriteTo writes a serialized version of this BSI to stream, see ReadFrom for the layout.
 func (b *BSI) WriteTo(w io.Writer) (n int64, err error) {
+	nh, err := internal.WriteBSIHeader(w, internal.BSIHeader{MinValue: b.MinValue, MaxValue: b.MaxValue})
+	n += int64(nh)
+	if err != nil {
+		return
+	}
 	n1, err := b.eBM.WriteTo(w)
 	n += n1
 	if err != nil {
@@ -0,0 +1,96 @@
+package roaring64
+
+import (
+	"io"
+
+	"github.com/RoaringBitmap/roaring/v2"
+	"github.com/RoaringBitmap/roaring/v2/internal"
+)
+
+// frozenBSICookie starts a frozen BSI ("BS64" in little endian)
+const frozenBSICookie = 0x34365342
+
+// FrozenBSIView creates a read-only BSI over a buffer written by Freeze,
+// FreezeTo or WriteFrozenTo. The existence bitmap and every bit slice are
+// created with Bitmap.FrozenView, so no bitmap data is copied and
+// queries such as CompareValue, Sum and MinMax run directly over buf.
+//
+// The layout is shared with BitSliceIndexing.BSI: a header holding MinValue, MaxValue
+// and the size of every bitmap, followed by the frozen bitmaps, each one 32-byte aligned
+// relative to the start of buf.
+//
+// buf must not be modified while the BSI is in use. The BSI should not be
+// modified either: its bitmaps are copy-on-write, so changes are possible
+// but allocate and defeat the purpose of the view.
+// Errors are *roaring.DeserializationError whose Container is the index of the bitmap
+// (0 for the existence bitmap, i+1 for the bit slice i).
+func FrozenBSIView(buf []byte) (*BSI, error) {
+	f, failure := internal.ParseFrozenBSI(buf, frozenBSICookie)
+	if failure != nil {
+		return nil, frozenBSIError(failure)
+	}
+
+	b := &BSI{
+		MinValue: f.MinValue,
+		MaxValue: f.MaxValue,
+		bA:       make([]Bitmap, len(f.Bitmaps)-1),
+	}
+	for i, data := range f.Bitmaps {
+		bm := &b.eBM
+		if i > 0 {
+			bm = &b.bA[i-1]
+		}
+		if err := bm.FrozenView(data); err != nil {
+			return nil, innerError(f.Offsets[i], i, err)
+		}
+	}
+	return b, nil
+}
+
+// frozenBSIError reports the problem found in a frozen BSI.
+func frozenBSIError(failure *internal.FrozenBSIFailure) error {
+	kind, err := roaring.TruncatedInput, roaring.ErrFrozenBitmapIncomplete
+	switch failure.Problem {
+	case internal.FrozenBSIInvalidCookie:
+		kind, err = roaring.InvalidCookie, roaring.ErrFrozenBitmapInvalidCookie
+	case internal.FrozenBSITrailingData:
+		kind, err = roaring.TrailingData, roaring.ErrFrozenBitmapUnexpectedData
+	}
+	return &roaring.DeserializationError{Kind: kind, Offset: int64(failure.Offset), Container: failure.Bitmap, Err: err}
+}
+
+// frozenBitmaps returns the existence bitmap followed by every bit slice.
+func (b *BSI) frozenBitmaps() []internal.FrozenBitmap {
+	bitmaps := make([]internal.FrozenBitmap, 0, len(b.bA)+1)
+	bitmaps = append(bitmaps, &b.eBM)
+	for i := range b.bA {
+		bitmaps = append(bitmaps, &b.bA[i])
+	}
+	return bitmaps
+}
+
+// GetFrozenSizeInBytes returns the size in bytes of the frozen BSI.
+func (b *BSI) GetFrozenSizeInBytes() uint64 {
+	return internal.FrozenBSISize(b.frozenBitmaps())
+}
+
+// Freeze serializes the BSI in the frozen format read by FrozenBSIView.
+func (b *BSI) Freeze() ([]byte, error) {
+	buf := make([]byte, b.GetFrozenSizeInBytes())
+	_, err := b.FreezeTo(buf)
+	return buf, err
+}
+
+// FreezeTo serializes the BSI in the frozen format read by FrozenBSIView.
+func (b *BSI) FreezeTo(buf []byte) (int, error) {
+	bitmaps := b.frozenBitmaps()
+	if uint64(len(buf)) < internal.FrozenBSISize(bitmaps) {
+		return 0, roaring.ErrFrozenBitmapBufferTooSmall
+	}
+	return internal.FreezeBSITo(buf, frozenBSICookie, internal.BSIHeader{MinValue: b.MinValue, MaxValue: b.MaxValue}, bitmaps)
+}
+
+// WriteFrozenTo serializes the BSI in the frozen format read by FrozenBSIView.
+func (b *BSI) WriteFrozenTo(wr io.Writer) (int, error) {
+	return internal.WriteFrozenBSITo(wr, frozenBSICookie, internal.BSIHeader{MinValue: b.MinValue, MaxValue: b.MaxValue}, b.frozenBitmaps())
+}
@@ -0,0 +1,98 @@
+package roaring64
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/RoaringBitmap/roaring/v2"
+	"github.com/RoaringBitmap/roaring/v2/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupWide returns a BSI with columns above 2^32 and values spanning 51 bit slices,
+// from -2^50 to 2^50.
+func setupWide() *BSI {
+	bsi := NewBSI(1<<50, -1<<50)
+	for i := uint64(0); i < 300; i++ {
+		bsi.SetValue(i, int64(i)<<40)
+		bsi.SetValue(1<<32+i, -int64(i)<<40-1)
+		bsi.SetValue(5<<40+i, int64(i)*7919)
+	}
+	bsi.SetValue(1<<63, 1<<50)
+	bsi.SetValue(1<<63+1, -1<<50)
+	return bsi
+}
+
+func TestFrozenBSIView(t *testing.T) {
+	bsi := setupWide()
+	bsi.RunOptimize()
+
+	buf, err := bsi.Freeze()
+	require.NoError(t, err)
+	assert.EqualValues(t, bsi.GetFrozenSizeInBytes(), len(buf))
+	orig := append([]byte(nil), buf...)
+
+	view, err := FrozenBSIView(buf)
+	require.NoError(t, err)
+	assert.True(t, bsi.Equals(view))
+	assert.Equal(t, int64(1<<50), view.MaxValue)
+	assert.Equal(t, int64(-1<<50), view.MinValue)
+	assert.Equal(t, bsi.BitCount(), view.BitCount())
+
+	it := bsi.GetExistenceBitmap().Iterator()
+	for it.HasNext() {
+		col := it.Next()
+		expected, _ := bsi.GetValue(col)
+		value, ok := view.GetValue(col)
+		assert.True(t, ok)
+		assert.Equal(t, expected, value, "column %d", col)
+	}
+
+	for _, value := range []int64{-1 << 50, -1 << 40, 0, 1 << 45, 1 << 50} {
+		for _, op := range []Operation{LT, LE, EQ, GE, GT} {
+			expected := bsi.CompareValue(0, op, value, 0, nil)
+			assert.True(t, expected.Equals(view.CompareValue(0, op, value, 0, nil)), "op %v value %d", op, value)
+		}
+	}
+	assert.True(t, bsi.CompareValue(0, RANGE, -1<<45, 1<<45, nil).Equals(view.CompareValue(0, RANGE, -1<<45, 1<<45, nil)))
+
+	sum, count := bsi.Sum(bsi.GetExistenceBitmap())
+	vsum, vcount := view.Sum(view.GetExistenceBitmap())
+	assert.Equal(t, sum, vsum)
+	assert.Equal(t, count, vcount)
+
+	assert.Equal(t, int64(-1<<50), view.MinMax(0, MIN, view.GetExistenceBitmap()))
+	assert.Equal(t, int64(1<<50), view.MinMax(0, MAX, view.GetExistenceBitmap()))
+	assert.Equal(t, orig, buf)
+
+	t.Run("write frozen", func(t *testing.T) {
+		var out bytes.Buffer
+		n, err := bsi.WriteFrozenTo(&out)
+		require.NoError(t, err)
+		assert.Equal(t, len(buf), n)
+		assert.Equal(t, buf, out.Bytes())
+	})
+}
+
+func TestFrozenBSIViewErrors(t *testing.T) {
+	buf, err := setupWide().Freeze()
+	require.NoError(t, err)
+
+	// a BSI frozen by BitSliceIndexing has another cookie
+	bad := append([]byte(nil), buf...)
+	binary.LittleEndian.PutUint32(bad, 0x46495342)
+	_, err = FrozenBSIView(bad)
+	assert.ErrorIs(t, err, roaring.ErrFrozenBitmapInvalidCookie)
+
+	// sizes are 64-bit, a size above 2^32 must not wrap around
+	bad = append([]byte(nil), buf...)
+	binary.LittleEndian.PutUint64(bad[internal.FrozenBSIHeaderSize+8:], 1<<32+1)
+	_, err = FrozenBSIView(bad)
+	assert.ErrorIs(t, err, roaring.ErrFrozenBitmapIncomplete)
+	var de *roaring.DeserializationError
+	require.True(t, errors.As(err, &de))
+	assert.Equal(t, 1, de.Container)
+}
@@ -3,6 +3,7 @@ package roaring64
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -12,6 +13,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/RoaringBitmap/roaring/v2"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -485,6 +487,52 @@ func TestBSIWriteToReadFrom(t *testing.T) {
 	assert.Equal(t, bsi.MaxValue, bsi2.MinMax(0, MAX, bsi2.GetExistenceBitmap()))
 }
 
+func TestBSIStreamLayout(t *testing.T) {
+	// header, existence bitmap and bit slices, as documented by both BSI implementations
+	var buf bytes.Buffer
+	buf.WriteString("BSIS")
+	require.NoError(t, binary.Write(&buf, binary.LittleEndian, []int64{-5, 1000}))
+	_, err := BitmapOf(1, 2, 7<<32).WriteTo(&buf)
+	require.NoError(t, err)
+	for _, slice := range []*Bitmap{BitmapOf(1, 7<<32), BitmapOf(2, 7<<32)} {
+		_, err = slice.WriteTo(&buf)
+		require.NoError(t, err)
+	}
+	layout := buf.Bytes()
+
+	bsi := NewDefaultBSI()
+	_, err = bsi.ReadFrom(bytes.NewReader(layout))
+	require.NoError(t, err)
+	assert.EqualValues(t, -5, bsi.MinValue)
+	assert.EqualValues(t, 1000, bsi.MaxValue)
+	for col, expected := range map[uint64]int64{1: 1, 2: 2, 7 << 32: 3} {
+		v, ok := bsi.GetValue(col)
+		assert.True(t, ok)
+		assert.Equal(t, expected, v)
+	}
+
+	var written bytes.Buffer
+	_, err = bsi.WriteTo(&written)
+	require.NoError(t, err)
+	assert.Equal(t, layout, written.Bytes())
+
+	// streams written before the header was added keep MinValue and MaxValue
+	bsi = NewBSI(10, 1)
+	_, err = bsi.ReadFrom(bytes.NewReader(layout[20:]))
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, bsi.MinValue)
+	assert.EqualValues(t, 10, bsi.MaxValue)
+	v, ok := bsi.GetValue(7 << 32)
+	assert.True(t, ok)
+	assert.EqualValues(t, 3, v)
+
+	_, err = NewDefaultBSI().ReadFrom(bytes.NewReader(layout[:10]))
+	var de *roaring.DeserializationError
+	require.True(t, errors.As(err, &de))
+	assert.Equal(t, roaring.TruncatedInput, de.Kind)
+	assert.Equal(t, -1, de.Container)
+}
+
 type bsiColValPair struct {
 	col uint64
 	val int64
@@ -561,6 +609,32 @@ func TestBsiStreaming(t *testing.T) {
 	testBsiRoundTrip(t, []bsiColValPair{{48, 0}})
 }
 
+func TestBSIReadFromError(t *testing.T) {
+	bsi := setupRandom()
+	var buf bytes.Buffer
+	_, err := bsi.WriteTo(&buf)
+	require.NoError(t, err)
+	data := buf.Bytes()
+
+	_, err = NewDefaultBSI().ReadFrom(bytes.NewReader(data[:len(data)-1]))
+	var de *roaring.DeserializationError
+	require.True(t, errors.As(err, &de))
+	assert.Equal(t, roaring.TruncatedInput, de.Kind)
+	assert.Equal(t, bsi.BitCount(), de.Container)
+	assert.Less(t, de.Offset, int64(len(data)))
+
+	_, err = NewDefaultBSI().ReadFrom(bytes.NewReader(nil))
+	assert.Equal(t, io.EOF, err)
+
+	bitData, err := bsi.MarshalBinary()
+	require.NoError(t, err)
+	bitData[2] = bitData[2][:len(bitData[2])-1]
+	err = NewDefaultBSI().UnmarshalBinary(bitData)
+	require.True(t, errors.As(err, &de))
+	assert.Equal(t, roaring.TruncatedInput, de.Kind)
+	assert.Equal(t, 2, de.Container)
+}
+
 // Test that the BSI can be mutated and still be equal to a fresh BSI with the same values.
 func TestMutatedBsiEquality(t *testing.T) {
 	mutated := NewDefaultBSI()
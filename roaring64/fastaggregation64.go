@@ -1,5 +1,7 @@
 package roaring64
 
+import "github.com/RoaringBitmap/roaring/v2"
+
 // FastAnd computes the intersection between many bitmaps quickly
 // Compared to the And function, it can take many bitmaps as input, thus saving the trouble
 // of manually calling "And" many times.
@@ -29,3 +31,246 @@ func FastOr(bitmaps ...*Bitmap) *Bitmap {
 	}
 	return answer
 }
+
+// HeapOr computes the union between many bitmaps quickly using a heap.
+// The 32-bit bitmaps sharing a high key are merged with roaring.HeapOr.
+// It might be faster than calling Or repeatedly.
+func HeapOr(bitmaps ...*Bitmap) *Bitmap {
+	return heapAggregate(roaring.HeapOr, bitmaps...)
+}
+
+// HeapXor computes the symmetric difference between many bitmaps quickly (as opposed to calling Xor repeated).
+// Internally, this function uses a heap and merges the 32-bit bitmaps sharing a high key with roaring.HeapXor.
+// It might be faster than calling Xor repeatedly.
+func HeapXor(bitmaps ...*Bitmap) *Bitmap {
+	return heapAggregate(roaring.HeapXor, bitmaps...)
+}
+
+// FastXor computes the symmetric difference between many bitmaps quickly, as opposed to having to call Xor repeatedly.
+// The 32-bit bitmaps sharing a high key are merged with roaring.FastXor.
+func FastXor(bitmaps ...*Bitmap) *Bitmap {
+	if len(bitmaps) == 0 {
+		return NewBitmap()
+	} else if len(bitmaps) == 1 {
+		return bitmaps[0].Clone()
+	}
+	return heapAggregate(roaring.FastXor, bitmaps...)
+}
+
+// FastAndNot computes the values of base that are in none of the subtrahends,
+// that is AndNot(base, FastOr(subtrahends...)), without building the union:
+// only the high keys of base are visited, and the 32-bit bitmaps sharing a
+// high key are combined with roaring.FastAndNot. Bitmaps are not modified.
+func FastAndNot(base *Bitmap, subtrahends ...*Bitmap) *Bitmap {
+	answer := NewBitmap()
+	positions := make([]int, len(subtrahends))
+	keyContainers := make([]*roaring.Bitmap, 0, len(subtrahends))
+
+	for pos := 0; pos < base.highlowcontainer.size(); pos++ {
+		key := base.highlowcontainer.getKeyAtIndex(pos)
+
+		for i, s := range subtrahends {
+			ra := &s.highlowcontainer
+			if positions[i] < ra.size() && ra.getKeyAtIndex(positions[i]) < key {
+				positions[i] = ra.advanceUntil(key, positions[i])
+			}
+			if positions[i] < ra.size() && ra.getKeyAtIndex(positions[i]) == key {
+				keyContainers = append(keyContainers, ra.getContainerAtIndex(positions[i]))
+			}
+		}
+
+		if len(keyContainers) == 0 {
+			answer.highlowcontainer.appendCopy(base.highlowcontainer, pos)
+		} else {
+			c := roaring.FastAndNot(base.highlowcontainer.getContainerAtIndex(pos), keyContainers...)
+			if !c.IsEmpty() {
+				answer.highlowcontainer.appendContainer(key, c, false)
+			}
+		}
+		keyContainers = keyContainers[:0]
+	}
+	return answer
+}
+
+// heapAggregate walks the high keys of all bitmaps in increasing order and
+// applies aggr to the 32-bit bitmaps found under each key.
+func heapAggregate(aggr func(bitmaps ...*roaring.Bitmap) *roaring.Bitmap, bitmaps ...*Bitmap) *Bitmap {
+	answer := NewBitmap()
+	h := newBitmapContainerHeap(bitmaps...)
+	containers := make([]*roaring.Bitmap, 0, len(bitmaps))
+	for h.Len() > 0 {
+		ck := h.Next(containers[:0])
+		var c *roaring.Bitmap
+		if len(ck.containers) == 1 {
+			c = ck.containers[0].Clone()
+		} else {
+			c = aggr(ck.containers...)
+		}
+		if !c.IsEmpty() {
+			answer.highlowcontainer.appendContainer(ck.key, c, false)
+		}
+		containers = ck.containers
+	}
+	return answer
+}
+
+// FastOrCardinality returns the cardinality of the union between many
+// bitmaps, as FastOr(bitmaps...).GetCardinality() would, but without
+// building the union: the 32-bit bitmaps sharing a high key are counted
+// with roaring.FastOrCardinality.
+func FastOrCardinality(bitmaps ...*Bitmap) uint64 {
+	return heapCardinality(roaring.FastOrCardinality, bitmaps...)
+}
+
+// FastAndCardinality returns the cardinality of the intersection between
+// many bitmaps, as FastAnd(bitmaps...).GetCardinality() would, but without
+// building the intersection: the 32-bit bitmaps sharing a high key are
+// counted with roaring.FastAndCardinality.
+func FastAndCardinality(bitmaps ...*Bitmap) uint64 {
+	if len(bitmaps) == 0 {
+		return 0
+	}
+	return heapCardinality(func(containers ...*roaring.Bitmap) uint64 {
+		if len(containers) < len(bitmaps) {
+			return 0
+		}
+		return roaring.FastAndCardinality(containers...)
+	}, bitmaps...)
+}
+
+// FastXorCardinality returns the cardinality of the symmetric difference
+// between many bitmaps, as HeapXor(bitmaps...).GetCardinality() would, but
+// without building the symmetric difference: the 32-bit bitmaps sharing a
+// high key are counted with roaring.FastXorCardinality.
+func FastXorCardinality(bitmaps ...*Bitmap) uint64 {
+	return heapCardinality(roaring.FastXorCardinality, bitmaps...)
+}
+
+// heapCardinality walks the high keys of all bitmaps in increasing order and
+// sums count over the 32-bit bitmaps found under each key.
+func heapCardinality(count func(bitmaps ...*roaring.Bitmap) uint64, bitmaps ...*Bitmap) uint64 {
+	answer := uint64(0)
+	h := newBitmapContainerHeap(bitmaps...)
+	containers := make([]*roaring.Bitmap, 0, len(bitmaps))
+	for h.Len() > 0 {
+		ck := h.Next(containers[:0])
+		answer += count(ck.containers...)
+		containers = ck.containers
+	}
+	return answer
+}
+
+// FastThreshold computes the values that are present in at least k of the
+// given bitmaps. With k <= 1 it is equivalent to FastOr, with k equal to the
+// number of bitmaps it is equivalent to FastAnd, and it returns an empty
+// bitmap when k exceeds the number of bitmaps.
+// The 32-bit bitmaps sharing a high key are combined with
+// roaring.FastThreshold, keys held by fewer than k bitmaps are skipped.
+func FastThreshold(k int, bitmaps ...*Bitmap) *Bitmap {
+	if k <= 1 {
+		return FastOr(bitmaps...)
+	} else if k > len(bitmaps) {
+		return NewBitmap()
+	}
+
+	answer := NewBitmap()
+	h := newBitmapContainerHeap(bitmaps...)
+	containers := make([]*roaring.Bitmap, 0, len(bitmaps))
+	for h.Len() > 0 {
+		ck := h.Next(containers[:0])
+		containers = ck.containers
+		if len(ck.containers) < k {
+			continue
+		}
+		c := roaring.FastThreshold(k, ck.containers...)
+		if !c.IsEmpty() {
+			answer.highlowcontainer.appendContainer(ck.key, c, false)
+		}
+	}
+	return answer
+}
+
+// AndAny provides a result equivalent to x1.And(FastOr(bitmaps)).
+// It's optimized to minimize allocations: the 32-bit bitmaps sharing a
+// high key are combined with roaring.Bitmap.AndAny.
+// It also might be faster than separate calls.
+func (x1 *Bitmap) AndAny(bitmaps ...*Bitmap) {
+	if len(bitmaps) == 0 {
+		return
+	} else if len(bitmaps) == 1 {
+		x1.And(bitmaps[0])
+		return
+	}
+
+	type withPos struct {
+		bitmap *roaringArray64
+		pos    int
+		key    uint32
+	}
+	filters := make([]withPos, 0, len(bitmaps))
+
+	for _, b := range bitmaps {
+		if b.highlowcontainer.size() > 0 {
+			filters = append(filters, withPos{
+				bitmap: &b.highlowcontainer,
+				pos:    0,
+				key:    b.highlowcontainer.getKeyAtIndex(0),
+			})
+		}
+	}
+
+	basePos := 0
+	intersections := 0
+	keyContainers := make([]*roaring.Bitmap, 0, len(filters))
+	var minNextKey uint32
+
+	for basePos < x1.highlowcontainer.size() && len(filters) > 0 {
+		baseKey := x1.highlowcontainer.getKeyAtIndex(basePos)
+
+		// accumulate containers for current key, find next minimal key in filters
+		// and exclude filters that do not have related values anymore
+		i := 0
+		minNextKey = maxUint32
+		for _, f := range filters {
+			if f.key < baseKey {
+				f.pos = f.bitmap.advanceUntil(baseKey, f.pos)
+				if f.pos == f.bitmap.size() {
+					continue
+				}
+				f.key = f.bitmap.getKeyAtIndex(f.pos)
+			}
+
+			if f.key == baseKey {
+				keyContainers = append(keyContainers, f.bitmap.getContainerAtIndex(f.pos))
+
+				f.pos++
+				if f.pos == f.bitmap.size() {
+					continue
+				}
+				f.key = f.bitmap.getKeyAtIndex(f.pos)
+			}
+
+			minNextKey = minOfUint32(minNextKey, f.key)
+			filters[i] = f
+			i++
+		}
+		filters = filters[:i]
+
+		if len(keyContainers) == 0 {
+			basePos = x1.highlowcontainer.advanceUntil(minNextKey, basePos)
+			continue
+		}
+
+		result := x1.highlowcontainer.getWritableContainerAtIndex(basePos)
+		result.AndAny(keyContainers...)
+		if !result.IsEmpty() {
+			x1.highlowcontainer.replaceKeyAndContainerAtIndex(intersections, baseKey, result, false)
+			intersections++
+		}
+
+		keyContainers = keyContainers[:0]
+		basePos = x1.highlowcontainer.advanceUntil(minNextKey, basePos)
+	}
+
+	x1.highlowcontainer.resize(intersections)
+}
@@ -4,6 +4,7 @@ package roaring64
 
 import (
 	"github.com/stretchr/testify/assert"
+	"math/rand"
 	"sort"
 	"testing"
 )
@@ -78,3 +79,148 @@ func TestIssue330_64bits(t *testing.T) {
 	assert.Equal(t, FastOr(bitmaps[2], bitmaps[1], bitmaps[0]).GetCardinality(), uint64(1040))
 	assert.Equal(t, FastOr(bitmaps[0], bitmaps[1], bitmaps[2]).GetCardinality(), uint64(1040))
 }
+
+func TestFastAggregationsAndAny(t *testing.T) {
+	base := NewBitmap()
+	rb1 := NewBitmap()
+	rb2 := NewBitmap()
+	rb3 := NewBitmap()
+	// only one filter has some values
+	from := uint64(4) << 32
+	for i := from; i < from+100; i += 2 {
+		rb1.Add(i)
+	}
+	// only base has values
+	from = uint64(7) << 32
+	for i := from; i < from+100; i += 2 {
+		base.Add(i)
+	}
+	// base and one of filters have same values
+	from = uint64(8) << 32
+	for i := from; i < from+100; i += 2 {
+		base.Add(i)
+		rb1.Add(i)
+	}
+	// small union
+	from = uint64(10) << 32
+	for i := from; i < from+1000; i += 10 {
+		base.Add(i)
+		base.Add(i + i%3)
+
+		rb1.Add(i)
+		rb1.Add(i + 1)
+
+		rb2.Add(i + 2)
+		rb2.Add(i + i%7)
+
+		rb3.Add(200 + i)
+	}
+	// run filters
+	for i := from; i < from+1000; i += 3 {
+		base.Add(i)
+	}
+	for i := from; i < from+100; i++ {
+		rb1.Add(i)
+		rb2.Add(i + 333)
+		rb3.Add(i + 433)
+	}
+	// large union spanning several 16-bit containers
+	from = uint64(16) << 32
+	for i := from; i < from+200000; i += 3 {
+		base.Add(i)
+		base.Add(i + i%2 + 1)
+		rb2.Add(i)
+		rb3.Add(i + 1)
+	}
+
+	// some extra base values
+	from = uint64(17) << 32
+	for i := from; i < from+1000; i++ {
+		base.Add(i)
+	}
+
+	base.RunOptimize()
+	rb1.RunOptimize()
+	rb2.RunOptimize()
+	rb3.RunOptimize()
+
+	orFirst := base.Clone()
+	orFirst.And(FastOr(rb1, rb2, rb3))
+
+	fast := base.Clone()
+	fast.AndAny(rb1, rb2, rb3)
+
+	assert.True(t, fast.Equals(orFirst))
+	assert.NoError(t, fast.Validate())
+
+	single := base.Clone()
+	single.AndAny(rb1)
+	assert.True(t, single.Equals(And(base, rb1)))
+
+	unchanged := base.Clone()
+	unchanged.AndAny()
+	assert.True(t, unchanged.Equals(base))
+}
+
+func TestFastAggregationsCardinality(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	bitmaps := make([]*Bitmap, 5)
+	for i := range bitmaps {
+		rb := NewBitmap()
+		rb.AddRange(1<<32-1<<16, 1<<32+1<<16)
+		for j := 0; j < 10000; j++ {
+			rb.Add(uint64(r.Intn(1<<20)) | uint64(r.Intn(i+2))<<32)
+		}
+		rb.RunOptimize()
+		bitmaps[i] = rb
+	}
+	bitmaps[1].Remove(1 << 32)
+
+	for n := 0; n <= len(bitmaps); n++ {
+		input := bitmaps[:n]
+		assert.Equal(t, FastOr(input...).GetCardinality(), FastOrCardinality(input...), "%d bitmaps", n)
+		assert.Equal(t, FastAnd(input...).GetCardinality(), FastAndCardinality(input...), "%d bitmaps", n)
+		assert.Equal(t, HeapXor(input...).GetCardinality(), FastXorCardinality(input...), "%d bitmaps", n)
+	}
+
+	assert.Zero(t, FastAndCardinality(bitmaps[0], NewBitmap(), bitmaps[1]))
+}
+
+func TestFastThreshold(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	bitmaps := make([]*Bitmap, 6)
+	for i := range bitmaps {
+		rb := NewBitmap()
+		rb.AddRange(1<<32-1<<16, 1<<32+1<<16)
+		for j := 0; j < 10000; j++ {
+			rb.Add(uint64(r.Intn(1<<18)) | uint64(r.Intn(i+2))<<32)
+		}
+		rb.RunOptimize()
+		bitmaps[i] = rb
+	}
+
+	counts := make(map[uint64]int)
+	atLeast := func(k int) *Bitmap {
+		answer := NewBitmap()
+		for x, count := range counts {
+			if count >= k {
+				answer.Add(x)
+			}
+		}
+		return answer
+	}
+
+	for n := 0; n <= len(bitmaps); n++ {
+		if n > 0 {
+			bitmaps[n-1].Iterate(func(x uint64) bool {
+				counts[x]++
+				return true
+			})
+		}
+		for k := 0; k <= n+1; k++ {
+			assert.True(t, atLeast(k).Equals(FastThreshold(k, bitmaps[:n]...)), "k=%d of %d bitmaps", k, n)
+		}
+	}
+
+	assert.True(t, FastThreshold(len(bitmaps), bitmaps...).Equals(FastAnd(bitmaps...)))
+}
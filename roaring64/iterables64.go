@@ -59,8 +59,9 @@ func (ii *intIterator) PeekNext() uint64 {
 func (ii *intIterator) AdvanceIfNeeded(minval uint64) {
 	to := minval >> 32
 
-	for ii.HasNext() && (ii.hs>>32) < to {
-		ii.pos++
+	if ii.HasNext() && (ii.hs>>32) < to {
+		// gallop over the keys to the first inner bitmap which may hold minval
+		ii.pos = ii.highlowcontainer.advanceUntil(highbits(minval), ii.pos)
 		ii.init()
 	}
 
@@ -82,10 +83,20 @@ func newIntIterator(a *Bitmap) *intIterator {
 	return p
 }
 
+// IntReversePeekable64 allows you to look at the next value without advancing and
+// retreat as long as the next value is larger than maxval
+type IntReversePeekable64 interface {
+	IntIterable64
+	// PeekNext peeks the next value without advancing the iterator
+	PeekNext() uint64
+	// RetreatIfNeeded retreats as long as the next value is larger than maxval
+	RetreatIfNeeded(maxval uint64)
+}
+
 type intReverseIterator struct {
 	pos              int
 	hs               uint64
-	iter             roaring.IntIterable
+	iter             roaring.IntReversePeekable
 	highlowcontainer *roaringArray64
 }
 
@@ -96,7 +107,9 @@ func (ii *intReverseIterator) HasNext() bool {
 
 func (ii *intReverseIterator) init() {
 	if ii.pos >= 0 {
-		ii.iter = ii.highlowcontainer.getContainerAtIndex(ii.pos).ReverseIterator()
+		it := new(roaring.IntReverseIterator)
+		it.Initialize(ii.highlowcontainer.getContainerAtIndex(ii.pos))
+		ii.iter = it
 		ii.hs = uint64(ii.highlowcontainer.getKeyAtIndex(ii.pos)) << 32
 	} else {
 		ii.iter = nil
@@ -113,6 +126,30 @@ func (ii *intReverseIterator) Next() uint64 {
 	return x
 }
 
+// PeekNext peeks the next value without advancing the iterator
+func (ii *intReverseIterator) PeekNext() uint64 {
+	return uint64(ii.iter.PeekNext()&maxLowBit) | ii.hs
+}
+
+// RetreatIfNeeded retreats as long as the next value is larger than maxval
+func (ii *intReverseIterator) RetreatIfNeeded(maxval uint64) {
+	to := maxval >> 32
+
+	for ii.HasNext() && (ii.hs>>32) > to {
+		ii.pos--
+		ii.init()
+	}
+
+	if ii.HasNext() && (ii.hs>>32) == to {
+		ii.iter.RetreatIfNeeded(lowbits(maxval))
+
+		if !ii.iter.HasNext() {
+			ii.pos--
+			ii.init()
+		}
+	}
+}
+
 func newIntReverseIterator(a *Bitmap) *intReverseIterator {
 	p := new(intReverseIterator)
 	p.highlowcontainer = &a.highlowcontainer
@@ -121,6 +158,126 @@ func newIntReverseIterator(a *Bitmap) *intReverseIterator {
 	return p
 }
 
+// intRangeIterator is an intIterator stopping at the first value not smaller than max
+type intRangeIterator struct {
+	intIterator
+	max uint64
+}
+
+// HasNext returns true if there are more integers to iterate over
+func (ii *intRangeIterator) HasNext() bool {
+	return ii.intIterator.HasNext() && ii.PeekNext() < ii.max
+}
+
+func newIntRangeIterator(a *Bitmap, min, max uint64) *intRangeIterator {
+	p := new(intRangeIterator)
+	p.highlowcontainer = &a.highlowcontainer
+	p.init()
+	if min >= max {
+		return p
+	}
+	p.max = max
+	p.AdvanceIfNeeded(min)
+	return p
+}
+
+// intReverseRangeIterator is an intReverseIterator stopping at the first value smaller than min
+type intReverseRangeIterator struct {
+	intReverseIterator
+	min uint64
+	// empty is set when the range [min, max) is empty
+	empty bool
+}
+
+// HasNext returns true if there are more integers to iterate over
+func (ii *intReverseRangeIterator) HasNext() bool {
+	return !ii.empty && ii.intReverseIterator.HasNext() && ii.PeekNext() >= ii.min
+}
+
+func newIntReverseRangeIterator(a *Bitmap, min, max uint64) *intReverseRangeIterator {
+	p := new(intReverseRangeIterator)
+	p.highlowcontainer = &a.highlowcontainer
+	p.pos = a.highlowcontainer.size() - 1
+	p.init()
+	if min >= max {
+		p.empty = true
+		return p
+	}
+	p.min = min
+	p.RetreatIfNeeded(max - 1)
+	return p
+}
+
+// RangeIterator64 allows you to iterate over the maximal runs of consecutive values in a Bitmap
+type RangeIterator64 interface {
+	HasNext() bool
+	// Next returns the next run as the half-open interval [start, endExclusive),
+	// endExclusive is 0 for a run ending with math.MaxUint64
+	Next() (start, endExclusive uint64)
+}
+
+type rangeIterator struct {
+	pos              int
+	hs               uint64
+	iter             roaring.RangeIterator
+	highlowcontainer *roaringArray64
+
+	// the next merged run, and the run read after it
+	hasNext    bool
+	start, end uint64
+	lookahead  roaring.Interval
+	hasLook    bool
+}
+
+// HasNext returns true if there are more runs to iterate over
+func (ri *rangeIterator) HasNext() bool {
+	return ri.hasNext
+}
+
+// Next returns the next run as the half-open interval [start, endExclusive)
+func (ri *rangeIterator) Next() (start, endExclusive uint64) {
+	start, endExclusive = ri.start, ri.end
+	ri.advance()
+	return
+}
+
+// read returns the next run of a 32-bit bitmap, runs are not merged across high keys.
+func (ri *rangeIterator) read() (roaring.Interval, bool) {
+	for ri.iter == nil || !ri.iter.HasNext() {
+		if ri.pos >= ri.highlowcontainer.size() {
+			return roaring.Interval{}, false
+		}
+		ri.iter = ri.highlowcontainer.getContainerAtIndex(ri.pos).Ranges()
+		ri.hs = uint64(ri.highlowcontainer.getKeyAtIndex(ri.pos)) << 32
+		ri.pos++
+	}
+	start, end := ri.iter.Next()
+	return roaring.Interval{Start: ri.hs + start, End: ri.hs + end}, true
+}
+
+func (ri *rangeIterator) advance() {
+	ri.hasNext = ri.hasLook
+	if !ri.hasNext {
+		return
+	}
+	ri.start, ri.end = ri.lookahead.Start, ri.lookahead.End
+	for {
+		ri.lookahead, ri.hasLook = ri.read()
+		if !ri.hasLook || ri.lookahead.Start != ri.end {
+			return
+		}
+		ri.end = ri.lookahead.End
+	}
+}
+
+func newRangeIterator(a *Bitmap) *rangeIterator {
+	p := new(rangeIterator)
+	p.highlowcontainer = &a.highlowcontainer
+	p.lookahead, p.hasLook = p.read()
+	p.advance()
+	return p
+}
+
 // ManyIntIterable64 allows you to iterate over the values in a Bitmap
 type ManyIntIterable64 interface {
 	// pass in a buffer to fill up with values, returns how many values were returned
@@ -167,3 +324,44 @@ func newManyIntIterator(a *Bitmap) *manyIntIterator {
 	p.init()
 	return p
 }
+
+type reverseManyIntIterator struct {
+	pos              int
+	hs               uint64
+	iter             roaring.ManyIntIterable
+	highlowcontainer *roaringArray64
+}
+
+func (ii *reverseManyIntIterator) init() {
+	if ii.pos >= 0 {
+		ii.iter = ii.highlowcontainer.getContainerAtIndex(ii.pos).ReverseManyIterator()
+		ii.hs = uint64(ii.highlowcontainer.getKeyAtIndex(ii.pos)) << 32
+	} else {
+		ii.iter = nil
+	}
+}
+
+func (ii *reverseManyIntIterator) NextMany(buf []uint64) int {
+	n := 0
+	for n < len(buf) {
+		if ii.iter == nil {
+			break
+		}
+		moreN := ii.iter.NextMany64(ii.hs, buf[n:])
+		n += moreN
+		if moreN == 0 {
+			ii.pos = ii.pos - 1
+			ii.init()
+		}
+	}
+
+	return n
+}
+
+func newReverseManyIntIterator(a *Bitmap) *reverseManyIntIterator {
+	p := new(reverseManyIntIterator)
+	p.highlowcontainer = &a.highlowcontainer
+	p.pos = a.highlowcontainer.size() - 1
+	p.init()
+	return p
+}
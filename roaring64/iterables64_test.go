@@ -180,3 +180,100 @@ func TestIteratorAdvance(t *testing.T) {
 		assert.EqualValues(t, 31, i.PeekNext())
 	})
 }
+
+func TestReverseIteratorRetreat(t *testing.T) {
+	bm := New()
+	bm.AddMany([]uint64{1, 2, 15, 16, 9999, roaring.MaxUint32, 1<<32 + 5, math.MaxUint64})
+	bm.AddRange(1<<33+100, 1<<33+200)
+	for i := uint64(0); i < 10000; i += 2 {
+		bm.Add(3<<32 + i)
+	}
+	bm.RunOptimize()
+	values := bm.ToArray()
+
+	// expected returns the largest value not larger than maxval, if any
+	expected := func(maxval uint64) (uint64, bool) {
+		for k := len(values) - 1; k >= 0; k-- {
+			if values[k] <= maxval {
+				return values[k], true
+			}
+		}
+		return 0, false
+	}
+
+	maxvals := []uint64{math.MaxUint64, math.MaxUint64 - 1, 3<<32 + 9999, 3<<32 + 1, 3 << 32, 1<<33 + 150, 1<<33 + 99, 1<<32 + 5, 1<<32 + 4, roaring.MaxUint32, 9999, 14, 1, 0}
+
+	t.Run("retreat by using a new int iterator", func(t *testing.T) {
+		for _, maxval := range maxvals {
+			i := bm.ReverseIterator().(IntReversePeekable64)
+			i.RetreatIfNeeded(maxval)
+
+			v, ok := expected(maxval)
+			assert.Equal(t, ok, i.HasNext(), "maxval %d", maxval)
+			if ok {
+				assert.Equal(t, v, i.PeekNext(), "maxval %d", maxval)
+			}
+		}
+	})
+
+	t.Run("retreat by using the same int iterator", func(t *testing.T) {
+		i := bm.ReverseIterator().(IntReversePeekable64)
+
+		for _, maxval := range maxvals {
+			i.RetreatIfNeeded(maxval)
+
+			v, ok := expected(maxval)
+			assert.Equal(t, ok, i.HasNext(), "maxval %d", maxval)
+			if ok {
+				assert.Equal(t, v, i.PeekNext(), "maxval %d", maxval)
+			}
+		}
+	})
+}
+
+func TestIteratorRange(t *testing.T) {
+	bm := New()
+	bm.AddMany([]uint64{1, 2, 15, 16, 9999, roaring.MaxUint32, 1<<32 + 5, math.MaxUint64})
+	bm.AddRange(1<<33+100, 1<<33+200)
+	for i := uint64(0); i < 10000; i += 2 {
+		bm.Add(3<<32 + i)
+	}
+	bm.RunOptimize()
+	values := bm.ToArray()
+
+	ranges := [][2]uint64{
+		{0, math.MaxUint64},
+		{0, 0},
+		{20, 10},
+		{2, 16},
+		{roaring.MaxUint32, 1<<32 + 6},
+		{1<<33 + 150, 3<<32 + 7},
+		{3<<32 + 9000, math.MaxUint64},
+		{math.MaxUint64 - 1, math.MaxUint64},
+	}
+
+	for _, r := range ranges {
+		var want []uint64
+		for _, v := range values {
+			if v >= r[0] && v < r[1] {
+				want = append(want, v)
+			}
+		}
+
+		var got []uint64
+		for i := bm.IteratorRange(r[0], r[1]); i.HasNext(); {
+			got = append(got, i.Next())
+		}
+		assert.Equal(t, want, got, "range %v", r)
+
+		got = got[:0]
+		for i := bm.ReverseIteratorRange(r[0], r[1]); i.HasNext(); {
+			got = append([]uint64{i.Next()}, got...)
+		}
+		if len(want) == 0 {
+			assert.Empty(t, got, "range %v", r)
+		} else {
+			assert.Equal(t, want, got, "range %v", r)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package roaring64
 
 import (
+	"container/heap"
 	"fmt"
 	"runtime"
 
@@ -9,6 +10,283 @@ import (
 
 var defaultWorkerCount = runtime.NumCPU()
 
+type bitmapContainerKey struct {
+	key    uint32
+	idx    int
+	bitmap *Bitmap
+}
+
+type multipleContainers struct {
+	key        uint32
+	containers []*roaring.Bitmap
+	idx        int
+}
+
+type keyedContainer struct {
+	key       uint32
+	container *roaring.Bitmap
+	idx       int
+}
+
+type bitmapContainerHeap []bitmapContainerKey
+
+func (h bitmapContainerHeap) Len() int           { return len(h) }
+func (h bitmapContainerHeap) Less(i, j int) bool { return h[i].key < h[j].key }
+func (h bitmapContainerHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *bitmapContainerHeap) Push(x interface{}) {
+	// Push and Pop use pointer receivers because they modify the slice's length,
+	// not just its contents.
+	*h = append(*h, x.(bitmapContainerKey))
+}
+
+func (h *bitmapContainerHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[0 : n-1]
+	return x
+}
+
+func (h bitmapContainerHeap) Peek() bitmapContainerKey {
+	return h[0]
+}
+
+func (h *bitmapContainerHeap) popIncrementing() (key uint32, container *roaring.Bitmap) {
+	k := h.Peek()
+	key = k.key
+	container = k.bitmap.highlowcontainer.containers[k.idx]
+
+	newIdx := k.idx + 1
+	if newIdx < k.bitmap.highlowcontainer.size() {
+		k = bitmapContainerKey{
+			k.bitmap.highlowcontainer.keys[newIdx],
+			newIdx,
+			k.bitmap,
+		}
+		(*h)[0] = k
+		heap.Fix(h, 0)
+	} else {
+		heap.Pop(h)
+	}
+
+	return
+}
+
+// Next returns all the containers sharing the smallest key in the heap,
+// appended to the containers slice.
+func (h *bitmapContainerHeap) Next(containers []*roaring.Bitmap) multipleContainers {
+	if h.Len() == 0 {
+		return multipleContainers{}
+	}
+
+	key, container := h.popIncrementing()
+	containers = append(containers, container)
+
+	for h.Len() > 0 && key == h.Peek().key {
+		_, container = h.popIncrementing()
+		containers = append(containers, container)
+	}
+
+	return multipleContainers{
+		key,
+		containers,
+		-1,
+	}
+}
+
+func newBitmapContainerHeap(bitmaps ...*Bitmap) bitmapContainerHeap {
+	// Initialize heap
+	var h bitmapContainerHeap = make([]bitmapContainerKey, 0, len(bitmaps))
+	for _, bitmap := range bitmaps {
+		if !bitmap.IsEmpty() {
+			key := bitmapContainerKey{
+				bitmap.highlowcontainer.keys[0],
+				0,
+				bitmap,
+			}
+			h = append(h, key)
+		}
+	}
+
+	heap.Init(&h)
+
+	return h
+}
+
+func appenderRoutine(bitmapChan chan<- *Bitmap, resultChan <-chan keyedContainer, expectedKeysChan <-chan int) {
+	expectedKeys := -1
+	appendedKeys := 0
+	var keys []uint32
+	var containers []*roaring.Bitmap
+	for appendedKeys != expectedKeys {
+		select {
+		case item := <-resultChan:
+			if len(keys) <= item.idx {
+				keys = append(keys, make([]uint32, item.idx-len(keys)+1)...)
+				containers = append(containers, make([]*roaring.Bitmap, item.idx-len(containers)+1)...)
+			}
+			keys[item.idx] = item.key
+			containers[item.idx] = item.container
+
+			appendedKeys++
+		case msg := <-expectedKeysChan:
+			expectedKeys = msg
+		}
+	}
+	answer := &Bitmap{
+		roaringArray64{
+			make([]uint32, 0, expectedKeys),
+			make([]*roaring.Bitmap, 0, expectedKeys),
+			make([]bool, 0, expectedKeys),
+			false,
+		},
+	}
+	for i := range keys {
+		if containers[i] != nil { // in case a resulting container was empty, see ParAnd function
+			answer.highlowcontainer.appendContainer(keys[i], containers[i], false)
+		}
+	}
+
+	bitmapChan <- answer
+}
+
+// ParHeapOr computes the union (OR) of all provided bitmaps in parallel,
+// where the parameter "parallelism" determines how many workers are to be used
+// (if it is set to 0, a default number of workers is chosen)
+// ParHeapOr uses a heap to group the 32-bit bitmaps by high key; each group is
+// then merged with roaring.FastOr. For rare cases it might be faster than ParOr
+func ParHeapOr(parallelism int, bitmaps ...*Bitmap) *Bitmap {
+	bitmapCount := len(bitmaps)
+	if bitmapCount == 0 {
+		return NewBitmap()
+	} else if bitmapCount == 1 {
+		return bitmaps[0].Clone()
+	}
+
+	if parallelism == 0 {
+		parallelism = defaultWorkerCount
+	}
+
+	h := newBitmapContainerHeap(bitmaps...)
+
+	bitmapChan := make(chan *Bitmap)
+	inputChan := make(chan multipleContainers, 128)
+	resultChan := make(chan keyedContainer, 32)
+	expectedKeysChan := make(chan int)
+
+	orFunc := func() {
+		// Assumes only structs with >=2 containers are passed
+		for input := range inputChan {
+			kx := keyedContainer{
+				input.key,
+				roaring.FastOr(input.containers...),
+				input.idx,
+			}
+			resultChan <- kx
+		}
+	}
+
+	go appenderRoutine(bitmapChan, resultChan, expectedKeysChan)
+
+	for i := 0; i < parallelism; i++ {
+		go orFunc()
+	}
+
+	idx := 0
+	for h.Len() > 0 {
+		ck := h.Next(make([]*roaring.Bitmap, 0, 4))
+		if len(ck.containers) == 1 {
+			resultChan <- keyedContainer{
+				ck.key,
+				ck.containers[0].Clone(),
+				idx,
+			}
+		} else {
+			ck.idx = idx
+			inputChan <- ck
+		}
+		idx++
+	}
+	expectedKeysChan <- idx
+
+	bitmap := <-bitmapChan
+
+	close(inputChan)
+	close(resultChan)
+	close(expectedKeysChan)
+
+	return bitmap
+}
+
+// ParAnd computes the intersection (AND) of all provided bitmaps in parallel,
+// where the parameter "parallelism" determines how many workers are to be used
+// (if it is set to 0, a default number of workers is chosen)
+// The 32-bit bitmaps sharing a high key are intersected with roaring.FastAnd.
+func ParAnd(parallelism int, bitmaps ...*Bitmap) *Bitmap {
+	bitmapCount := len(bitmaps)
+	if bitmapCount == 0 {
+		return NewBitmap()
+	} else if bitmapCount == 1 {
+		return bitmaps[0].Clone()
+	}
+
+	if parallelism == 0 {
+		parallelism = defaultWorkerCount
+	}
+
+	h := newBitmapContainerHeap(bitmaps...)
+
+	bitmapChan := make(chan *Bitmap)
+	inputChan := make(chan multipleContainers, 128)
+	resultChan := make(chan keyedContainer, 32)
+	expectedKeysChan := make(chan int)
+
+	andFunc := func() {
+		// Assumes only structs with >=2 containers are passed
+		for input := range inputChan {
+			c := roaring.FastAnd(input.containers...)
+
+			// Send a nil explicitly if the result of the intersection is an empty container
+			if c.IsEmpty() {
+				c = nil
+			}
+
+			kx := keyedContainer{
+				input.key,
+				c,
+				input.idx,
+			}
+			resultChan <- kx
+		}
+	}
+
+	go appenderRoutine(bitmapChan, resultChan, expectedKeysChan)
+
+	for i := 0; i < parallelism; i++ {
+		go andFunc()
+	}
+
+	idx := 0
+	for h.Len() > 0 {
+		ck := h.Next(make([]*roaring.Bitmap, 0, 4))
+		if len(ck.containers) == bitmapCount {
+			ck.idx = idx
+			inputChan <- ck
+			idx++
+		}
+	}
+	expectedKeysChan <- idx
+
+	bitmap := <-bitmapChan
+
+	close(inputChan)
+	close(resultChan)
+	close(expectedKeysChan)
+
+	return bitmap
+}
+
 // ParOr computes the union (OR) of all provided bitmaps in parallel,
 // where the parameter "parallelism" determines how many workers are to be used
 // (if it is set to 0, a default number of workers is chosen)
@@ -44,6 +322,113 @@ func ParOr(parallelism int, bitmaps ...*Bitmap) *Bitmap {
 		return FastOr(bitmaps...)
 	}
 
+	return parAggregateOnRanges(parallelism, lKey, hKey, func(start, last uint32) *roaringArray64 {
+		ra := orOnRange(&bitmaps[0].highlowcontainer, &bitmaps[1].highlowcontainer, start, last)
+		for _, b := range bitmaps[2:] {
+			ra = iorOnRange(ra, &b.highlowcontainer, start, last)
+		}
+		return ra
+	})
+}
+
+// ParXor computes the symmetric difference (XOR) of all provided bitmaps in parallel,
+// where the parameter "parallelism" determines how many workers are to be used
+// (if it is set to 0, a default number of workers is chosen)
+// Like ParOr, it splits the high keys into ranges, each of them aggregated with FastXor.
+func ParXor(parallelism int, bitmaps ...*Bitmap) *Bitmap {
+	var lKey uint32 = maxUint32
+	var hKey uint32
+
+	bitmapsFiltered := make([]*Bitmap, 0, len(bitmaps))
+	for _, b := range bitmaps {
+		if !b.IsEmpty() {
+			bitmapsFiltered = append(bitmapsFiltered, b)
+		}
+	}
+	bitmaps = bitmapsFiltered
+
+	for _, b := range bitmaps {
+		lKey = minOfUint32(lKey, b.highlowcontainer.keys[0])
+		hKey = maxOfUint32(hKey, b.highlowcontainer.keys[b.highlowcontainer.size()-1])
+	}
+
+	if len(bitmaps) == 0 {
+		return New()
+	} else if len(bitmaps) == 1 {
+		return bitmaps[0].Clone()
+	} else if lKey == hKey {
+		return FastXor(bitmaps...)
+	}
+
+	return parAggregateOnRanges(parallelism, lKey, hKey, func(start, last uint32) *roaringArray64 {
+		views := make([]*Bitmap, len(bitmaps))
+		for i, b := range bitmaps {
+			views[i] = parRangeView(&b.highlowcontainer, start, last)
+		}
+		return &FastXor(views...).highlowcontainer
+	})
+}
+
+// ParAndNot computes the values of base that are in none of the subtrahends in parallel,
+// where the parameter "parallelism" determines how many workers are to be used
+// (if it is set to 0, a default number of workers is chosen)
+// Like ParOr, it splits the high keys of base into ranges, each of them aggregated with FastAndNot.
+func ParAndNot(parallelism int, base *Bitmap, subtrahends ...*Bitmap) *Bitmap {
+	if base.IsEmpty() {
+		return New()
+	} else if len(subtrahends) == 0 {
+		return base.Clone()
+	}
+
+	lKey := base.highlowcontainer.keys[0]
+	hKey := base.highlowcontainer.keys[base.highlowcontainer.size()-1]
+	if lKey == hKey {
+		return FastAndNot(base, subtrahends...)
+	}
+
+	return parAggregateOnRanges(parallelism, lKey, hKey, func(start, last uint32) *roaringArray64 {
+		views := make([]*Bitmap, len(subtrahends))
+		for i, b := range subtrahends {
+			views[i] = parRangeView(&b.highlowcontainer, start, last)
+		}
+		return &FastAndNot(parRangeView(&base.highlowcontainer, start, last), views...).highlowcontainer
+	})
+}
+
+// parRangeView returns a bitmap sharing the containers of ra whose keys are
+// between start and last, inclusive. The view must not be modified.
+func parRangeView(ra *roaringArray64, start, last uint32) *Bitmap {
+	lo := ra.getIndex(start)
+	if lo < 0 {
+		lo = -lo - 1
+	}
+	hi := ra.getIndex(last)
+	if hi < 0 {
+		hi = -hi - 1
+	} else {
+		hi++
+	}
+	if hi < lo {
+		// the last chunks may start after the last key
+		hi = lo
+	}
+
+	return &Bitmap{
+		roaringArray64{
+			keys:            ra.keys[lo:hi:hi],
+			containers:      ra.containers[lo:hi:hi],
+			needCopyOnWrite: ra.needCopyOnWrite[lo:hi:hi],
+			copyOnWrite:     ra.copyOnWrite,
+		},
+	}
+}
+
+// parAggregateOnRanges splits the keys from lKey to hKey into chunks, calls
+// aggregate on each chunk in parallel and concatenates the results, which
+// must only hold keys within their chunk.
+func parAggregateOnRanges(parallelism int, lKey, hKey uint32, aggregate func(start, last uint32) *roaringArray64) *Bitmap {
+	keyRange := uint64(hKey) - uint64(lKey) + 1
+
 	if parallelism == 0 {
 		parallelism = defaultWorkerCount
 	}
@@ -68,19 +453,14 @@ func ParOr(parallelism int, bitmaps ...*Bitmap) *Bitmap {
 	chunkSpecChan := make(chan parChunkSpec, minOfInt(maxOfInt(64, 2*parallelism), int(chunkCount)))
 	chunkChan := make(chan parChunk, minOfInt(32, int(chunkCount)))
 
-	orFunc := func() {
+	aggregateFunc := func() {
 		for spec := range chunkSpecChan {
-			ra := orOnRange(&bitmaps[0].highlowcontainer, &bitmaps[1].highlowcontainer, spec.start, spec.end)
-			for _, b := range bitmaps[2:] {
-				ra = iorOnRange(ra, &b.highlowcontainer, spec.start, spec.end)
-			}
-
-			chunkChan <- parChunk{ra, spec.idx}
+			chunkChan <- parChunk{aggregate(spec.start, spec.end), spec.idx}
 		}
 	}
 
 	for i := 0; i < parallelism; i++ {
-		go orFunc()
+		go aggregateFunc()
 	}
 
 	go func() {
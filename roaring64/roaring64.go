@@ -4,12 +4,15 @@ import (
 	"bytes"
 	"encoding/base64"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"strconv"
 
 	"github.com/RoaringBitmap/roaring/v2"
 	"github.com/RoaringBitmap/roaring/v2/internal"
+	"github.com/bits-and-blooms/bitset"
 )
 
 const (
@@ -89,7 +92,7 @@ func (rb *Bitmap) FromUnsafeBytes(data []byte) (p int64, err error) {
 	sizeBuf := make([]byte, 8)
 	_, err = stream.Read(sizeBuf)
 	if err != nil {
-		return 0, err
+		return 0, readError(0, -1, err)
 	}
 	size := binary.LittleEndian.Uint64(sizeBuf)
 
@@ -112,14 +115,15 @@ func (rb *Bitmap) FromUnsafeBytes(data []byte) (p int64, err error) {
 	for i := uint64(0); i < size; i++ {
 		keyBuf, err := stream.Next(4)
 		if err != nil {
-			return 0, fmt.Errorf("error in bitmap.UnsafeFromBytes: could not read key #%d: %w", i, err)
+			return 0, readError(stream.GetReadBytes(), int(i), fmt.Errorf("error in bitmap.UnsafeFromBytes: could not read key #%d: %w", i, err))
 		}
 		rb.highlowcontainer.keys[i] = binary.LittleEndian.Uint32(keyBuf)
 		rb.highlowcontainer.containers[i] = roaring.NewBitmap()
+		start := stream.GetReadBytes()
 		n, err := rb.highlowcontainer.containers[i].ReadFrom(stream)
 
 		if n == 0 || err != nil {
-			return int64(n), fmt.Errorf("Could not deserialize bitmap for key #%d: %s", i, err)
+			return int64(n), innerError(start, int(i), err)
 		}
 	}
 
@@ -134,8 +138,12 @@ func (rb *Bitmap) ReadFrom(stream io.Reader) (p int64, err error) {
 	sizeBuf := make([]byte, 8)
 	var n int
 	n, err = io.ReadFull(stream, sizeBuf)
+	if err == io.EOF {
+		// the stream ended cleanly, before the bitmap
+		return 0, err
+	}
 	if err != nil {
-		return int64(n), err
+		return int64(n), readError(int64(n), -1, err)
 	}
 	p += int64(n)
 	size := binary.LittleEndian.Uint64(sizeBuf)
@@ -159,7 +167,7 @@ func (rb *Bitmap) ReadFrom(stream io.Reader) (p int64, err error) {
 	for i := uint64(0); i < size; i++ {
 		n, err = io.ReadFull(stream, keyBuf)
 		if err != nil {
-			return int64(n), fmt.Errorf("error in bitmap.readFrom: could not read key #%d: %s", i, err)
+			return int64(n), readError(p+int64(n), int(i), fmt.Errorf("error in bitmap.readFrom: could not read key #%d: %w", i, err))
 		}
 		p += int64(n)
 		rb.highlowcontainer.keys[i] = binary.LittleEndian.Uint32(keyBuf)
@@ -167,13 +175,183 @@ func (rb *Bitmap) ReadFrom(stream io.Reader) (p int64, err error) {
 		n, err := rb.highlowcontainer.containers[i].ReadFrom(stream)
 
 		if n == 0 || err != nil {
-			return int64(n), fmt.Errorf("Could not deserialize bitmap for key #%d: %s", i, err)
+			return int64(n), innerError(p, int(i), err)
 		}
 		p += int64(n)
 	}
 	return p, nil
 }
 
+// ReadFromWithOptions reads a serialized version of this bitmap from stream, like ReadFrom,
+// but does not trust the input, see roaring.Bitmap.ReadFromWithOptions.
+// The limits of opts apply to the whole bitmap: MaxContainers and MaxCardinality count the
+// containers and values of all the inner 32-bit bitmaps, MaxAllocatedBytes is compared with
+// GetSizeInBytes. The number of inner bitmaps announced by the input is not used to allocate
+// memory upfront. The bitmap must not be used after an error.
+func (rb *Bitmap) ReadFromWithOptions(stream io.Reader, opts roaring.ReadOptions) (p int64, err error) {
+	sizeBuf := make([]byte, 8)
+	var n int
+	n, err = io.ReadFull(stream, sizeBuf)
+	if err == io.EOF {
+		// the stream ended cleanly, before the bitmap
+		return 0, err
+	}
+	if err != nil {
+		return int64(n), readError(int64(n), -1, err)
+	}
+	p += int64(n)
+	size := binary.LittleEndian.Uint64(sizeBuf)
+
+	if size > math.MaxUint32+1 {
+		// the keys cannot all be distinct
+		return p, &roaring.DeserializationError{Kind: roaring.InvalidContainerCount, Offset: 0, Container: -1,
+			Err: fmt.Errorf("error in bitmap.ReadFromWithOptions: %d bitmaps: %w", size, ErrKeySortOrder)}
+	}
+	// every inner bitmap needs its key and at least the 8 bytes of an empty bitmap
+	allocated := uint64(8)
+	if opts.MaxAllocatedBytes > 0 && (allocated > opts.MaxAllocatedBytes || size > (opts.MaxAllocatedBytes-allocated)/12) {
+		return p, &roaring.DeserializationError{Kind: roaring.LimitExceeded, Offset: 0, Container: -1,
+			Err: fmt.Errorf("error in bitmap.ReadFromWithOptions: %d bitmaps: %w", size, roaring.ErrAllocationLimit)}
+	}
+
+	rb.highlowcontainer.resize(0)
+	keyBuf := sizeBuf[:4]
+	var containers, cardinality uint64
+	for i := uint64(0); i < size; i++ {
+		n, err = io.ReadFull(stream, keyBuf)
+		p += int64(n)
+		if err != nil {
+			return p, readError(p, int(i), fmt.Errorf("error in bitmap.ReadFromWithOptions: could not read key #%d: %w", i, err))
+		}
+		key := binary.LittleEndian.Uint32(keyBuf)
+		if i > 0 && key <= rb.highlowcontainer.keys[i-1] {
+			return p, &roaring.DeserializationError{Kind: roaring.UnsortedKeys, Offset: p - 4, Container: int(i),
+				Err: fmt.Errorf("error in bitmap.ReadFromWithOptions: key #%d: %w", i, ErrKeySortOrder)}
+		}
+
+		allocated += 4
+		inner := roaring.NewBitmap()
+		n, err := inner.ReadFromWithOptions(stream, roaring.ReadOptions{
+			MaxContainers:     remainingLimit(opts.MaxContainers, containers),
+			MaxCardinality:    remainingLimit(opts.MaxCardinality, cardinality),
+			MaxAllocatedBytes: remainingLimit(opts.MaxAllocatedBytes, allocated),
+		})
+		if err != nil {
+			return p + n, innerError(p, int(i), err)
+		}
+		p += n
+
+		containers += uint64(inner.Stats().Containers)
+		cardinality += inner.GetCardinality()
+		allocated += inner.GetSizeInBytes()
+		var limitErr error
+		switch {
+		case opts.MaxContainers > 0 && containers > opts.MaxContainers:
+			limitErr = roaring.ErrContainerLimit
+		case opts.MaxCardinality > 0 && cardinality > opts.MaxCardinality:
+			limitErr = roaring.ErrCardinalityLimit
+		case opts.MaxAllocatedBytes > 0 && allocated > opts.MaxAllocatedBytes:
+			limitErr = roaring.ErrAllocationLimit
+		}
+		if limitErr != nil {
+			return p, &roaring.DeserializationError{Kind: roaring.LimitExceeded, Offset: p, Container: int(i),
+				Err: fmt.Errorf("error in bitmap.ReadFromWithOptions: bitmap for key #%d: %w", i, limitErr)}
+		}
+
+		rb.highlowcontainer.appendContainer(key, inner, false)
+	}
+	return p, nil
+}
+
+// remainingLimit returns what is left of limit once used is consumed, as a limit of
+// roaring.ReadOptions: 0 stays unlimited, and an exhausted limit becomes 1 so that the
+// caller detects an overflow after reading at most one more element.
+func remainingLimit(limit, used uint64) uint64 {
+	if limit == 0 {
+		return 0
+	}
+	if used >= limit {
+		return 1
+	}
+	return limit - used
+}
+
+// readError reports a failure of the stream at offset.
+func readError(offset int64, container int, err error) error {
+	kind := roaring.ReadFailure
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		kind = roaring.TruncatedInput
+	}
+	return &roaring.DeserializationError{Kind: kind, Offset: offset, Container: container, Err: err}
+}
+
+// innerError reports the failure err of the inner bitmap i, which starts at offset.
+// The error keeps the kind of the inner error, with an offset relative to the whole input.
+func innerError(offset int64, i int, err error) error {
+	var inner *roaring.DeserializationError
+	if !errors.As(err, &inner) {
+		return readError(offset, i, err)
+	}
+	return &roaring.DeserializationError{Kind: inner.Kind, Offset: offset + inner.Offset, Container: i, Err: err}
+}
+
+// FromBuffer creates a bitmap from its serialized version stored in buffer (E.g., as written by WriteTo).
+//
+// The format specification is available here:
+// https://github.com/RoaringBitmap/RoaringFormatSpec#extention-for-64-bit-implementations
+//
+// Every inner 32-bit bitmap is created with roaring.Bitmap.FromBuffer over a
+// slice of buf, so that container data is not copied and only the headers are
+// parsed. The same caveats as for roaring.Bitmap.FromBuffer apply: buf is
+// expected to be a constant, modifications of the resulting bitmap rely on
+// copy-on-write, and CloneCopyOnWriteContainers should be called on this
+// bitmap (and every bitmap derived from it) before buf becomes unavailable.
+func (rb *Bitmap) FromBuffer(buf []byte) (p int64, err error) {
+	if len(buf) < 8 {
+		return 0, readError(int64(len(buf)), -1, fmt.Errorf("error in bitmap.FromBuffer: could not read size: %w", io.ErrUnexpectedEOF))
+	}
+	size := binary.LittleEndian.Uint64(buf)
+	p = 8
+	// every inner bitmap requires at least a 4-byte key and a 4-byte cookie
+	if size > uint64(len(buf)-8)/8 {
+		return p, readError(int64(len(buf)), -1, fmt.Errorf("error in bitmap.FromBuffer: buffer too small for %d bitmaps: %w", size, io.ErrUnexpectedEOF))
+	}
+
+	rb.highlowcontainer.resize(0)
+	if cap(rb.highlowcontainer.keys) >= int(size) {
+		rb.highlowcontainer.keys = rb.highlowcontainer.keys[:size]
+	} else {
+		rb.highlowcontainer.keys = make([]uint32, size)
+	}
+	if cap(rb.highlowcontainer.containers) >= int(size) {
+		rb.highlowcontainer.containers = rb.highlowcontainer.containers[:size]
+	} else {
+		rb.highlowcontainer.containers = make([]*roaring.Bitmap, size)
+	}
+	if cap(rb.highlowcontainer.needCopyOnWrite) >= int(size) {
+		rb.highlowcontainer.needCopyOnWrite = rb.highlowcontainer.needCopyOnWrite[:size]
+	} else {
+		rb.highlowcontainer.needCopyOnWrite = make([]bool, size)
+	}
+	for i := uint64(0); i < size; i++ {
+		if int64(len(buf))-p < 4 {
+			rb.highlowcontainer.resize(int(i))
+			return p, readError(int64(len(buf)), int(i), fmt.Errorf("error in bitmap.FromBuffer: could not read key #%d: %w", i, io.ErrUnexpectedEOF))
+		}
+		rb.highlowcontainer.keys[i] = binary.LittleEndian.Uint32(buf[p:])
+		p += 4
+		rb.highlowcontainer.containers[i] = roaring.NewBitmap()
+		rb.highlowcontainer.needCopyOnWrite[i] = false
+		n, err := rb.highlowcontainer.containers[i].FromBuffer(buf[p:])
+		if n == 0 || err != nil {
+			rb.highlowcontainer.resize(int(i))
+			return p, innerError(p, int(i), err)
+		}
+		p += n
+	}
+	return p, nil
+}
+
 // MarshalBinary implements the encoding.BinaryMarshaler interface for the bitmap
 // (same as ToBytes)
 func (rb *Bitmap) MarshalBinary() ([]byte, error) {
@@ -229,6 +407,145 @@ func (rb *Bitmap) ToArray() []uint64 {
 	return array
 }
 
+// ToIntervals creates a new slice containing the maximal runs of consecutive values in the bitmap, in sorted order.
+// The End of an interval ending with math.MaxUint64 is 0.
+func (rb *Bitmap) ToIntervals() []roaring.Interval {
+	var intervals []roaring.Interval
+	rb.IterateRanges(func(start, endExclusive uint64) bool {
+		intervals = append(intervals, roaring.Interval{Start: start, End: endExclusive})
+		return true
+	})
+	return intervals
+}
+
+// denseContainerSize is the number of uint64 words covered by one 32-bit bitmap
+// when the Bitmap is stored as a dense bitmap.
+const denseContainerSize = 1 << (32 - 6)
+
+// DenseSize returns the size of the bitmap when stored as a dense bitmap,
+// in uint64 words. Note that a dense bitmap needs one bit for every integer
+// up to the maximum, so this grows quickly with large values.
+func (rb *Bitmap) DenseSize() uint64 {
+	if rb.highlowcontainer.size() == 0 {
+		return 0
+	}
+
+	maximum := rb.Maximum()
+	return maximum>>6 + 1
+}
+
+// ToDense returns a slice of uint64s representing the bitmap as a dense bitmap.
+// Useful to convert a roaring bitmap to a format that can be used by other libraries
+// like https://github.com/bits-and-blooms/bitset or https://github.com/kelindar/bitmap
+func (rb *Bitmap) ToDense() []uint64 {
+	sz := rb.DenseSize()
+	if sz == 0 {
+		return nil
+	}
+
+	bitmap := make([]uint64, sz)
+	rb.WriteDenseTo(bitmap)
+	return bitmap
+}
+
+// WriteDenseTo writes to a slice of uint64s representing the bitmap as a dense bitmap.
+// Callers are responsible for allocating enough space in the bitmap using DenseSize.
+func (rb *Bitmap) WriteDenseTo(bitmap []uint64) {
+	for i, c := range rb.highlowcontainer.containers {
+		offset := uint64(rb.highlowcontainer.keys[i]) * denseContainerSize
+		c.WriteDenseTo(bitmap[offset:])
+	}
+}
+
+// FromDense creates a bitmap from a slice of uint64s representing the bitmap as a dense bitmap.
+// Useful to convert bitmaps from libraries like https://github.com/bits-and-blooms/bitset or
+// https://github.com/kelindar/bitmap into roaring bitmaps fast and with convenience.
+//
+// The same caveats as for roaring.FromDense apply: no run containers are created, and
+// when doCopy is false the resulting bitmap may hold references into the slice.
+//
+// See also FromBitSet.
+func FromDense(bitmap []uint64, doCopy bool) *Bitmap {
+	rb := NewBitmap()
+	rb.FromDense(bitmap, doCopy)
+	return rb
+}
+
+// FromDense unmarshalls from a slice of uint64s representing the bitmap as a dense bitmap.
+// Callers are responsible for ensuring that the bitmap is empty before calling this function.
+//
+// See FromBitSet.
+func (rb *Bitmap) FromDense(bitmap []uint64, doCopy bool) {
+	var key uint32
+	for len(bitmap) > 0 {
+		hi := denseContainerSize
+		if len(bitmap) < hi {
+			hi = len(bitmap)
+		}
+
+		c := roaring.FromDense(bitmap[:hi], doCopy)
+		if !c.IsEmpty() {
+			rb.highlowcontainer.appendContainer(key, c, false)
+		}
+
+		bitmap = bitmap[hi:]
+		key++
+	}
+}
+
+// ToBitSet copies the content of the Bitmap into a bitset.BitSet instance
+func (rb *Bitmap) ToBitSet() *bitset.BitSet {
+	return bitset.From(rb.ToDense())
+}
+
+// FromBitSet creates a new Bitmap from a bitset.BitSet instance
+func FromBitSet(bitset *bitset.BitSet) *Bitmap {
+	return FromDense(bitset.Bytes(), false)
+}
+
+// Checksum computes a hash (currently FNV-1a) for a bitmap that is suitable for
+// using bitmaps as elements in hash sets or as keys in hash maps, as well as
+// generally quicker comparisons.
+// Equal bitmaps have equal checksums regardless of their container types: every
+// 32-bit bitmap is hashed as its runs of consecutive values, so the result is not
+// affected by RunOptimize or by how the bitmap was built.
+func (rb *Bitmap) Checksum() uint64 {
+	const (
+		offset = 14695981039346656037
+		prime  = 1099511628211
+	)
+
+	var buf [8]byte
+
+	hash := uint64(offset)
+
+	for _, key := range rb.highlowcontainer.keys {
+		binary.LittleEndian.PutUint32(buf[:], key)
+		for _, b := range buf[:4] {
+			hash ^= uint64(b)
+			hash *= prime
+		}
+	}
+
+	for _, c := range rb.highlowcontainer.containers {
+		// 0 separator
+		hash ^= 0
+		hash *= prime
+
+		c.IterateRanges(func(start, endExclusive uint64) bool {
+			binary.LittleEndian.PutUint32(buf[:], uint32(start))
+			binary.LittleEndian.PutUint32(buf[4:], uint32(endExclusive-1))
+			for _, b := range buf {
+				hash ^= uint64(b)
+				hash *= prime
+			}
+			return true
+		})
+	}
+
+	return hash
+}
+
 // GetSizeInBytes estimates the memory usage of the Bitmap. Note that this
 // might differ slightly from the amount of bytes required for persistent storage
 func (rb *Bitmap) GetSizeInBytes() uint64 {
@@ -239,6 +556,63 @@ func (rb *Bitmap) GetSizeInBytes() uint64 {
 	return size
 }
 
+// Iterate iterates over the bitmap, calling the given callback with each value in the bitmap.  If the callback returns
+// false, the iteration is halted.
+// The iteration results are undefined if the bitmap is modified (e.g., with Add or Remove).
+// There is no guarantee as to what order the values will be iterated.
+func (rb *Bitmap) Iterate(cb func(x uint64) bool) {
+	for i := 0; i < rb.highlowcontainer.size(); i++ {
+		hs := uint64(rb.highlowcontainer.getKeyAtIndex(i)) << 32
+		c := rb.highlowcontainer.getContainerAtIndex(i)
+
+		shouldContinue := true
+		c.Iterate(func(x uint32) bool {
+			shouldContinue = cb(uint64(x) | hs)
+			return shouldContinue
+		})
+
+		if !shouldContinue {
+			break
+		}
+	}
+}
+
+// IterateRanges iterates over the maximal runs of consecutive values in the bitmap, in sorted order,
+// calling the given callback with each run as the half-open interval [start, endExclusive).
+// A run ending with math.MaxUint64 is reported with an endExclusive of 0.
+// Runs spanning several 32-bit bitmaps are reported once. If the callback returns false, the iteration is halted.
+// The iteration results are undefined if the bitmap is modified (e.g., with Add or Remove).
+func (rb *Bitmap) IterateRanges(cb func(start, endExclusive uint64) bool) {
+	var pending roaring.Interval
+	hasPending := false
+	for i := 0; i < rb.highlowcontainer.size(); i++ {
+		hs := uint64(rb.highlowcontainer.getKeyAtIndex(i)) << 32
+		c := rb.highlowcontainer.getContainerAtIndex(i)
+
+		shouldContinue := true
+		c.IterateRanges(func(start, endExclusive uint64) bool {
+			run := roaring.Interval{Start: hs + start, End: hs + endExclusive}
+			if hasPending && pending.End == run.Start {
+				pending.End = run.End
+				return true
+			}
+			if hasPending && !cb(pending.Start, pending.End) {
+				shouldContinue = false
+				return false
+			}
+			pending, hasPending = run, true
+			return true
+		})
+
+		if !shouldContinue {
+			return
+		}
+	}
+	if hasPending {
+		cb(pending.Start, pending.End)
+	}
+}
+
 // String creates a string representation of the Bitmap
 func (rb *Bitmap) String() string {
 	// inspired by https://github.com/fzandona/goroar/
@@ -277,12 +651,37 @@ func (rb *Bitmap) ReverseIterator() IntIterable64 {
 	return newIntReverseIterator(rb)
 }
 
+// IteratorRange creates a new IntPeekable64 to iterate over the integers of the bitmap within [min, max), in sorted order;
+// the iterator becomes invalid if the bitmap is modified (e.g., with Add or Remove).
+func (rb *Bitmap) IteratorRange(min, max uint64) IntPeekable64 {
+	return newIntRangeIterator(rb, min, max)
+}
+
+// ReverseIteratorRange creates a new IntReversePeekable64 to iterate over the integers of the bitmap
+// within [min, max), in decreasing order;
+// the iterator becomes invalid if the bitmap is modified (e.g., with Add or Remove).
+func (rb *Bitmap) ReverseIteratorRange(min, max uint64) IntReversePeekable64 {
+	return newIntReverseRangeIterator(rb, min, max)
+}
+
 // ManyIterator creates a new ManyIntIterable to iterate over the integers contained in the bitmap, in sorted order;
 // the iterator becomes invalid if the bitmap is modified (e.g., with Add or Remove).
 func (rb *Bitmap) ManyIterator() ManyIntIterable64 {
 	return newManyIntIterator(rb)
 }
 
+// ReverseManyIterator creates a new ManyIntIterable64 to iterate over the integers contained in the bitmap,
+// in decreasing order; the iterator becomes invalid if the bitmap is modified (e.g., with Add or Remove).
+func (rb *Bitmap) ReverseManyIterator() ManyIntIterable64 {
+	return newReverseManyIntIterator(rb)
+}
+
+// Ranges creates a new RangeIterator64 to iterate over the maximal runs of consecutive values in the bitmap,
+// in sorted order; the iterator becomes invalid if the bitmap is modified (e.g., with Add or Remove).
+func (rb *Bitmap) Ranges() RangeIterator64 {
+	return newRangeIterator(rb)
+}
+
 // Clone creates a copy of the Bitmap
 func (rb *Bitmap) Clone() *Bitmap {
 	ptr := new(Bitmap)
@@ -318,6 +717,59 @@ func (rb *Bitmap) Equals(srb *Bitmap) bool {
 	return srb.highlowcontainer.equals(rb.highlowcontainer)
 }
 
+// AddOffset adds the value 'offset' to each and every value in a bitmap, generating a new bitmap in the process.
+// If offset + element is outside of the range [0,2^64), then the element will be dropped.
+//
+// The offset is split into a shift of the high 32-bit keys and a shift of the low 32 bits,
+// which is applied to each inner bitmap as a whole with roaring.AddOffset64. Values that
+// overflow the low 32 bits are carried into the next key.
+func AddOffset(x *Bitmap, offset int64) (answer *Bitmap) {
+	// floor division, so that inOffset is always non-negative
+	keyOffset := offset >> 32
+	inOffset := int64(uint32(offset))
+
+	answer = New()
+
+	for pos := 0; pos < x.highlowcontainer.size(); pos++ {
+		key := int64(x.highlowcontainer.getKeyAtIndex(pos)) + keyOffset
+		if key+1 < 0 || key > maxUint32 {
+			continue
+		}
+
+		c := x.highlowcontainer.getContainerAtIndex(pos)
+		if c.IsEmpty() {
+			continue
+		}
+		if inOffset == 0 {
+			if key >= 0 {
+				answer.highlowcontainer.appendContainer(uint32(key), c.Clone(), false)
+			}
+			continue
+		}
+
+		// values of c shifted by inOffset stay below 2^32 up to (and including) threshold
+		threshold := uint64(maxUint32 - inOffset)
+
+		if key >= 0 && uint64(c.Minimum()) <= threshold {
+			lo := roaring.AddOffset64(c, inOffset)
+
+			curSize := answer.highlowcontainer.size()
+			if curSize > 0 && int64(answer.highlowcontainer.getKeyAtIndex(curSize-1)) == key {
+				answer.highlowcontainer.getContainerAtIndex(curSize - 1).Or(lo)
+			} else {
+				answer.highlowcontainer.appendContainer(uint32(key), lo, false)
+			}
+		}
+
+		if key+1 <= maxUint32 && uint64(c.Maximum()) > threshold {
+			hi := roaring.AddOffset64(c, inOffset-(1<<32))
+			answer.highlowcontainer.appendContainer(uint32(key+1), hi, false)
+		}
+	}
+
+	return answer
+}
+
 // Add the integer x to the bitmap
 func (rb *Bitmap) Add(x uint64) {
 	hb := highbits(x)
@@ -410,6 +862,83 @@ func (rb *Bitmap) Rank(x uint64) uint64 {
 	return size
 }
 
+// RangeCardinality returns the number of integers in the bitmap which are in the
+// range [rangeStart, rangeEnd). Inner bitmaps entirely within the range are counted
+// from their cardinality: only the inner bitmaps at the boundaries are inspected.
+func (rb *Bitmap) RangeCardinality(rangeStart, rangeEnd uint64) uint64 {
+	if rangeStart >= rangeEnd {
+		return 0
+	}
+
+	hbStart := highbits(rangeStart)
+	lbStart := uint64(lowbits(rangeStart))
+	hbLast := highbits(rangeEnd - 1)
+	lbEnd := uint64(lowbits(rangeEnd-1)) + 1
+
+	answer := uint64(0)
+	i := rb.highlowcontainer.getIndex(hbStart)
+	if i < 0 {
+		i = -i - 1
+	}
+	for ; i < rb.highlowcontainer.size(); i++ {
+		key := rb.highlowcontainer.getKeyAtIndex(i)
+		if key > hbLast {
+			break
+		}
+		start, end := uint64(0), uint64(maxUint32)+1
+		if key == hbStart {
+			start = lbStart
+		}
+		if key == hbLast {
+			end = lbEnd
+		}
+		c := rb.highlowcontainer.getContainerAtIndex(i)
+		if start == 0 && end == uint64(maxUint32)+1 {
+			answer += c.GetCardinality()
+		} else {
+			answer += c.RangeCardinality(start, end)
+		}
+	}
+	return answer
+}
+
+// ContainsRange returns true if every integer in the range [rangeStart, rangeEnd)
+// is in the bitmap. An empty range is always contained.
+func (rb *Bitmap) ContainsRange(rangeStart, rangeEnd uint64) bool {
+	if rangeStart >= rangeEnd {
+		return true
+	}
+
+	hbStart := highbits(rangeStart)
+	lbStart := uint64(lowbits(rangeStart))
+	hbLast := highbits(rangeEnd - 1)
+	lbEnd := uint64(lowbits(rangeEnd-1)) + 1
+
+	// every key between hbStart and hbLast must be present
+	first := rb.highlowcontainer.getIndex(hbStart)
+	if first < 0 || uint64(hbLast-hbStart) >= uint64(rb.highlowcontainer.size()-first) {
+		return false
+	}
+	last := first + int(hbLast-hbStart)
+	if rb.highlowcontainer.getKeyAtIndex(last) != hbLast {
+		return false
+	}
+
+	for i := first; i <= last; i++ {
+		start, end := uint64(0), uint64(maxUint32)+1
+		if i == first {
+			start = lbStart
+		}
+		if i == last {
+			end = lbEnd
+		}
+		if !rb.highlowcontainer.getContainerAtIndex(i).ContainsRange(start, end) {
+			return false
+		}
+	}
+	return true
+}
+
 // Select returns the xth integer in the bitmap
 func (rb *Bitmap) Select(x uint64) (uint64, error) {
 	cardinality := rb.GetCardinality()
@@ -513,8 +1042,7 @@ main:
 					}
 					s2 = x2.highlowcontainer.getKeyAtIndex(pos2)
 				} else {
-					// TODO: could be faster if we did not have to materialize the container
-					answer += roaring.Or(rb.highlowcontainer.getContainerAtIndex(pos1), x2.highlowcontainer.getContainerAtIndex(pos2)).GetCardinality()
+					answer += rb.highlowcontainer.getContainerAtIndex(pos1).OrCardinality(x2.highlowcontainer.getContainerAtIndex(pos2))
 					pos1++
 					pos2++
 					if (pos1 == length1) || (pos2 == length2) {
@@ -583,6 +1111,64 @@ main:
 	return answer
 }
 
+// AndNotCardinality returns the cardinality of the difference between two bitmaps, bitmaps are not modified
+func (rb *Bitmap) AndNotCardinality(x2 *Bitmap) uint64 {
+	return rb.GetCardinality() - rb.AndCardinality(x2)
+}
+
+// XorCardinality returns the cardinality of the symmetric difference between two bitmaps, bitmaps are not modified
+func (rb *Bitmap) XorCardinality(x2 *Bitmap) uint64 {
+	return rb.GetCardinality() + x2.GetCardinality() - 2*rb.AndCardinality(x2)
+}
+
+// Jaccard returns the Jaccard index of two bitmaps, that is the cardinality
+// of their intersection divided by the cardinality of their union. It returns
+// 0 when both bitmaps are empty. Bitmaps are not modified.
+func (rb *Bitmap) Jaccard(x2 *Bitmap) float64 {
+	inter := rb.AndCardinality(x2)
+	union := rb.GetCardinality() + x2.GetCardinality() - inter
+	if union == 0 {
+		return 0
+	}
+	return float64(inter) / float64(union)
+}
+
+// Dice returns the Sørensen–Dice coefficient of two bitmaps, that is twice
+// the cardinality of their intersection divided by the sum of their
+// cardinalities. It returns 0 when both bitmaps are empty. Bitmaps are not
+// modified.
+func (rb *Bitmap) Dice(x2 *Bitmap) float64 {
+	total := rb.GetCardinality() + x2.GetCardinality()
+	if total == 0 {
+		return 0
+	}
+	return 2 * float64(rb.AndCardinality(x2)) / float64(total)
+}
+
+// OverlapCoefficient returns the overlap coefficient of two bitmaps, that is
+// the cardinality of their intersection divided by the smaller of their
+// cardinalities. It returns 0 when either bitmap is empty. Bitmaps are not
+// modified.
+func (rb *Bitmap) OverlapCoefficient(x2 *Bitmap) float64 {
+	smaller := rb.GetCardinality()
+	if card := x2.GetCardinality(); card < smaller {
+		smaller = card
+	}
+	if smaller == 0 {
+		return 0
+	}
+	return float64(rb.AndCardinality(x2)) / float64(smaller)
+}
+
+// IntersectsWithInterval checks whether a bitmap 'rb' and an open interval '[x,y)' intersect.
+func (rb *Bitmap) IntersectsWithInterval(x, y uint64) bool {
+	if x >= y {
+		return false
+	}
+	v, ok := rb.NextValue(x)
+	return ok && v < y
+}
+
 // Intersects checks whether two bitmap intersects, bitmaps are not modified
 func (rb *Bitmap) Intersects(x2 *Bitmap) bool {
 	pos1 := 0
@@ -630,6 +1216,103 @@ main:
 	return false
 }
 
+// IsSubsetOf returns true if every value of the current bitmap is also in
+// other, bitmaps are not modified
+func (rb *Bitmap) IsSubsetOf(other *Bitmap) bool {
+	length1 := rb.highlowcontainer.size()
+	length2 := other.highlowcontainer.size()
+	if length1 > length2 {
+		return false
+	}
+
+	pos2 := -1
+	for pos1 := 0; pos1 < length1; pos1++ {
+		key := rb.highlowcontainer.getKeyAtIndex(pos1)
+		pos2 = other.highlowcontainer.advanceUntil(key, pos2)
+		if pos2 == length2 || other.highlowcontainer.getKeyAtIndex(pos2) != key {
+			return false
+		}
+		c1 := rb.highlowcontainer.getContainerAtIndex(pos1)
+		c2 := other.highlowcontainer.getContainerAtIndex(pos2)
+		if !c1.IsSubsetOf(c2) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsStrictSubsetOf returns true if the current bitmap is a subset of other
+// and other contains at least one value that is not in the current bitmap,
+// bitmaps are not modified
+func (rb *Bitmap) IsStrictSubsetOf(other *Bitmap) bool {
+	return rb.IsSubsetOf(other) && rb.GetCardinality() < other.GetCardinality()
+}
+
+// Relation returns the set relation between a and b, bitmaps are not modified.
+// Containment takes precedence over disjointness: an empty bitmap is a
+// roaring.Subset of any non-empty bitmap, and two empty bitmaps are
+// roaring.Equal. The comparison stops as soon as the bitmaps are known to be
+// roaring.Overlapping.
+func Relation(a, b *Bitmap) roaring.SetRelation {
+	pos1 := 0
+	pos2 := 0
+	length1 := a.highlowcontainer.size()
+	length2 := b.highlowcontainer.size()
+
+	aInB, bInA, intersects := true, true, false
+	for pos1 < length1 && pos2 < length2 {
+		s1 := a.highlowcontainer.getKeyAtIndex(pos1)
+		s2 := b.highlowcontainer.getKeyAtIndex(pos2)
+		if s1 < s2 {
+			aInB = false
+			pos1++
+		} else if s1 > s2 {
+			bInA = false
+			pos2++
+		} else {
+			c1 := a.highlowcontainer.getContainerAtIndex(pos1)
+			c2 := b.highlowcontainer.getContainerAtIndex(pos2)
+			switch roaring.Relation(c1, c2) {
+			case roaring.Overlapping:
+				return roaring.Overlapping
+			case roaring.Disjoint:
+				aInB, bInA = false, false
+			case roaring.Subset:
+				bInA = false
+				intersects = intersects || !c1.IsEmpty()
+			case roaring.Superset:
+				aInB = false
+				intersects = intersects || !c2.IsEmpty()
+			case roaring.Equal:
+				intersects = intersects || !c1.IsEmpty()
+			}
+			pos1++
+			pos2++
+		}
+		if !aInB && !bInA && intersects {
+			return roaring.Overlapping
+		}
+	}
+	if pos1 < length1 {
+		aInB = false
+	}
+	if pos2 < length2 {
+		bInA = false
+	}
+
+	switch {
+	case aInB && bInA:
+		return roaring.Equal
+	case aInB:
+		return roaring.Subset
+	case bInA:
+		return roaring.Superset
+	case intersects:
+		return roaring.Overlapping
+	}
+	return roaring.Disjoint
+}
+
 // Xor computes the symmetric difference between two bitmaps and stores the result in the current bitmap
 func (rb *Bitmap) Xor(x2 *Bitmap) {
 	pos1 := 0
@@ -1004,6 +1687,22 @@ func BitmapOf(dat ...uint64) *Bitmap {
 	return ans
 }
 
+// FromIntervals generates a new bitmap holding the values of all the given intervals,
+// which may overlap and need not be sorted. An interval with a non-zero Start and
+// an End of 0 runs up to math.MaxUint64 included, as returned by ToIntervals.
+func FromIntervals(intervals ...roaring.Interval) *Bitmap {
+	ans := NewBitmap()
+	for _, iv := range intervals {
+		if iv.End == 0 && iv.Start > 0 {
+			ans.AddRange(iv.Start, math.MaxUint64)
+			ans.Add(math.MaxUint64)
+		} else {
+			ans.AddRange(iv.Start, iv.End)
+		}
+	}
+	return ans
+}
+
 // Flip negates the bits in the given range (i.e., [rangeStart,rangeEnd)), any integer present in this range and in the bitmap is removed,
 // and any integer present in the range and not in the bitmap is added.
 func (rb *Bitmap) Flip(rangeStart, rangeEnd uint64) {
@@ -1208,6 +1907,118 @@ func (rb *Bitmap) CloneCopyOnWriteContainers() {
 	rb.highlowcontainer.cloneCopyOnWriteContainers()
 }
 
+// NextValue returns the smallest value in the bitmap that is larger than or
+// equal to target. The boolean is false if there is no such value.
+// This function should not be used inside a performance-sensitive loop:
+// prefer iterators if performance is a concern.
+func (rb *Bitmap) NextValue(target uint64) (uint64, bool) {
+	ra := &rb.highlowcontainer
+	originalKey := highbits(target)
+	for i := ra.advanceUntil(originalKey, -1); i < ra.size(); i++ {
+		key := ra.getKeyAtIndex(i)
+		c := ra.getContainerAtIndex(i)
+		if key == originalKey {
+			if v := c.NextValue(lowbits(target)); v >= 0 {
+				return uint64(key)<<32 | uint64(v), true
+			}
+		} else if !c.IsEmpty() {
+			// past the key of target: the minimum of the container is the answer
+			return uint64(key)<<32 | uint64(c.Minimum()), true
+		}
+	}
+	return 0, false
+}
+
+// PreviousValue returns the largest value in the bitmap that is smaller than or
+// equal to target. The boolean is false if there is no such value.
+// This function should not be used inside a performance-sensitive loop:
+// prefer iterators if performance is a concern.
+func (rb *Bitmap) PreviousValue(target uint64) (uint64, bool) {
+	ra := &rb.highlowcontainer
+	originalKey := highbits(target)
+	i := ra.advanceUntil(originalKey, -1)
+	if i == ra.size() || ra.getKeyAtIndex(i) > originalKey {
+		i--
+	}
+	for ; i >= 0; i-- {
+		key := ra.getKeyAtIndex(i)
+		c := ra.getContainerAtIndex(i)
+		if key == originalKey {
+			if v := c.PreviousValue(lowbits(target)); v >= 0 {
+				return uint64(key)<<32 | uint64(v), true
+			}
+		} else if !c.IsEmpty() {
+			// before the key of target: the maximum of the container is the answer
+			return uint64(key)<<32 | uint64(c.Maximum()), true
+		}
+	}
+	return 0, false
+}
+
+// NextAbsentValue returns the smallest value missing from the bitmap that is
+// larger than or equal to target. The boolean is false if there is no such value.
+// This function should not be used inside a performance-sensitive loop:
+// prefer iterators if performance is a concern.
+func (rb *Bitmap) NextAbsentValue(target uint64) (uint64, bool) {
+	ra := &rb.highlowcontainer
+	key := highbits(target)
+	i := ra.getIndex(key)
+	if i < 0 {
+		// no container for target, so target itself is missing
+		return target, true
+	}
+
+	query := lowbits(target)
+	for {
+		if v := ra.getContainerAtIndex(i).NextAbsentValue(query); v >= 0 {
+			return uint64(key)<<32 | uint64(v), true
+		}
+		// every value from query onwards is present in this container
+		if key == maxUint32 {
+			return 0, false
+		}
+		i++
+		if i == ra.size() || ra.getKeyAtIndex(i) != key+1 {
+			// the next key is missing altogether
+			return uint64(key+1) << 32, true
+		}
+		key++
+		query = 0
+	}
+}
+
+// PreviousAbsentValue returns the largest value missing from the bitmap that is
+// smaller than or equal to target. The boolean is false if there is no such value.
+// This function should not be used inside a performance-sensitive loop:
+// prefer iterators if performance is a concern.
+func (rb *Bitmap) PreviousAbsentValue(target uint64) (uint64, bool) {
+	ra := &rb.highlowcontainer
+	key := highbits(target)
+	i := ra.getIndex(key)
+	if i < 0 {
+		// no container for target, so target itself is missing
+		return target, true
+	}
+
+	query := lowbits(target)
+	for {
+		if v := ra.getContainerAtIndex(i).PreviousAbsentValue(query); v >= 0 {
+			return uint64(key)<<32 | uint64(v), true
+		}
+		// every value up to query is present in this container
+		if key == 0 {
+			return 0, false
+		}
+		i--
+		if i < 0 || ra.getKeyAtIndex(i) != key-1 {
+			// the previous key is missing altogether
+			return uint64(key)<<32 - 1, true
+		}
+		key--
+		query = maxUint32
+	}
+}
+
 // FlipInt calls Flip after casting the parameters (convenience method)
 func FlipInt(bm *Bitmap, rangeStart, rangeEnd int) *Bitmap {
 	return Flip(bm, uint64(rangeStart), uint64(rangeEnd))
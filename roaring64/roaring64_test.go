@@ -1,6 +1,7 @@
 package roaring64
 
 import (
+	"bytes"
 	"math"
 	"math/rand"
 	"strconv"
@@ -9,6 +10,7 @@ import (
 	"github.com/RoaringBitmap/roaring/v2"
 	"github.com/bits-and-blooms/bitset"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestRoaringIntervalCheck(t *testing.T) {
@@ -1683,6 +1685,33 @@ func TestNextManyOfAddRangeAcrossContainers(t *testing.T) {
 	assert.Equal(t, 0, n)
 }
 
+func TestReverseManyIterator(t *testing.T) {
+	rb := NewBitmap()
+	rb.AddMany([]uint64{1, 2, 15, 9999, roaring.MaxUint32, 1<<32 + 5, math.MaxUint64})
+	rb.AddRange(1<<33+100, 1<<33+300)
+	for i := uint64(0); i < 10000; i += 2 {
+		rb.Add(3<<32 + i)
+	}
+	rb.RunOptimize()
+
+	expected := rb.ToArray()
+	for i, j := 0, len(expected)-1; i < j; i, j = i+1, j-1 {
+		expected[i], expected[j] = expected[j], expected[i]
+	}
+
+	for _, size := range []int{1, 3, 64, 1000, len(expected) + 1} {
+		it := rb.ReverseManyIterator()
+		buf := make([]uint64, size)
+		var got []uint64
+		for n := it.NextMany(buf); n > 0; n = it.NextMany(buf) {
+			got = append(got, buf[:n]...)
+		}
+		assert.Equal(t, expected, got, "buffer size %d", size)
+	}
+
+	assert.Equal(t, 0, NewBitmap().ReverseManyIterator().NextMany(make([]uint64, 8)))
+}
+
 func TestDoubleAdd(t *testing.T) {
 	t.Run("doubleadd ", func(t *testing.T) {
 		rb := NewBitmap()
@@ -2078,3 +2107,488 @@ func TestRoaringArray64SortOrder(t *testing.T) {
 		assert.True(t, bm.highlowcontainer.checkKeysSorted())
 	})
 }
+
+func TestNextAndPreviousValue64(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		bm := New()
+		_, ok := bm.NextValue(0)
+		assert.False(t, ok)
+		_, ok = bm.PreviousValue(math.MaxUint64)
+		assert.False(t, ok)
+
+		v, ok := bm.NextAbsentValue(42)
+		assert.True(t, ok)
+		assert.EqualValues(t, 42, v)
+		v, ok = bm.PreviousAbsentValue(42)
+		assert.True(t, ok)
+		assert.EqualValues(t, 42, v)
+	})
+
+	t.Run("across keys", func(t *testing.T) {
+		bm := BitmapOf(5, 1<<32|7, 9<<32, math.MaxUint64)
+
+		v, ok := bm.NextValue(6)
+		assert.True(t, ok)
+		assert.EqualValues(t, uint64(1<<32|7), v)
+		v, ok = bm.NextValue(1<<32 | 8)
+		assert.True(t, ok)
+		assert.EqualValues(t, uint64(9<<32), v)
+		v, ok = bm.NextValue(9<<32 + 1)
+		assert.True(t, ok)
+		assert.EqualValues(t, uint64(math.MaxUint64), v)
+
+		v, ok = bm.PreviousValue(9<<32 - 1)
+		assert.True(t, ok)
+		assert.EqualValues(t, uint64(1<<32|7), v)
+		v, ok = bm.PreviousValue(1 << 32)
+		assert.True(t, ok)
+		assert.EqualValues(t, 5, v)
+		_, ok = bm.PreviousValue(4)
+		assert.False(t, ok)
+		v, ok = bm.PreviousValue(math.MaxUint64 - 1)
+		assert.True(t, ok)
+		assert.EqualValues(t, uint64(9<<32), v)
+	})
+
+	t.Run("absent across keys", func(t *testing.T) {
+		bm := New()
+		bm.AddRange(1<<32-10, 3<<32+5)
+
+		v, ok := bm.NextAbsentValue(1<<32 - 10)
+		assert.True(t, ok)
+		assert.EqualValues(t, uint64(3<<32+5), v)
+		v, ok = bm.PreviousAbsentValue(3<<32 + 4)
+		assert.True(t, ok)
+		assert.EqualValues(t, uint64(1<<32-11), v)
+
+		bm.AddRange(5<<32, 6<<32)
+		v, ok = bm.NextAbsentValue(5<<32 + 3)
+		assert.True(t, ok)
+		assert.EqualValues(t, uint64(6<<32), v)
+		v, ok = bm.PreviousAbsentValue(6<<32 - 1)
+		assert.True(t, ok)
+		assert.EqualValues(t, uint64(5<<32-1), v)
+	})
+
+	t.Run("absent at the ends", func(t *testing.T) {
+		bm := New()
+		bm.AddRange(math.MaxUint64-100, math.MaxUint64)
+		bm.Add(math.MaxUint64)
+		_, ok := bm.NextAbsentValue(math.MaxUint64 - 100)
+		assert.False(t, ok)
+
+		bm = New()
+		bm.AddRange(0, 1<<32+100)
+		_, ok = bm.PreviousAbsentValue(1<<32 + 99)
+		assert.False(t, ok)
+		v, ok := bm.NextAbsentValue(0)
+		assert.True(t, ok)
+		assert.EqualValues(t, uint64(1<<32+100), v)
+	})
+
+	t.Run("randomized", func(t *testing.T) {
+		r := rand.New(rand.NewSource(0))
+		bm := New()
+		for i := 0; i < 20; i++ {
+			start := uint64(r.Intn(6)+1)<<32 + uint64(r.Intn(1<<17)) - 1<<16
+			bm.AddRange(start, start+uint64(r.Intn(70000)))
+		}
+		bm.RunOptimize()
+		values := bm.ToArray()
+
+		for i := 0; i < 2000; i++ {
+			target := values[r.Intn(len(values))] + uint64(r.Intn(3)) - 1
+
+			idx := 0
+			for idx < len(values) && values[idx] < target {
+				idx++
+			}
+			v, ok := bm.NextValue(target)
+			if idx < len(values) {
+				assert.True(t, ok)
+				assert.Equal(t, values[idx], v)
+			} else {
+				assert.False(t, ok)
+			}
+
+			if idx < len(values) && values[idx] == target {
+				idx++
+			}
+			v, ok = bm.PreviousValue(target)
+			if idx > 0 {
+				assert.True(t, ok)
+				assert.Equal(t, values[idx-1], v)
+			} else {
+				assert.False(t, ok)
+			}
+
+			expected := target
+			for bm.Contains(expected) {
+				expected++
+			}
+			v, ok = bm.NextAbsentValue(target)
+			assert.True(t, ok)
+			assert.Equal(t, expected, v)
+
+			expected = target
+			for bm.Contains(expected) {
+				expected--
+			}
+			v, ok = bm.PreviousAbsentValue(target)
+			assert.True(t, ok)
+			assert.Equal(t, expected, v)
+		}
+	})
+}
+
+func TestIntersectsWithInterval64(t *testing.T) {
+	bm := BitmapOf(10, 1<<40)
+
+	assert.False(t, bm.IntersectsWithInterval(0, 10))
+	assert.True(t, bm.IntersectsWithInterval(0, 11))
+	assert.True(t, bm.IntersectsWithInterval(10, 11))
+	assert.False(t, bm.IntersectsWithInterval(11, 1<<40))
+	assert.True(t, bm.IntersectsWithInterval(11, 1<<40+1))
+	assert.False(t, bm.IntersectsWithInterval(1<<40+1, math.MaxUint64))
+	assert.False(t, bm.IntersectsWithInterval(20, 10))
+}
+
+func TestIterate64(t *testing.T) {
+	bm := BitmapOf(1, 2, 1<<32, 1<<33, math.MaxUint64)
+
+	var values []uint64
+	bm.Iterate(func(x uint64) bool {
+		values = append(values, x)
+		return true
+	})
+	assert.Equal(t, bm.ToArray(), values)
+
+	values = values[:0]
+	bm.Iterate(func(x uint64) bool {
+		values = append(values, x)
+		return x < 1<<32
+	})
+	assert.Equal(t, []uint64{1, 2, 1 << 32}, values)
+}
+
+func TestDense64(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		rb := New()
+		assert.EqualValues(t, 0, rb.DenseSize())
+		assert.Nil(t, rb.ToDense())
+		assert.True(t, FromDense(nil, false).IsEmpty())
+	})
+
+	t.Run("round trip", func(t *testing.T) {
+		rb := New()
+		rb.AddRange(0, 100000)
+		rb.AddMany([]uint64{1 << 20, 1<<20 + 63, 1<<20 + 64, 3<<20 + 70000})
+		for i := uint64(0); i < 100000; i += 3 {
+			rb.Add(2<<20 + i)
+		}
+		rb.RunOptimize()
+
+		dense := rb.ToDense()
+		require.Len(t, dense, int(rb.DenseSize()))
+		assert.EqualValues(t, (3<<20+70000)/64+1, len(dense))
+		assert.EqualValues(t, 1|uint64(1)<<63, dense[1<<14])
+		assert.EqualValues(t, 1, dense[1<<14+1])
+
+		for _, doCopy := range []bool{false, true} {
+			other := FromDense(dense, doCopy)
+			assert.True(t, other.Equals(rb))
+			assert.NoError(t, other.Validate())
+		}
+	})
+
+	t.Run("bitset", func(t *testing.T) {
+		rb := BitmapOf(5, 1<<20+7, 1<<21)
+		bs := rb.ToBitSet()
+		assert.EqualValues(t, 3, bs.Count())
+		assert.True(t, bs.Test(1<<20+7))
+		assert.True(t, FromBitSet(bs).Equals(rb))
+	})
+}
+
+func TestChecksum64(t *testing.T) {
+	rb := New()
+	for i := uint64(0); i < 10000; i++ {
+		rb.Add(i)
+		rb.Add(5<<32 + i)
+	}
+	rb.Add(7 << 32)
+	require.False(t, rb.HasRunCompression())
+
+	runs := rb.Clone()
+	runs.RunOptimize()
+	require.True(t, runs.HasRunCompression())
+	assert.Equal(t, rb.Checksum(), runs.Checksum())
+	assert.Equal(t, uint64(0xd3e630dc7344e265), rb.Checksum())
+
+	assert.Equal(t, rb.Checksum(), BitmapOf(rb.ToArray()...).Checksum())
+
+	other := rb.Clone()
+	other.Remove(7 << 32)
+	other.Add(8 << 32)
+	assert.NotEqual(t, rb.Checksum(), other.Checksum())
+	assert.NotEqual(t, New().Checksum(), BitmapOf(0).Checksum())
+}
+
+func TestAddOffset64(t *testing.T) {
+	rb := New()
+	rb.AddRange(0, 10)
+	rb.AddRange(maxUint32-5, maxUint32+5)
+	rb.AddMany([]uint64{3<<32 + 70000, 3<<32 + maxUint32, 9 << 32})
+	for i := uint64(0); i < 100000; i += 7 {
+		rb.Add(5<<32 + maxUint32 - 50000 + i)
+	}
+	rb.RunOptimize()
+	values := rb.ToArray()
+
+	expected := func(offset int64) *Bitmap {
+		result := New()
+		for _, v := range values {
+			switch {
+			case offset >= 0 && v+uint64(offset) >= v:
+				result.Add(v + uint64(offset))
+			case offset < 0 && v >= uint64(-offset):
+				result.Add(v - uint64(-offset))
+			}
+		}
+		return result
+	}
+
+	for _, offset := range []int64{
+		0, 1, 7, -1, -7, 1 << 16, 1 << 32, -(1 << 32), 1<<32 + 3, -(1<<32 + 3),
+		maxUint32, -maxUint32, 50000, -50000, 1 << 40, -(1 << 40), math.MaxInt64, math.MinInt64,
+	} {
+		answer := AddOffset(rb, offset)
+		assert.True(t, answer.Equals(expected(offset)), "offset %d", offset)
+	}
+
+	t.Run("does not modify the input", func(t *testing.T) {
+		answer := AddOffset(rb, 0)
+		answer.Add(12345)
+		assert.False(t, rb.Contains(12345))
+		assert.Equal(t, values, rb.ToArray())
+	})
+
+	t.Run("empty inner bitmap", func(t *testing.T) {
+		// two inner bitmaps, the second one is empty
+		var buf bytes.Buffer
+		buf.Write([]byte{2, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0})
+		_, err := roaring.BitmapOf(5, maxUint32).WriteTo(&buf)
+		require.NoError(t, err)
+		buf.Write([]byte{3, 0, 0, 0})
+		_, err = roaring.New().WriteTo(&buf)
+		require.NoError(t, err)
+
+		withEmpty := New()
+		_, err = withEmpty.FromBuffer(buf.Bytes())
+		require.NoError(t, err)
+		require.Equal(t, 2, withEmpty.highlowcontainer.size())
+
+		for _, offset := range []int64{0, 1, -1, 1 << 32, -(1 << 32)} {
+			answer := AddOffset(withEmpty, offset)
+			assert.True(t, answer.Equals(AddOffset(BitmapOf(1<<32+5, 1<<32+maxUint32), offset)), "offset %d", offset)
+			for _, c := range answer.highlowcontainer.containers {
+				assert.False(t, c.IsEmpty(), "offset %d", offset)
+			}
+		}
+	})
+
+	t.Run("high end", func(t *testing.T) {
+		top := BitmapOf(math.MaxUint64-1, math.MaxUint64)
+		assert.True(t, AddOffset(top, 1).Equals(BitmapOf(math.MaxUint64)))
+		assert.True(t, AddOffset(top, 2).IsEmpty())
+		assert.True(t, AddOffset(top, -(1<<32)).Equals(BitmapOf(math.MaxUint64-1-(1<<32), math.MaxUint64-(1<<32))))
+	})
+}
+
+func TestRangeCardinality64(t *testing.T) {
+	small := New()
+	small.AddRange(10, 100)
+	small.AddRange(1<<32-5, 1<<32+5)
+	small.AddMany([]uint64{3 << 32, 3<<32 + 70000, math.MaxUint64})
+	small.RunOptimize()
+
+	naive := func(start, end uint64) uint64 {
+		count := uint64(0)
+		small.Iterate(func(x uint64) bool {
+			if x >= start && x < end {
+				count++
+			}
+			return true
+		})
+		return count
+	}
+
+	ranges := [][2]uint64{
+		{0, 0}, {5, 3}, {0, 10}, {0, 11}, {10, 100}, {50, 60}, {1<<32 - 6, 1<<32 + 6}, {1<<32 - 5, 1<<32 + 5},
+		{1<<32 - 4, 1<<32 + 4}, {3 << 32, 3<<32 + 1}, {3<<32 + 1, 3<<32 + 70000}, {2 << 32, 4 << 32},
+		{0, math.MaxUint64}, {math.MaxUint64 - 1, math.MaxUint64},
+	}
+	for _, r := range ranges {
+		assert.Equal(t, naive(r[0], r[1]), small.RangeCardinality(r[0], r[1]), "range %v", r)
+	}
+
+	assert.True(t, small.ContainsRange(5, 5))
+	assert.True(t, small.ContainsRange(10, 100))
+	assert.False(t, small.ContainsRange(10, 101))
+	assert.True(t, small.ContainsRange(1<<32-5, 1<<32+5))
+	assert.False(t, small.ContainsRange(1<<32-6, 1<<32+5))
+	assert.False(t, small.ContainsRange(3<<32, 5<<32))
+	assert.False(t, New().ContainsRange(0, 1))
+
+	t.Run("full inner bitmaps", func(t *testing.T) {
+		rb := New()
+		rb.AddRange(5<<32, 7<<32)
+		rb.Add(9 << 32)
+
+		assert.Equal(t, uint64(2<<32+1), rb.RangeCardinality(0, math.MaxUint64))
+		assert.Equal(t, uint64(2<<32), rb.RangeCardinality(4<<32, 8<<32))
+		assert.Equal(t, uint64(2<<32-2), rb.RangeCardinality(5<<32+1, 7<<32-1))
+		assert.Equal(t, uint64(1<<32), rb.RangeCardinality(5<<32+3, 6<<32+3))
+
+		assert.True(t, rb.ContainsRange(5<<32, 7<<32))
+		assert.True(t, rb.ContainsRange(5<<32+3, 6<<32+3))
+		assert.False(t, rb.ContainsRange(5<<32-1, 6<<32))
+		assert.False(t, rb.ContainsRange(5<<32, 7<<32+1))
+		assert.False(t, rb.ContainsRange(5<<32, 10<<32))
+	})
+}
+
+func TestRelation64(t *testing.T) {
+	naive := func(a, b *Bitmap) roaring.SetRelation {
+		aInB := AndNot(a, b).IsEmpty()
+		bInA := AndNot(b, a).IsEmpty()
+		switch {
+		case aInB && bInA:
+			return roaring.Equal
+		case aInB:
+			return roaring.Subset
+		case bInA:
+			return roaring.Superset
+		case a.Intersects(b):
+			return roaring.Overlapping
+		}
+		return roaring.Disjoint
+	}
+
+	runs := New()
+	runs.AddRange(1<<32-100000, 1<<32+100000)
+	runs.RunOptimize()
+	dense := New()
+	for i := uint64(1<<32 - 100000); i < 1<<32+100000; i += 2 {
+		dense.Add(i)
+	}
+	sparse := BitmapOf(1<<32-99998, 1<<32, 1<<32+5)
+	other := BitmapOf(1, 1<<32+1, 5<<32)
+	far := BitmapOf(5<<32, math.MaxUint64)
+	bitmaps := []*Bitmap{New(), runs, dense, sparse, other, far, dense.Clone()}
+
+	for i, a := range bitmaps {
+		for j, b := range bitmaps {
+			expected := naive(a, b)
+			assert.Equal(t, expected, Relation(a, b), "bitmaps %d and %d", i, j)
+			assert.Equal(t, expected == roaring.Subset || expected == roaring.Equal, a.IsSubsetOf(b), "bitmaps %d and %d", i, j)
+			assert.Equal(t, expected == roaring.Subset, a.IsStrictSubsetOf(b), "bitmaps %d and %d", i, j)
+		}
+	}
+
+	assert.Equal(t, roaring.Subset, Relation(sparse, runs))
+	assert.Equal(t, roaring.Superset, Relation(runs, dense))
+	assert.Equal(t, roaring.Overlapping, Relation(dense, sparse))
+	assert.Equal(t, roaring.Disjoint, Relation(sparse, other))
+}
+
+func TestBinaryCardinalities64(t *testing.T) {
+	r := rand.New(rand.NewSource(42))
+	random := func() *Bitmap {
+		rb := New()
+		rb.AddRange(uint64(r.Intn(1<<18)), uint64(r.Intn(1<<18)))
+		for i := 0; i < 5000; i++ {
+			rb.Add(uint64(r.Intn(1<<19)) << uint(r.Intn(2)*32))
+		}
+		for i := 0; i < 20; i++ {
+			rb.Add(r.Uint64())
+		}
+		if r.Intn(2) == 0 {
+			rb.RunOptimize()
+		}
+		return rb
+	}
+	bitmaps := []*Bitmap{New(), random(), random(), random()}
+
+	for i, a := range bitmaps {
+		for j, b := range bitmaps {
+			and := And(a, b).GetCardinality()
+			assert.Equal(t, Or(a, b).GetCardinality(), a.OrCardinality(b), "bitmaps %d and %d", i, j)
+			assert.Equal(t, AndNot(a, b).GetCardinality(), a.AndNotCardinality(b), "bitmaps %d and %d", i, j)
+			assert.Equal(t, Xor(a, b).GetCardinality(), a.XorCardinality(b), "bitmaps %d and %d", i, j)
+
+			if a.IsEmpty() || b.IsEmpty() {
+				assert.Zero(t, a.OverlapCoefficient(b))
+				continue
+			}
+			card1, card2 := a.GetCardinality(), b.GetCardinality()
+			assert.InDelta(t, float64(and)/float64(card1+card2-and), a.Jaccard(b), 1e-12)
+			assert.InDelta(t, 2*float64(and)/float64(card1+card2), a.Dice(b), 1e-12)
+			assert.InDelta(t, float64(and)/math.Min(float64(card1), float64(card2)), a.OverlapCoefficient(b), 1e-12)
+		}
+	}
+
+	assert.Zero(t, New().Jaccard(New()))
+	assert.Equal(t, 1.0, bitmaps[1].Jaccard(bitmaps[1]))
+}
+
+func TestIterateRanges64(t *testing.T) {
+	rb := BitmapOf(1, 2, 3, 7, 1<<32-1, 1<<32, 200000, 5<<32, math.MaxUint64)
+	rb.AddRange(3<<32-10, 3<<32+10)
+	for i := uint64(6 << 32); i < 6<<32+15000; i += 3 {
+		rb.Add(i)
+		rb.Add(i + 1)
+	}
+	rb.AddRange(math.MaxUint64-1000, math.MaxUint64)
+	rb.RunOptimize()
+
+	var expected []roaring.Interval
+	rb.Iterate(func(x uint64) bool {
+		if n := len(expected); n > 0 && expected[n-1].End == x {
+			expected[n-1].End++
+		} else {
+			expected = append(expected, roaring.Interval{Start: x, End: x + 1})
+		}
+		return true
+	})
+
+	var actual []roaring.Interval
+	rb.IterateRanges(func(start, endExclusive uint64) bool {
+		actual = append(actual, roaring.Interval{Start: start, End: endExclusive})
+		return true
+	})
+	assert.Equal(t, expected, actual)
+	assert.Equal(t, expected, rb.ToIntervals())
+	assert.Equal(t, roaring.Interval{Start: 1<<32 - 1, End: 1<<32 + 1}, expected[3])
+	assert.Equal(t, roaring.Interval{Start: math.MaxUint64 - 1000, End: 0}, expected[len(expected)-1])
+
+	actual = actual[:0]
+	it := rb.Ranges()
+	for it.HasNext() {
+		start, end := it.Next()
+		actual = append(actual, roaring.Interval{Start: start, End: end})
+	}
+	assert.Equal(t, expected, actual)
+
+	assert.True(t, rb.Equals(FromIntervals(rb.ToIntervals()...)))
+
+	count := 0
+	rb.IterateRanges(func(start, endExclusive uint64) bool {
+		count++
+		return count < 3
+	})
+	assert.Equal(t, 3, count)
+
+	assert.Empty(t, New().ToIntervals())
+	assert.False(t, New().Ranges().HasNext())
+}
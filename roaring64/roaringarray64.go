@@ -182,12 +182,16 @@ func (ra *roaringArray64) clone() *roaringArray64 {
 // clone all containers which have needCopyOnWrite set to true
 // This can be used to make sure it is safe to munmap a []byte
 // that the roaring array may still have a reference to.
+// The inner bitmaps may themselves reference such a []byte (see
+// FromBuffer and FrozenView), so their copy-on-write containers
+// are cloned as well.
 func (ra *roaringArray64) cloneCopyOnWriteContainers() {
 	for i, needCopyOnWrite := range ra.needCopyOnWrite {
 		if needCopyOnWrite {
 			ra.containers[i] = ra.containers[i].Clone()
 			ra.needCopyOnWrite[i] = false
 		}
+		ra.containers[i].CloneCopyOnWriteContainers()
 	}
 }
 
@@ -0,0 +1,174 @@
+package roaring64
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/RoaringBitmap/roaring/v2"
+)
+
+/* Frozen layout of CRoaring's Roaring64Map (see cpp/roaring64map.hh).
+ *
+ * <map_size>   uint64_t
+ * for each 32-bit bitmap, in increasing key order:
+ *   <padding>  so that the frozen bitmap below starts on a 32-byte boundary
+ *   <len>      size_t (8 bytes), size in bytes of the frozen bitmap
+ *   <key>      uint32_t, the high 32 bits shared by the bitmap values
+ *   <bitmap>   a 32-bit bitmap in the frozen format (see roaring.FrozenView)
+ *
+ * Alignment is computed relative to the start of the buffer, so the
+ * buffer itself should be 32-byte aligned (as memory maps are).
+ */
+const frozenMetadataSize = 8 + 4
+
+// frozenPadding returns the number of padding bytes needed at offset
+// so that the frozen bitmap following the metadata is 32-byte aligned.
+func frozenPadding(offset uint64) uint64 {
+	return (32 - (offset+frozenMetadataSize)%32) % 32
+}
+
+// FrozenView creates a static view of a serialized bitmap stored in buf.
+// It uses the frozen format of CRoaring's Roaring64Map.
+//
+// Each inner 32-bit bitmap is itself created with roaring.Bitmap.FrozenView,
+// so no container data is copied. The same caveats as for the 32-bit
+// FrozenView apply: buf must not be modified while the bitmap is in use,
+// and CloneCopyOnWriteContainers should be called on this bitmap (and on
+// every bitmap derived from it) before buf becomes unavailable.
+func (rb *Bitmap) FrozenView(buf []byte) error {
+	return rb.highlowcontainer.frozenView(buf)
+}
+
+func (ra *roaringArray64) frozenView(buf []byte) error {
+	if len(buf) < 8 {
+		return readError(int64(len(buf)), -1, roaring.ErrFrozenBitmapIncomplete)
+	}
+	size := binary.LittleEndian.Uint64(buf)
+	if size > uint64(len(buf)-8)/frozenMetadataSize {
+		return readError(int64(len(buf)), -1, roaring.ErrFrozenBitmapIncomplete)
+	}
+
+	keys := make([]uint32, size)
+	containers := make([]*roaring.Bitmap, size)
+	needCOW := make([]bool, size)
+
+	offset := uint64(8)
+	for i := uint64(0); i < size; i++ {
+		offset += frozenPadding(offset)
+		if offset+frozenMetadataSize > uint64(len(buf)) {
+			return readError(int64(len(buf)), int(i), roaring.ErrFrozenBitmapIncomplete)
+		}
+		length := binary.LittleEndian.Uint64(buf[offset:])
+		keys[i] = binary.LittleEndian.Uint32(buf[offset+8:])
+		offset += frozenMetadataSize
+		if i > 0 && keys[i] <= keys[i-1] {
+			return &roaring.DeserializationError{Kind: roaring.UnsortedKeys, Offset: int64(offset) - 4, Container: int(i), Err: ErrKeySortOrder}
+		}
+		if length > uint64(len(buf))-offset {
+			return readError(int64(len(buf)), int(i), roaring.ErrFrozenBitmapIncomplete)
+		}
+
+		containers[i] = roaring.NewBitmap()
+		if err := containers[i].FrozenView(buf[offset : offset+length]); err != nil {
+			return innerError(int64(offset), int(i), err)
+		}
+		needCOW[i] = true
+		offset += length
+	}
+
+	if offset != uint64(len(buf)) {
+		return &roaring.DeserializationError{Kind: roaring.TrailingData, Offset: int64(offset), Container: -1, Err: roaring.ErrFrozenBitmapUnexpectedData}
+	}
+
+	ra.keys = keys
+	ra.containers = containers
+	ra.needCopyOnWrite = needCOW
+	ra.copyOnWrite = true
+
+	return nil
+}
+
+// GetFrozenSizeInBytes returns the size in bytes of the frozen bitmap.
+func (rb *Bitmap) GetFrozenSizeInBytes() uint64 {
+	size := uint64(8)
+	for _, c := range rb.highlowcontainer.containers {
+		size += frozenPadding(size)
+		size += frozenMetadataSize
+		size += c.GetFrozenSizeInBytes()
+	}
+	return size
+}
+
+// Freeze serializes the bitmap in the frozen format of CRoaring's Roaring64Map.
+func (rb *Bitmap) Freeze() ([]byte, error) {
+	sz := rb.GetFrozenSizeInBytes()
+	buf := make([]byte, sz)
+	_, err := rb.FreezeTo(buf)
+	return buf, err
+}
+
+// FreezeTo serializes the bitmap in the frozen format of CRoaring's Roaring64Map.
+func (rb *Bitmap) FreezeTo(buf []byte) (int, error) {
+	serialSize := rb.GetFrozenSizeInBytes()
+	if uint64(len(buf)) < serialSize {
+		return 0, roaring.ErrFrozenBitmapBufferTooSmall
+	}
+
+	binary.LittleEndian.PutUint64(buf, uint64(rb.highlowcontainer.size()))
+	offset := uint64(8)
+	for i, c := range rb.highlowcontainer.containers {
+		padding := frozenPadding(offset)
+		for j := offset; j < offset+padding; j++ {
+			buf[j] = 0
+		}
+		offset += padding
+
+		length := c.GetFrozenSizeInBytes()
+		binary.LittleEndian.PutUint64(buf[offset:], length)
+		binary.LittleEndian.PutUint32(buf[offset+8:], rb.highlowcontainer.keys[i])
+		offset += frozenMetadataSize
+
+		if _, err := c.FreezeTo(buf[offset : offset+length]); err != nil {
+			return 0, err
+		}
+		offset += length
+	}
+
+	return int(serialSize), nil
+}
+
+// WriteFrozenTo serializes the bitmap in the frozen format of CRoaring's Roaring64Map.
+func (rb *Bitmap) WriteFrozenTo(wr io.Writer) (int, error) {
+	var padding [32]byte
+	metadata := make([]byte, frozenMetadataSize)
+
+	binary.LittleEndian.PutUint64(metadata, uint64(rb.highlowcontainer.size()))
+	written, err := wr.Write(metadata[:8])
+	if err != nil {
+		return written, err
+	}
+
+	for i, c := range rb.highlowcontainer.containers {
+		n, err := wr.Write(padding[:frozenPadding(uint64(written))])
+		written += n
+		if err != nil {
+			return written, err
+		}
+
+		binary.LittleEndian.PutUint64(metadata, c.GetFrozenSizeInBytes())
+		binary.LittleEndian.PutUint32(metadata[8:], rb.highlowcontainer.keys[i])
+		n, err = wr.Write(metadata)
+		written += n
+		if err != nil {
+			return written, err
+		}
+
+		n, err = c.WriteFrozenTo(wr)
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+
+	return written, nil
+}
@@ -0,0 +1,157 @@
+package roaring64
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/RoaringBitmap/roaring/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFrozen64Layout(t *testing.T) {
+	rb := BitmapOf(1, 2, 3, 1<<32|7)
+
+	buf, err := rb.Freeze()
+	require.NoError(t, err)
+	assert.EqualValues(t, rb.GetFrozenSizeInBytes(), len(buf))
+
+	assert.EqualValues(t, 2, binary.LittleEndian.Uint64(buf))
+
+	// first bitmap: 8 bytes of map size, 12 bytes of padding, then metadata
+	offset := 8 + frozenPadding(8)
+	assert.EqualValues(t, 20, offset)
+	length := binary.LittleEndian.Uint64(buf[offset:])
+	assert.EqualValues(t, 0, binary.LittleEndian.Uint32(buf[offset+8:]))
+	offset += frozenMetadataSize
+	assert.EqualValues(t, 0, offset%32)
+
+	inner := roaring.New()
+	require.NoError(t, inner.FrozenView(buf[offset:offset+length]))
+	assert.True(t, inner.Equals(roaring.BitmapOf(1, 2, 3)))
+	offset += length
+
+	offset += frozenPadding(offset)
+	length = binary.LittleEndian.Uint64(buf[offset:])
+	assert.EqualValues(t, 1, binary.LittleEndian.Uint32(buf[offset+8:]))
+	offset += frozenMetadataSize
+	assert.EqualValues(t, 0, offset%32)
+	assert.EqualValues(t, len(buf), offset+length)
+}
+
+func TestFrozen64RoundTrip(t *testing.T) {
+	rb := New()
+	rb.AddRange(0, 100000)
+	rb.AddRange(1<<32, 1<<32+10)
+	rb.AddMany([]uint64{5 << 32, 5<<32 + 70000, 1 << 62, 1<<62 + 3})
+	for i := uint64(0); i < 100000; i += 3 {
+		rb.Add(7<<32 + i)
+	}
+	rb.RunOptimize()
+
+	t.Run("freeze", func(t *testing.T) {
+		buf, err := rb.Freeze()
+		require.NoError(t, err)
+
+		view := New()
+		require.NoError(t, view.FrozenView(buf))
+		assert.True(t, view.Equals(rb))
+		assert.NoError(t, view.Validate())
+	})
+
+	t.Run("write frozen", func(t *testing.T) {
+		frozen, err := rb.Freeze()
+		require.NoError(t, err)
+
+		var buf bytes.Buffer
+		n, err := rb.WriteFrozenTo(&buf)
+		require.NoError(t, err)
+		assert.Equal(t, len(frozen), n)
+		assert.Equal(t, frozen, buf.Bytes())
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		buf, err := New().Freeze()
+		require.NoError(t, err)
+		assert.Len(t, buf, 8)
+
+		view := BitmapOf(1, 2)
+		require.NoError(t, view.FrozenView(buf))
+		assert.True(t, view.IsEmpty())
+	})
+
+	t.Run("buffer too small", func(t *testing.T) {
+		_, err := rb.FreezeTo(make([]byte, rb.GetFrozenSizeInBytes()-1))
+		assert.ErrorIs(t, err, roaring.ErrFrozenBitmapBufferTooSmall)
+	})
+}
+
+func TestFrozen64ViewIsCopyOnWrite(t *testing.T) {
+	rb := New()
+	rb.AddRange(0, 10)
+	rb.AddRange(1<<32, 1<<32+10000)
+
+	buf, err := rb.Freeze()
+	require.NoError(t, err)
+	orig := append([]byte(nil), buf...)
+
+	view := New()
+	require.NoError(t, view.FrozenView(buf))
+
+	view.Add(20)
+	view.Remove(1<<32 + 5)
+	view.AddRange(2<<32, 2<<32+100)
+	assert.Equal(t, orig, buf)
+
+	assert.True(t, view.Contains(20))
+	assert.False(t, view.Contains(1<<32+5))
+	assert.EqualValues(t, rb.GetCardinality()+100, view.GetCardinality())
+
+	view.CloneCopyOnWriteContainers()
+	for i := range buf {
+		buf[i] = 0
+	}
+	assert.True(t, view.Contains(1<<32+9999))
+	assert.True(t, view.Contains(9))
+}
+
+func TestFrozen64ViewErrors(t *testing.T) {
+	rb := BitmapOf(1, 1<<32|1, 2<<32|1)
+	buf, err := rb.Freeze()
+	require.NoError(t, err)
+
+	t.Run("incomplete", func(t *testing.T) {
+		assert.ErrorIs(t, New().FrozenView(buf[:4]), roaring.ErrFrozenBitmapIncomplete)
+		assert.ErrorIs(t, New().FrozenView(buf[:len(buf)-1]), roaring.ErrFrozenBitmapIncomplete)
+	})
+
+	t.Run("too many bitmaps", func(t *testing.T) {
+		bad := append([]byte(nil), buf...)
+		binary.LittleEndian.PutUint64(bad, 1<<40)
+		assert.ErrorIs(t, New().FrozenView(bad), roaring.ErrFrozenBitmapIncomplete)
+	})
+
+	t.Run("trailing data", func(t *testing.T) {
+		bad := append(append([]byte(nil), buf...), 0)
+		assert.ErrorIs(t, New().FrozenView(bad), roaring.ErrFrozenBitmapUnexpectedData)
+	})
+
+	t.Run("unsorted keys", func(t *testing.T) {
+		bad := append([]byte(nil), buf...)
+		// overwrite the second key with the first one
+		second := 8 + frozenPadding(8)
+		second += frozenMetadataSize + binary.LittleEndian.Uint64(bad[second:])
+		second += frozenPadding(second)
+		binary.LittleEndian.PutUint32(bad[second+8:], 0)
+		err := New().FrozenView(bad)
+		assert.ErrorIs(t, err, ErrKeySortOrder)
+
+		var de *roaring.DeserializationError
+		require.True(t, errors.As(err, &de))
+		assert.Equal(t, roaring.UnsortedKeys, de.Kind)
+		assert.Equal(t, 1, de.Container)
+		assert.EqualValues(t, second+8, de.Offset)
+	})
+}
@@ -4,6 +4,8 @@ package roaring64
 
 import (
 	"bytes"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -13,6 +15,7 @@ import (
 	"runtime"
 	"testing"
 
+	"github.com/RoaringBitmap/roaring/v2"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -307,3 +310,222 @@ func Test_tryReadFromRoaring32WithRoaring64_File(t *testing.T) {
 	assert.True(t, nr64.Contains(math.MaxUint32))
 	assert.True(t, nr64.Contains(math.MaxUint64))
 }
+
+func TestFromBuffer(t *testing.T) {
+	rb := BitmapOf(1, 2, 3, 4, 5, 100, 1000, 10000, 100000, 1000000, maxUint32+10, maxUint32<<10)
+	for i := uint64(maxUint32); i < maxUint32+2*(1<<16); i++ {
+		rb.Add(i)
+	}
+	rb.AddRange(5<<32, 5<<32+100000)
+	rb.RunOptimize()
+
+	data, err := rb.ToBytes()
+	require.NoError(t, err)
+	orig := append([]byte(nil), data...)
+
+	newrb := NewBitmap()
+	p, err := newrb.FromBuffer(data)
+	require.NoError(t, err)
+	assert.EqualValues(t, len(data), p)
+	assert.True(t, rb.Equals(newrb))
+
+	t.Run("copy on write", func(t *testing.T) {
+		modified := newrb.Clone()
+		modified.Add(7)
+		modified.Remove(maxUint32 + 1)
+		modified.RemoveRange(5<<32, 5<<32+10)
+		assert.Equal(t, orig, data)
+		assert.True(t, modified.Contains(7))
+		assert.False(t, modified.Contains(maxUint32+1))
+
+		newrb.Add(7)
+		newrb.Flip(5<<32, 5<<32+10)
+		assert.Equal(t, orig, data)
+	})
+
+	t.Run("clone containers", func(t *testing.T) {
+		other := NewBitmap()
+		_, err := other.FromBuffer(data)
+		require.NoError(t, err)
+		other.CloneCopyOnWriteContainers()
+		for i := range data {
+			data[i] = 0
+		}
+		assert.True(t, rb.Equals(other))
+		copy(data, orig)
+	})
+
+	t.Run("stale copy on write flags", func(t *testing.T) {
+		other := NewBitmap()
+		stale := make([]bool, 2*rb.highlowcontainer.size())
+		for i := range stale {
+			stale[i] = true
+		}
+		other.highlowcontainer.needCopyOnWrite = stale[:0]
+		_, err := other.FromBuffer(data)
+		require.NoError(t, err)
+		assert.NotContains(t, other.highlowcontainer.needCopyOnWrite, true)
+		assert.True(t, rb.Equals(other))
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		for _, l := range []int{0, 7, 8, 12, len(orig) / 2, len(orig) - 1} {
+			_, err := NewBitmap().FromBuffer(orig[:l])
+			assert.Error(t, err, "length %d", l)
+		}
+	})
+
+	t.Run("oversized header", func(t *testing.T) {
+		bad := append([]byte(nil), orig...)
+		bad[7] = 0xff
+		_, err := NewBitmap().FromBuffer(bad)
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	})
+}
+
+func TestFromBufferEmpty(t *testing.T) {
+	data, err := NewBitmap().ToBytes()
+	require.NoError(t, err)
+
+	newrb := BitmapOf(1, 2, 3)
+	_, err = newrb.FromBuffer(data)
+	require.NoError(t, err)
+	assert.True(t, newrb.IsEmpty())
+}
+
+func TestReadFromWithOptions(t *testing.T) {
+	rb := New()
+	rb.AddRange(1, 100)
+	rb.Add(200)
+	rb.AddRange(1<<32, 1<<32+100000)
+	for i := uint64(0); i < 10000; i += 2 {
+		rb.Add(3<<32 + i)
+	}
+	rb.Add(math.MaxUint64)
+	rb.RunOptimize()
+	buf, err := rb.ToBytes()
+	require.NoError(t, err)
+
+	containers := uint64(0)
+	for _, c := range rb.highlowcontainer.containers {
+		containers += uint64(c.Stats().Containers)
+	}
+
+	t.Run("valid input", func(t *testing.T) {
+		for _, opts := range []roaring.ReadOptions{
+			{},
+			{
+				MaxContainers:     containers,
+				MaxCardinality:    rb.GetCardinality(),
+				MaxAllocatedBytes: rb.GetSizeInBytes(),
+			},
+		} {
+			b := New()
+			n, err := b.ReadFromWithOptions(bytes.NewReader(buf), opts)
+			require.NoError(t, err)
+			assert.EqualValues(t, len(buf), n)
+			assert.True(t, b.Equals(rb))
+		}
+	})
+
+	t.Run("limits", func(t *testing.T) {
+		_, err := New().ReadFromWithOptions(bytes.NewReader(buf), roaring.ReadOptions{MaxContainers: containers - 1})
+		assert.ErrorIs(t, err, roaring.ErrContainerLimit)
+		_, err = New().ReadFromWithOptions(bytes.NewReader(buf), roaring.ReadOptions{MaxCardinality: rb.GetCardinality() - 1})
+		assert.ErrorIs(t, err, roaring.ErrCardinalityLimit)
+		_, err = New().ReadFromWithOptions(bytes.NewReader(buf), roaring.ReadOptions{MaxAllocatedBytes: rb.GetSizeInBytes() - 1})
+		assert.ErrorIs(t, err, roaring.ErrAllocationLimit)
+	})
+
+	t.Run("invalid input", func(t *testing.T) {
+		// the announced number of bitmaps is not trusted
+		huge := make([]byte, 8)
+		binary.LittleEndian.PutUint64(huge, 1<<40)
+		_, err := New().ReadFromWithOptions(bytes.NewReader(huge), roaring.ReadOptions{})
+		assert.ErrorIs(t, err, ErrKeySortOrder)
+		binary.LittleEndian.PutUint64(huge, 1<<30)
+		_, err = New().ReadFromWithOptions(bytes.NewReader(huge), roaring.ReadOptions{MaxAllocatedBytes: 1 << 20})
+		assert.ErrorIs(t, err, roaring.ErrAllocationLimit)
+		_, err = New().ReadFromWithOptions(bytes.NewReader(huge), roaring.ReadOptions{})
+		assert.ErrorIs(t, err, io.EOF)
+
+		// give the first bitmap the key of the second one
+		s := append([]byte(nil), buf...)
+		binary.LittleEndian.PutUint32(s[8:], 1)
+		_, err = New().ReadFromWithOptions(bytes.NewReader(s), roaring.ReadOptions{})
+		assert.ErrorIs(t, err, ErrKeySortOrder)
+
+		// corrupt the inner bitmap
+		s = append([]byte(nil), buf...)
+		s[12] = 0
+		_, err = New().ReadFromWithOptions(bytes.NewReader(s), roaring.ReadOptions{})
+		assert.Error(t, err)
+	})
+}
+
+func TestDeserializationError(t *testing.T) {
+	rb := BitmapOf(1, 2, 1<<32+1)
+	buf, err := rb.ToBytes()
+	require.NoError(t, err)
+	// 8 bytes of size, then the key and the inner bitmap of 20 bytes of each high value
+	require.EqualValues(t, 20, roaring.BitmapOf(1, 2).GetSerializedSizeInBytes())
+
+	readers := map[string]func(b []byte) error{
+		"ReadFrom":        func(b []byte) error { _, err := New().ReadFrom(bytes.NewReader(b)); return err },
+		"FromBuffer":      func(b []byte) error { _, err := New().FromBuffer(b); return err },
+		"FromUnsafeBytes": func(b []byte) error { _, err := New().FromUnsafeBytes(b); return err },
+		"ReadFromWithOptions": func(b []byte) error {
+			_, err := New().ReadFromWithOptions(bytes.NewReader(b), roaring.ReadOptions{})
+			return err
+		},
+	}
+	t.Run("end of stream", func(t *testing.T) {
+		_, err := New().ReadFrom(bytes.NewReader(nil))
+		assert.Equal(t, io.EOF, err)
+		_, err = New().ReadFromWithOptions(bytes.NewReader(nil), roaring.ReadOptions{})
+		assert.Equal(t, io.EOF, err)
+
+		// the inner bitmap of the key is missing
+		_, err = New().ReadFrom(bytes.NewReader(buf[:12]))
+		var de *roaring.DeserializationError
+		require.True(t, errors.As(err, &de))
+		assert.Equal(t, roaring.TruncatedInput, de.Kind)
+		assert.Equal(t, 0, de.Container)
+	})
+
+	for name, read := range readers {
+		t.Run(name, func(t *testing.T) {
+			var de *roaring.DeserializationError
+			require.True(t, errors.As(read(buf[:4]), &de))
+			assert.Equal(t, roaring.TruncatedInput, de.Kind)
+			assert.Equal(t, -1, de.Container)
+
+			bad := append([]byte(nil), buf...)
+			bad[36] = 0
+			err := read(bad)
+			require.True(t, errors.As(err, &de))
+			assert.Equal(t, roaring.InvalidCookie, de.Kind)
+			assert.Equal(t, 1, de.Container)
+			assert.EqualValues(t, 36, de.Offset)
+			assert.True(t, errors.Is(err, &roaring.DeserializationError{Kind: roaring.InvalidCookie}))
+
+			// the error of the inner bitmap is wrapped, with its own offsets
+			var inner *roaring.DeserializationError
+			require.True(t, errors.As(de.Err, &inner))
+			assert.EqualValues(t, 0, inner.Offset)
+			assert.Equal(t, -1, inner.Container)
+		})
+	}
+
+	t.Run("unsorted keys", func(t *testing.T) {
+		bad := append([]byte(nil), buf...)
+		bad[32] = 0
+		_, err := New().ReadFromWithOptions(bytes.NewReader(bad), roaring.ReadOptions{})
+		var de *roaring.DeserializationError
+		require.True(t, errors.As(err, &de))
+		assert.Equal(t, roaring.UnsortedKeys, de.Kind)
+		assert.Equal(t, 1, de.Container)
+		assert.EqualValues(t, 32, de.Offset)
+		assert.ErrorIs(t, err, ErrKeySortOrder)
+	})
+}
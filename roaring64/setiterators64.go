@@ -0,0 +1,232 @@
+package roaring64
+
+import (
+	"math"
+
+	"github.com/RoaringBitmap/roaring/v2"
+)
+
+// AndIterator returns an IntPeekable64 over the intersection of the values of the given iterators,
+// in sorted order. The values are combined by roaring.AndIterator, one range of 2^32 values at a
+// time, and the ranges where one of the inputs has no value are skipped with AdvanceIfNeeded.
+// When all the iterators are bitmap iterators (see Bitmap.Iterator), roaring.AndIterator is given
+// the iterators of their inner bitmaps, so that it combines them one container at a time.
+// The input iterators are consumed by the returned iterator.
+func AndIterator(iterators ...IntPeekable64) IntPeekable64 {
+	return newSetIterator(andSetOp, iterators)
+}
+
+// OrIterator returns an IntPeekable64 over the union of the values of the given iterators,
+// in sorted order and without duplicates. The values are combined by roaring.OrIterator, one range
+// of 2^32 values at a time, from the iterators of the inner bitmaps as for AndIterator.
+// The input iterators are consumed by the returned iterator.
+func OrIterator(iterators ...IntPeekable64) IntPeekable64 {
+	return newSetIterator(orSetOp, iterators)
+}
+
+// AndNotIterator returns an IntPeekable64 over the values of it which are in none of the excluded
+// iterators, in sorted order. The values are combined by roaring.AndNotIterator, one range of 2^32
+// values at a time, from the iterators of the inner bitmaps as for AndIterator, so the excluded
+// iterators are only advanced as far as needed.
+// The input iterators are consumed by the returned iterator.
+func AndNotIterator(it IntPeekable64, excluded ...IntPeekable64) IntPeekable64 {
+	return newSetIterator(andNotSetOp, append([]IntPeekable64{it}, excluded...))
+}
+
+type setOp int
+
+const (
+	andSetOp setOp = iota
+	orSetOp
+	andNotSetOp // the first input minus the others
+)
+
+// setIterator combines its inputs with a 32-bit set iterator over every range of 2^32 values,
+// in increasing order
+type setIterator struct {
+	op     setOp
+	inputs []IntPeekable64
+	views  []bucketView
+	// peekables holds a pointer to every view, the 32-bit set iterators do not modify it
+	peekables []roaring.IntPeekable
+	// inners holds the iterators of the inner bitmaps in the current range, when all the
+	// inputs are bitmap iterators
+	inners []roaring.IntPeekable
+	// containers is true when the current range is combined from the inner iterators
+	containers bool
+	high       uint32
+	cur        roaring.IntPeekable // nil once exhausted, has a next value otherwise
+}
+
+func newSetIterator(op setOp, inputs []IntPeekable64) *setIterator {
+	si := &setIterator{op: op, inputs: inputs, views: make([]bucketView, len(inputs)),
+		peekables: make([]roaring.IntPeekable, len(inputs))}
+	for i, it := range inputs {
+		si.views[i].it = it
+		si.peekables[i] = &si.views[i]
+	}
+	si.open(0)
+	return si
+}
+
+// open moves to the first range of 2^32 values, from the one of minval, where the result has values
+func (si *setIterator) open(minval uint64) {
+	si.cur = nil
+	for {
+		for _, it := range si.inputs {
+			it.AdvanceIfNeeded(minval)
+		}
+		high, ok := si.firstHigh()
+		if !ok {
+			return
+		}
+		for _, it := range si.inputs {
+			it.AdvanceIfNeeded(uint64(high) << 32)
+		}
+
+		si.high = high
+		for i := range si.views {
+			si.views[i].high = high
+		}
+		peekables := si.peekables
+		if si.containers = si.innerIterators(high); si.containers {
+			peekables = si.inners
+		}
+		var cur roaring.IntPeekable
+		switch si.op {
+		case andSetOp:
+			cur = roaring.AndIterator(peekables...)
+		case orSetOp:
+			cur = roaring.OrIterator(peekables...)
+		default:
+			cur = roaring.AndNotIterator(peekables[0], peekables[1:]...)
+		}
+		if cur.HasNext() {
+			si.cur = cur
+			return
+		}
+		if high == math.MaxUint32 {
+			return
+		}
+		minval = uint64(high+1) << 32
+	}
+}
+
+// innerIterators sets inners to the iterators of the inner bitmaps of the inputs in the range
+// of 2^32 values high, and returns false if one of the inputs is not a bitmap iterator.
+// The inner iterators are not advanced by the 32-bit set iterators, which read their bitmaps
+// one container at a time, and the inputs are moved past the range by open.
+func (si *setIterator) innerIterators(high uint32) bool {
+	si.inners = si.inners[:0]
+	for _, it := range si.inputs {
+		ii, ok := it.(*intIterator)
+		if !ok {
+			return false
+		}
+		if ii.HasNext() && highbits(ii.PeekNext()) == high {
+			si.inners = append(si.inners, ii.iter)
+		} else {
+			si.inners = append(si.inners, roaring.New().Iterator())
+		}
+	}
+	return true
+}
+
+// firstHigh returns the first range of 2^32 values where the result may have values
+func (si *setIterator) firstHigh() (uint32, bool) {
+	switch si.op {
+	case andSetOp:
+		// every input must reach the range of the input which is the furthest ahead
+		high := uint32(0)
+		for _, it := range si.inputs {
+			if !it.HasNext() {
+				return 0, false
+			}
+			if h := highbits(it.PeekNext()); h > high {
+				high = h
+			}
+		}
+		return high, len(si.inputs) > 0
+	case orSetOp:
+		high, found := uint32(0), false
+		for _, it := range si.inputs {
+			if !it.HasNext() {
+				continue
+			}
+			if h := highbits(it.PeekNext()); !found || h < high {
+				high, found = h, true
+			}
+		}
+		return high, found
+	default:
+		if !si.inputs[0].HasNext() {
+			return 0, false
+		}
+		return highbits(si.inputs[0].PeekNext()), true
+	}
+}
+
+// HasNext returns true if there are more integers to iterate over
+func (si *setIterator) HasNext() bool {
+	return si.cur != nil
+}
+
+// Next returns the next integer
+func (si *setIterator) Next() uint64 {
+	x := uint64(si.high)<<32 | uint64(si.cur.Next())
+	if !si.cur.HasNext() {
+		si.openNext()
+	}
+	return x
+}
+
+// PeekNext peeks the next value without advancing the iterator
+func (si *setIterator) PeekNext() uint64 {
+	return uint64(si.high)<<32 | uint64(si.cur.PeekNext())
+}
+
+// AdvanceIfNeeded advances as long as the next value is smaller than minval
+func (si *setIterator) AdvanceIfNeeded(minval uint64) {
+	if si.cur == nil || si.PeekNext() >= minval {
+		return
+	}
+	if highbits(minval) != si.high {
+		si.open(minval)
+		return
+	}
+	si.cur.AdvanceIfNeeded(lowbits(minval))
+	if !si.cur.HasNext() {
+		si.openNext()
+	}
+}
+
+// openNext moves to the range of 2^32 values following the current one
+func (si *setIterator) openNext() {
+	if si.high == math.MaxUint32 {
+		si.cur = nil
+		return
+	}
+	si.open(uint64(si.high+1) << 32)
+}
+
+// bucketView is a roaring.IntPeekable over the values of it in the range of 2^32 values high
+type bucketView struct {
+	it   IntPeekable64
+	high uint32
+}
+
+func (v *bucketView) HasNext() bool {
+	return v.it.HasNext() && highbits(v.it.PeekNext()) == v.high
+}
+
+func (v *bucketView) Next() uint32 {
+	return lowbits(v.it.Next())
+}
+
+func (v *bucketView) PeekNext() uint32 {
+	return lowbits(v.it.PeekNext())
+}
+
+func (v *bucketView) AdvanceIfNeeded(minval uint32) {
+	v.it.AdvanceIfNeeded(uint64(v.high)<<32 | uint64(minval))
+}
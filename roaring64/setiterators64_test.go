@@ -0,0 +1,139 @@
+package roaring64
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func drainIterator(it IntIterable64) []uint64 {
+	var values []uint64
+	for it.HasNext() {
+		values = append(values, it.Next())
+	}
+	return values
+}
+
+// valueIterator hides the bitmap iterator it wraps, so that the set iterators combine its values one by one
+type valueIterator struct {
+	IntPeekable64
+}
+
+func TestSetIterators(t *testing.T) {
+	r := rand.New(rand.NewSource(42))
+	bitmaps := make([]*Bitmap, 4)
+	for i := range bitmaps {
+		bitmaps[i] = New()
+		for j := 0; j < 20000; j++ {
+			bitmaps[i].Add(uint64(r.Intn(1<<20)) << 14)
+		}
+		bitmaps[i].AddRange(1<<32+uint64(i)<<16, 1<<32+uint64(i+2)<<16)
+		bitmaps[i].Add(math.MaxUint64)
+	}
+	bitmaps[0].RunOptimize()
+	empty := New()
+
+	iterators := func(bms ...*Bitmap) []IntPeekable64 {
+		its := make([]IntPeekable64, len(bms))
+		for i, bm := range bms {
+			its[i] = bm.Iterator()
+		}
+		return its
+	}
+
+	t.Run("and", func(t *testing.T) {
+		assert.Equal(t, FastAnd(bitmaps...).ToArray(), drainIterator(AndIterator(iterators(bitmaps...)...)))
+		assert.Equal(t, And(bitmaps[1], bitmaps[2]).ToArray(), drainIterator(AndIterator(iterators(bitmaps[1], bitmaps[2])...)))
+		assert.Empty(t, drainIterator(AndIterator(iterators(bitmaps[0], empty)...)))
+		assert.False(t, AndIterator().HasNext())
+
+		// the inputs only meet after skipping ranges of 2^32 values
+		a := BitmapOf(1, 3<<32, 5<<32, 5<<32+1, math.MaxUint64)
+		b := BitmapOf(2, 4<<32, 5<<32+1, 6<<32, math.MaxUint64)
+		assert.Equal(t, []uint64{5<<32 + 1, math.MaxUint64}, drainIterator(AndIterator(a.Iterator(), b.Iterator())))
+	})
+
+	t.Run("or", func(t *testing.T) {
+		assert.Equal(t, FastOr(bitmaps...).ToArray(), drainIterator(OrIterator(iterators(bitmaps...)...)))
+		assert.Equal(t, bitmaps[3].ToArray(), drainIterator(OrIterator(iterators(empty, bitmaps[3])...)))
+		assert.False(t, OrIterator().HasNext())
+	})
+
+	t.Run("andnot", func(t *testing.T) {
+		expected := AndNot(bitmaps[0], Or(bitmaps[1], bitmaps[2]))
+		assert.Equal(t, expected.ToArray(), drainIterator(AndNotIterator(bitmaps[0].Iterator(), iterators(bitmaps[1], bitmaps[2])...)))
+		assert.Equal(t, bitmaps[0].ToArray(), drainIterator(AndNotIterator(bitmaps[0].Iterator())))
+		assert.Empty(t, drainIterator(AndNotIterator(bitmaps[0].Iterator(), bitmaps[0].Iterator())))
+	})
+
+	t.Run("nested", func(t *testing.T) {
+		// (b0 & b1) | (b2 andnot b3)
+		it := OrIterator(
+			AndIterator(iterators(bitmaps[0], bitmaps[1])...),
+			AndNotIterator(bitmaps[2].Iterator(), bitmaps[3].Iterator()),
+		)
+		expected := Or(And(bitmaps[0], bitmaps[1]), AndNot(bitmaps[2], bitmaps[3]))
+		assert.Equal(t, expected.ToArray(), drainIterator(it))
+	})
+
+	t.Run("peek and advance", func(t *testing.T) {
+		expected := FastAnd(bitmaps...)
+		it := AndIterator(iterators(bitmaps...)...)
+		for _, minval := range []uint64{0, 1 << 16, 1 << 32, 3<<32 + 7, 1 << 33, 1 << 33, math.MaxUint64} {
+			it.AdvanceIfNeeded(minval)
+			want := expected.Clone()
+			want.RemoveRange(0, uint64(minval))
+			if assert.True(t, it.HasNext()) {
+				assert.Equal(t, want.Minimum(), it.PeekNext())
+			}
+		}
+		assert.Equal(t, uint64(math.MaxUint64), it.Next())
+		assert.False(t, it.HasNext())
+
+		or := OrIterator(iterators(bitmaps...)...)
+		or.AdvanceIfNeeded(5 << 31)
+		want := FastOr(bitmaps...)
+		want.RemoveRange(0, 5<<31)
+		assert.Equal(t, want.ToArray(), drainIterator(or))
+
+		andNot := AndNotIterator(bitmaps[1].Iterator(), bitmaps[0].Iterator())
+		andNot.AdvanceIfNeeded(1 << 33)
+		want = AndNot(bitmaps[1], bitmaps[0])
+		want.RemoveRange(0, 1<<33)
+		assert.Equal(t, want.ToArray(), drainIterator(andNot))
+	})
+
+	t.Run("containers", func(t *testing.T) {
+		values := func(bms ...*Bitmap) []IntPeekable64 {
+			its := iterators(bms...)
+			for i, it := range its {
+				its[i] = valueIterator{it}
+			}
+			return its
+		}
+		assert.True(t, AndIterator(iterators(bitmaps...)...).(*setIterator).containers)
+		assert.True(t, OrIterator(iterators(empty, bitmaps[1])...).(*setIterator).containers)
+		assert.True(t, AndNotIterator(bitmaps[0].Iterator(), iterators(bitmaps[1:]...)...).(*setIterator).containers)
+		assert.False(t, AndIterator(append(values(bitmaps[0]), iterators(bitmaps[1:]...)...)...).(*setIterator).containers)
+
+		assert.Equal(t, drainIterator(AndIterator(values(bitmaps...)...)), drainIterator(AndIterator(iterators(bitmaps...)...)))
+		assert.Equal(t, drainIterator(OrIterator(values(bitmaps...)...)), drainIterator(OrIterator(iterators(bitmaps...)...)))
+		assert.Equal(t, drainIterator(AndNotIterator(valueIterator{bitmaps[0].Iterator()}, values(bitmaps[1:]...)...)),
+			drainIterator(AndNotIterator(bitmaps[0].Iterator(), iterators(bitmaps[1:]...)...)))
+
+		// iterators which have already consumed part of their inner bitmap
+		for _, minval := range []uint64{3 << 14, 1<<32 + 1000, 1<<32 + 3<<16 + 7} {
+			partial := func(its []IntPeekable64) []IntPeekable64 {
+				its[0].AdvanceIfNeeded(minval)
+				its[0].Next()
+				return its
+			}
+			assert.Equal(t, drainIterator(AndIterator(partial(values(bitmaps...))...)),
+				drainIterator(AndIterator(partial(iterators(bitmaps...))...)), "and from %d", minval)
+			assert.Equal(t, drainIterator(OrIterator(partial(values(bitmaps...))...)),
+				drainIterator(OrIterator(partial(iterators(bitmaps...))...)), "or from %d", minval)
+		}
+	})
+}
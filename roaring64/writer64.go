@@ -0,0 +1,249 @@
+package roaring64
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/RoaringBitmap/roaring/v2"
+)
+
+// Writer builds a bitmap from values added in increasing order, see roaring.Writer.
+// Every range of 2^32 values is built by a roaring.Writer, so only the block of 65536
+// values being filled is kept in a mutable container.
+//
+// A Writer created with NewWriter builds a Bitmap, obtained with Bitmap. A Writer created
+// with NewStreamWriter produces the portable format (see WriteTo) instead, without building
+// the bitmap.
+type Writer struct {
+	rb *Bitmap
+
+	out     io.Writer
+	scratch *sharedScratch
+	keys    []uint32
+	inners  []*roaring.Writer
+
+	key     uint32
+	inner   *roaring.Writer
+	last    uint64
+	started bool
+	closed  bool
+	flushed bool
+	err     error
+}
+
+// NewWriter returns a Writer building a Bitmap.
+func NewWriter() *Writer {
+	return &Writer{rb: NewBitmap(), inner: roaring.NewWriter()}
+}
+
+// NewStreamWriter returns a Writer serializing the bitmap to out, in the portable format.
+// As for roaring.NewStreamWriter, closed containers are staged in scratch and only the
+// headers are kept in memory until Flush.
+func NewStreamWriter(out io.Writer, scratch io.ReadWriter) *Writer {
+	return &Writer{out: out, scratch: &sharedScratch{rw: scratch}}
+}
+
+// Add adds x to the bitmap. x must not be smaller than the values already added.
+func (wr *Writer) Add(x uint64) error {
+	if err := wr.check(x); err != nil {
+		return err
+	}
+	if err := wr.open(highbits(x)); err != nil {
+		return err
+	}
+	if err := wr.inner.Add(lowbits(x)); err != nil {
+		return wr.fail(err)
+	}
+	wr.last = x
+	return nil
+}
+
+// AddMany adds all the values of dat, which must be sorted, to the bitmap.
+func (wr *Writer) AddMany(dat []uint64) error {
+	for _, x := range dat {
+		if err := wr.Add(x); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddRange adds the integers in [rangeStart, rangeEnd) to the bitmap. rangeStart must not
+// be smaller than the values already added.
+func (wr *Writer) AddRange(rangeStart, rangeEnd uint64) error {
+	if rangeStart >= rangeEnd {
+		return wr.err
+	}
+	if err := wr.check(rangeStart); err != nil {
+		return err
+	}
+
+	hbStart := uint64(highbits(rangeStart))
+	lbStart := uint64(lowbits(rangeStart))
+	hbLast := uint64(highbits(rangeEnd - 1))
+	lbLast := uint64(lowbits(rangeEnd - 1))
+
+	var max uint64 = maxLowBit
+	for hb := hbStart; hb <= hbLast; hb++ {
+		containerStart := uint64(0)
+		if hb == hbStart {
+			containerStart = lbStart
+		}
+		containerLast := max
+		if hb == hbLast {
+			containerLast = lbLast
+		}
+
+		if err := wr.open(uint32(hb)); err != nil {
+			return err
+		}
+		if err := wr.inner.AddRange(containerStart, containerLast+1); err != nil {
+			return wr.fail(err)
+		}
+	}
+	wr.last = rangeEnd - 1
+	return nil
+}
+
+// Close closes the block being filled, so that no value can be added afterwards.
+// The result is then obtained with Bitmap or Flush, which call Close if needed.
+func (wr *Writer) Close() error {
+	if wr.closed {
+		return wr.err
+	}
+	wr.closed = true
+	return wr.close()
+}
+
+// Bitmap closes the Writer and returns the bitmap built by a Writer created with NewWriter.
+// The Writer is then reset and can be used to build another bitmap.
+// Bitmap returns nil for a Writer created with NewStreamWriter.
+func (wr *Writer) Bitmap() *Bitmap {
+	if wr.rb == nil {
+		return nil
+	}
+	wr.Close()
+	rb := wr.rb
+	*wr = *NewWriter()
+	return rb
+}
+
+// Flush closes the Writer and writes the serialized bitmap to the output of a Writer created
+// with NewStreamWriter. It returns the number of bytes written. The Writer must not be used afterwards.
+func (wr *Writer) Flush() (n int64, err error) {
+	if wr.rb != nil {
+		return 0, roaring.ErrWriterNoOutput
+	}
+	if wr.flushed {
+		return 0, roaring.ErrWriterClosed
+	}
+	if err := wr.Close(); err != nil {
+		return 0, err
+	}
+	wr.flushed = true
+
+	buf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(buf, uint64(len(wr.keys)))
+	written, err := wr.out.Write(buf)
+	n += int64(written)
+	if err != nil {
+		return n, err
+	}
+
+	// the inner writers read the scratch in sequence, from where the first one started
+	if seeker, ok := wr.scratch.rw.(io.Seeker); ok {
+		if _, err := seeker.Seek(-wr.scratch.staged, io.SeekCurrent); err != nil {
+			return n, err
+		}
+	}
+	keyBuf := buf[:4]
+	for i, inner := range wr.inners {
+		binary.LittleEndian.PutUint32(keyBuf, wr.keys[i])
+		written, err = wr.out.Write(keyBuf)
+		n += int64(written)
+		if err != nil {
+			return n, err
+		}
+		flushed, err := inner.Flush()
+		n += flushed
+		if err != nil {
+			return n, err
+		}
+		wr.inners[i] = nil
+	}
+	return n, nil
+}
+
+// check verifies that the Writer can accept a value or a range starting at x
+func (wr *Writer) check(x uint64) error {
+	if wr.err != nil {
+		return wr.err
+	}
+	if wr.closed {
+		return roaring.ErrWriterClosed
+	}
+	if wr.started && x < wr.last {
+		return roaring.ErrWriterOutOfOrder
+	}
+	return nil
+}
+
+// open makes sure that the current inner writer is the one of the given key
+func (wr *Writer) open(key uint32) error {
+	if wr.started && wr.key == key {
+		return nil
+	}
+	if err := wr.close(); err != nil {
+		return err
+	}
+	wr.key = key
+	if wr.rb == nil {
+		wr.inner = roaring.NewStreamWriter(wr.out, wr.scratch)
+	}
+	wr.started = true
+	return nil
+}
+
+// fail stops the Writer with err
+func (wr *Writer) fail(err error) error {
+	wr.err = err
+	return err
+}
+
+// close appends the current inner bitmap to the result
+func (wr *Writer) close() error {
+	if !wr.started {
+		return wr.err
+	}
+	wr.started = false
+	if wr.rb != nil {
+		wr.rb.highlowcontainer.appendContainer(wr.key, wr.inner.Bitmap(), false)
+		return nil
+	}
+	// the last container must be staged before the next inner writer stages its own
+	if err := wr.inner.Close(); err != nil {
+		return wr.fail(err)
+	}
+	wr.keys = append(wr.keys, wr.key)
+	wr.inners = append(wr.inners, wr.inner)
+	wr.inner = nil
+	return nil
+}
+
+// sharedScratch is the scratch of the inner writers of a stream Writer: it hides the
+// io.Seeker of the underlying scratch, which the inner writers read in sequence, and
+// counts the bytes staged by all of them.
+type sharedScratch struct {
+	rw     io.ReadWriter
+	staged int64
+}
+
+func (s *sharedScratch) Write(p []byte) (int, error) {
+	n, err := s.rw.Write(p)
+	s.staged += int64(n)
+	return n, err
+}
+
+func (s *sharedScratch) Read(p []byte) (int, error) {
+	return s.rw.Read(p)
+}
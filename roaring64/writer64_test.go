@@ -0,0 +1,118 @@
+package roaring64
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/RoaringBitmap/roaring/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writerInput adds sorted values and ranges to w and to the returned bitmap
+func writerInput(t *testing.T, w *Writer) *Bitmap {
+	expected := New()
+	add := func(x uint64) {
+		require.NoError(t, w.Add(x))
+		expected.Add(x)
+	}
+	addRange := func(start, end uint64) {
+		require.NoError(t, w.AddRange(start, end))
+		expected.AddRange(start, end)
+	}
+
+	add(1)
+	add(1)
+	for x := uint64(1 << 16); x < 1<<16+10000; x += 2 {
+		add(x)
+	}
+	// a range spanning two inner bitmaps
+	addRange(1<<32-100, 1<<32+100)
+	add(1<<32 + 100)
+	for x := uint64(5 << 32); x < 10<<32; x += 1<<32 + 7 {
+		add(x)
+	}
+	addRange(20<<32+5, 20<<32+3<<16)
+	add(math.MaxUint64)
+
+	expected.RunOptimize()
+	return expected
+}
+
+func TestWriter(t *testing.T) {
+	t.Run("bitmap", func(t *testing.T) {
+		w := NewWriter()
+		expected := writerInput(t, w)
+		rb := w.Bitmap()
+		assert.True(t, rb.Equals(expected))
+
+		expectedBuf, err := expected.ToBytes()
+		require.NoError(t, err)
+		buf, err := rb.ToBytes()
+		require.NoError(t, err)
+		assert.Equal(t, expectedBuf, buf)
+
+		// the writer is reset
+		require.NoError(t, w.Add(1))
+		assert.Equal(t, []uint64{1}, w.Bitmap().ToArray())
+
+		// the same values again, after an error and Close
+		require.NoError(t, w.AddRange(1<<32+5, 1<<32+10))
+		assert.ErrorIs(t, w.Add(1), roaring.ErrWriterOutOfOrder)
+		require.NoError(t, w.Close())
+		assert.Equal(t, []uint64{1<<32 + 5, 1<<32 + 6, 1<<32 + 7, 1<<32 + 8, 1<<32 + 9}, w.Bitmap().ToArray())
+		assert.True(t, w.Bitmap().IsEmpty())
+		second := writerInput(t, w)
+		assert.True(t, w.Bitmap().Equals(second))
+		assert.True(t, rb.Equals(expected))
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		tmp, err := os.Create(filepath.Join(t.TempDir(), "scratch"))
+		require.NoError(t, err)
+		defer tmp.Close()
+
+		for name, scratch := range map[string]io.ReadWriter{"buffer": &bytes.Buffer{}, "file": tmp} {
+			t.Run(name, func(t *testing.T) {
+				var out bytes.Buffer
+				w := NewStreamWriter(&out, scratch)
+				expected := writerInput(t, w)
+				n, err := w.Flush()
+				require.NoError(t, err)
+				assert.EqualValues(t, out.Len(), n)
+
+				buf, err := expected.ToBytes()
+				require.NoError(t, err)
+				assert.Equal(t, buf, out.Bytes())
+			})
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		var out bytes.Buffer
+		_, err := NewStreamWriter(&out, &bytes.Buffer{}).Flush()
+		require.NoError(t, err)
+		buf, err := New().ToBytes()
+		require.NoError(t, err)
+		assert.Equal(t, buf, out.Bytes())
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		w := NewWriter()
+		require.NoError(t, w.Add(1<<32))
+		assert.ErrorIs(t, w.Add(1), roaring.ErrWriterOutOfOrder)
+		assert.ErrorIs(t, w.AddRange(5, 1<<33), roaring.ErrWriterOutOfOrder)
+		_, err := w.Flush()
+		assert.ErrorIs(t, err, roaring.ErrWriterNoOutput)
+
+		w = NewStreamWriter(&bytes.Buffer{}, &bytes.Buffer{})
+		require.NoError(t, w.Add(1))
+		_, err = w.Flush()
+		require.NoError(t, err)
+		assert.ErrorIs(t, w.Add(2), roaring.ErrWriterClosed)
+	})
+}
@@ -1941,6 +1941,20 @@ func TestHash(t *testing.T) {
 	hashTest(t, 4097)
 }
 
+func TestHashStable(t *testing.T) {
+	// the checksums of existing bitmaps must not change, whatever their container types
+	rb := BitmapOf(1, 2, 3, 1000, 1<<16)
+	for x := uint32(2 << 16); x < 2<<16+5000; x++ {
+		rb.Add(x)
+	}
+	require.False(t, rb.HasRunCompression())
+	assert.Equal(t, uint64(0x3287b4cb6f7cb2c0), rb.Checksum())
+
+	rb.RunOptimize()
+	require.True(t, rb.HasRunCompression())
+	assert.Equal(t, uint64(0x69959a6ca2511a89), rb.Checksum())
+}
+
 func rTest(t *testing.T, N int) {
 	for gap := 1; gap <= 65536; gap *= 2 {
 		bs1 := bitset.New(0)
@@ -2211,6 +2225,45 @@ func TestNextManyOfAddRangeAcrossContainers(t *testing.T) {
 	assert.Equal(t, 0, n)
 }
 
+func TestReverseManyIterator(t *testing.T) {
+	rb := NewBitmap()
+	rb.AddMany([]uint32{1, 2, 15, 9999, MaxUint16, MaxUint32})
+	rb.AddRange(1<<16+100, 1<<16+300)
+	rb.AddRange(65530, 65540)
+	for i := uint32(0); i < 10000; i += 2 {
+		rb.Add(2<<16 + i)
+	}
+	rb.RunOptimize()
+
+	expected := rb.ToArray()
+	for i, j := 0, len(expected)-1; i < j; i, j = i+1, j-1 {
+		expected[i], expected[j] = expected[j], expected[i]
+	}
+
+	for _, size := range []int{1, 3, 64, 100, 1000, len(expected) + 1} {
+		it := rb.ReverseManyIterator()
+		buf := make([]uint32, size)
+		var got []uint32
+		for n := it.NextMany(buf); n > 0; n = it.NextMany(buf) {
+			got = append(got, buf[:n]...)
+		}
+		assert.Equal(t, expected, got, "buffer size %d", size)
+
+		it = rb.ReverseManyIterator()
+		buf64 := make([]uint64, size)
+		var got64 []uint64
+		for n := it.NextMany64(1<<32, buf64); n > 0; n = it.NextMany64(1<<32, buf64) {
+			got64 = append(got64, buf64[:n]...)
+		}
+		require.Len(t, got64, len(expected))
+		for i, v := range got64 {
+			assert.Equal(t, uint64(expected[i])|1<<32, v)
+		}
+	}
+
+	assert.Equal(t, 0, NewBitmap().ReverseManyIterator().NextMany(make([]uint32, 8)))
+}
+
 func TestDoubleAdd(t *testing.T) {
 	t.Run("doubleadd ", func(t *testing.T) {
 		rb := NewBitmap()
@@ -2565,6 +2618,133 @@ func TestIteratorAdvance(t *testing.T) {
 	})
 }
 
+func TestReverseIteratorRetreat(t *testing.T) {
+	bm := New()
+	bm.AddMany([]uint32{1, 2, 15, 16, 31, 32, 33, 9999, MaxUint16})
+	bm.AddRange(1<<16+100, 1<<16+200)
+	for i := uint32(0); i < 10000; i += 2 {
+		bm.Add(2<<16 + i)
+	}
+	bm.RunOptimize()
+	values := bm.ToArray()
+
+	// expected returns the largest value not larger than maxval, if any
+	expected := func(maxval uint32) (uint32, bool) {
+		for k := len(values) - 1; k >= 0; k-- {
+			if values[k] <= maxval {
+				return values[k], true
+			}
+		}
+		return 0, false
+	}
+
+	maxvals := []uint32{MaxUint32, 2<<16 + 9999, 2<<16 + 9998, 2<<16 + 1, 2 << 16, 1<<16 + 250, 1<<16 + 150, 1<<16 + 100, 1 << 16, 9999, 34, 31, 14, 1, 0}
+
+	t.Run("retreat by using a new int iterator", func(t *testing.T) {
+		for _, maxval := range maxvals {
+			i := bm.ReverseIterator().(IntReversePeekable)
+			i.RetreatIfNeeded(maxval)
+
+			v, ok := expected(maxval)
+			require.Equal(t, ok, i.HasNext(), "maxval %d", maxval)
+			if ok {
+				assert.Equal(t, v, i.PeekNext(), "maxval %d", maxval)
+			}
+		}
+	})
+
+	t.Run("retreat by using the same int iterator", func(t *testing.T) {
+		i := bm.ReverseIterator().(IntReversePeekable)
+
+		for _, maxval := range maxvals {
+			i.RetreatIfNeeded(maxval)
+
+			v, ok := expected(maxval)
+			require.Equal(t, ok, i.HasNext(), "maxval %d", maxval)
+			if ok {
+				assert.Equal(t, v, i.PeekNext(), "maxval %d", maxval)
+			}
+		}
+	})
+
+	t.Run("retreat does not move forward", func(t *testing.T) {
+		i := bm.ReverseIterator().(IntReversePeekable)
+		i.RetreatIfNeeded(9999)
+		i.RetreatIfNeeded(MaxUint32)
+
+		assert.True(t, i.HasNext())
+		assert.EqualValues(t, 9999, i.PeekNext())
+	})
+}
+
+func TestIteratorRange(t *testing.T) {
+	bm := New()
+	bm.AddMany([]uint32{1, 2, 15, 16, 31, 32, 33, 9999, MaxUint16, MaxUint32})
+	bm.AddRange(1<<16+100, 1<<16+200)
+	for i := uint32(0); i < 10000; i += 2 {
+		bm.Add(2<<16 + i)
+	}
+	bm.RunOptimize()
+	values := bm.ToArray()
+
+	ranges := [][2]uint64{
+		{0, MaxRange},
+		{0, 0},
+		{5, 5},
+		{20, 10},
+		{0, 16},
+		{2, 33},
+		{33, 34},
+		{1<<16 + 150, 2<<16 + 7},
+		{2<<16 + 1, 2<<16 + 3},
+		{2<<16 + 9000, MaxUint32},
+		{MaxUint32, MaxRange},
+		{MaxUint32, MaxRange + 10},
+		{MaxRange, MaxRange + 10},
+	}
+
+	for _, r := range ranges {
+		var want []uint32
+		for _, v := range values {
+			if uint64(v) >= r[0] && uint64(v) < r[1] {
+				want = append(want, v)
+			}
+		}
+
+		var got []uint32
+		for i := bm.IteratorRange(r[0], r[1]); i.HasNext(); {
+			got = append(got, i.Next())
+		}
+		assert.Equal(t, want, got, "range %v", r)
+
+		got = got[:0]
+		for i := bm.ReverseIteratorRange(r[0], r[1]); i.HasNext(); {
+			got = append([]uint32{i.Next()}, got...)
+		}
+		if len(want) == 0 {
+			assert.Empty(t, got, "range %v", r)
+		} else {
+			assert.Equal(t, want, got, "range %v", r)
+		}
+	}
+
+	t.Run("seek within the range", func(t *testing.T) {
+		i := bm.IteratorRange(10, 2<<16)
+		i.AdvanceIfNeeded(1 << 16)
+		assert.True(t, i.HasNext())
+		assert.EqualValues(t, 1<<16+100, i.PeekNext())
+		i.AdvanceIfNeeded(1<<16 + 200)
+		assert.False(t, i.HasNext())
+
+		r := bm.ReverseIteratorRange(10, 2<<16)
+		r.RetreatIfNeeded(1<<16 + 150)
+		assert.True(t, r.HasNext())
+		assert.EqualValues(t, 1<<16+150, r.PeekNext())
+		r.RetreatIfNeeded(9)
+		assert.False(t, r.HasNext())
+	})
+}
+
 func TestPackageFlipMaxRangeEnd(t *testing.T) {
 	var empty Bitmap
 	flipped := Flip(&empty, 0, MaxRange)
@@ -3088,6 +3268,46 @@ func TestNextAndPreviousValue(t *testing.T) {
 
 		}
 	})
+
+	t.Run("absent values across container boundaries", func(t *testing.T) {
+		bmp := BitmapOf(65535)
+		assert.Equal(t, int64(65536), bmp.NextAbsentValue(65535))
+		assert.Equal(t, int64(65534), bmp.PreviousAbsentValue(65535))
+
+		bmp = New()
+		bmp.AddRange(65530, 3*65536+10)
+		assert.Equal(t, int64(3*65536+10), bmp.NextAbsentValue(65530))
+		assert.Equal(t, int64(3*65536+10), bmp.NextAbsentValue(2*65536))
+		assert.Equal(t, int64(65529), bmp.PreviousAbsentValue(3*65536+9))
+		assert.Equal(t, int64(65529), bmp.PreviousAbsentValue(65536))
+
+		bmp = New()
+		bmp.AddRange(MaxUint32-70000, MaxUint32+1)
+		assert.Equal(t, int64(-1), bmp.NextAbsentValue(MaxUint32-70000))
+		assert.Equal(t, int64(MaxUint32-70001), bmp.PreviousAbsentValue(MaxUint32))
+		assert.Equal(t, int64(MaxUint32-70001), bmp.PreviousAbsentValue(MaxUint32-65536))
+
+		bmp = New()
+		bmp.AddRange(0, 70000)
+		assert.Equal(t, int64(-1), bmp.PreviousAbsentValue(69999))
+		assert.Equal(t, int64(70000), bmp.NextAbsentValue(0))
+	})
+
+	// these used to return -1 as soon as the last container was full, and 0 for 65535
+	t.Run("full last container", func(t *testing.T) {
+		bmp := New()
+		bmp.AddRange(0, 1<<16)
+		assert.Equal(t, int64(1<<16), bmp.NextAbsentValue(0))
+		assert.Equal(t, int64(1<<16), bmp.NextAbsentValue(65535))
+
+		bmp = New()
+		bmp.AddRange(1<<16, 2<<16)
+		assert.Equal(t, int64(1<<16-1), bmp.PreviousAbsentValue(2<<16-1))
+		assert.Equal(t, int64(1<<16-1), bmp.PreviousAbsentValue(1<<16))
+
+		bmp = BitmapOf(65535, 70000)
+		assert.Equal(t, int64(65536), bmp.NextAbsentValue(65535))
+	})
 }
 
 func BenchmarkFromDense(b *testing.B) {
@@ -3236,3 +3456,187 @@ func BenchmarkArrayUnionThreshold(b *testing.B) {
 		})
 	}
 }
+
+func TestRangeCardinality(t *testing.T) {
+	rb := NewBitmap()
+	rb.AddRange(10, 100)                 // run
+	rb.AddMany([]uint32{1 << 16, 70000}) // array
+	for i := uint32(0); i < 10000; i++ {
+		rb.Add(3<<16 + 2*i) // bitmap
+	}
+	rb.AddRange(5<<16, 7<<16) // full containers
+	rb.Add(MaxUint32)
+	rb.RunOptimize()
+
+	naive := func(start, end uint64) uint64 {
+		count := uint64(0)
+		rb.Iterate(func(x uint32) bool {
+			if uint64(x) >= start && uint64(x) < end {
+				count++
+			}
+			return true
+		})
+		return count
+	}
+
+	ranges := [][2]uint64{
+		{0, 0}, {5, 3}, {0, 10}, {0, 11}, {10, 100}, {50, 60}, {99, 1 << 16}, {0, 1<<16 + 1},
+		{3 << 16, 3<<16 + 1}, {3<<16 + 1, 3<<16 + 2}, {3<<16 + 100, 3<<16 + 5000}, {2 << 16, 4 << 16},
+		{5 << 16, 7 << 16}, {5<<16 + 1, 7<<16 - 1}, {5<<16 - 1, 7<<16 + 1}, {0, MaxUint32}, {0, MaxUint32 + 1},
+		{MaxUint32, MaxUint32 + 1}, {0, 1 << 40},
+	}
+	for _, r := range ranges {
+		assert.Equal(t, naive(r[0], r[1]), rb.RangeCardinality(r[0], r[1]), "range %v", r)
+		assert.Equal(t, naive(r[0], r[1]) == r[1]-r[0] || r[0] >= r[1], rb.ContainsRange(r[0], r[1]), "range %v", r)
+	}
+
+	assert.True(t, rb.ContainsRange(5<<16, 7<<16))
+	assert.True(t, rb.ContainsRange(5<<16+3, 6<<16+3))
+	assert.False(t, rb.ContainsRange(5<<16-1, 6<<16))
+	assert.False(t, rb.ContainsRange(MaxUint32, MaxUint32+2))
+	assert.True(t, rb.ContainsRange(MaxUint32, MaxUint32+1))
+	assert.False(t, NewBitmap().ContainsRange(0, 1))
+	assert.EqualValues(t, 0, NewBitmap().RangeCardinality(0, 1<<32))
+}
+
+func TestRelation(t *testing.T) {
+	naive := func(a, b *Bitmap) SetRelation {
+		aInB := AndNot(a, b).IsEmpty()
+		bInA := AndNot(b, a).IsEmpty()
+		switch {
+		case aInB && bInA:
+			return Equal
+		case aInB:
+			return Subset
+		case bInA:
+			return Superset
+		case a.Intersects(b):
+			return Overlapping
+		}
+		return Disjoint
+	}
+
+	runs := New()
+	runs.AddRange(0, 200000)
+	runs.RunOptimize()
+	dense := New()
+	for i := uint32(0); i < 200000; i += 2 {
+		dense.Add(i)
+	}
+	sparse := BitmapOf(3, 70000, 140000)
+	other := BitmapOf(1, 70001, 1<<20)
+	far := BitmapOf(1<<20, 1<<30)
+	bitmaps := []*Bitmap{New(), runs, dense, sparse, other, far, dense.Clone()}
+
+	for i, a := range bitmaps {
+		for j, b := range bitmaps {
+			expected := naive(a, b)
+			assert.Equal(t, expected, Relation(a, b), "bitmaps %d and %d", i, j)
+			assert.Equal(t, expected == Subset || expected == Equal, a.IsSubsetOf(b), "bitmaps %d and %d", i, j)
+			assert.Equal(t, expected == Subset, a.IsStrictSubsetOf(b), "bitmaps %d and %d", i, j)
+		}
+	}
+
+	assert.Equal(t, Equal, Relation(dense, dense.Clone()))
+	assert.Equal(t, Subset, Relation(sparse, runs))
+	assert.Equal(t, Superset, Relation(runs, dense))
+	assert.Equal(t, Overlapping, Relation(dense, sparse))
+	assert.Equal(t, Disjoint, Relation(sparse, other))
+	assert.Equal(t, Subset, Relation(New(), sparse))
+	assert.Equal(t, "overlapping", Overlapping.String())
+}
+
+func TestBinaryCardinalities(t *testing.T) {
+	r := rand.New(rand.NewSource(42))
+	random := func() *Bitmap {
+		rb := New()
+		rb.AddRange(uint64(r.Intn(1<<18)), uint64(r.Intn(1<<18)))
+		for i := 0; i < 5000; i++ {
+			rb.Add(uint32(r.Intn(1 << 19)))
+		}
+		for i := 0; i < 20; i++ {
+			rb.Add(uint32(r.Intn(1 << 24)))
+		}
+		if r.Intn(2) == 0 {
+			rb.RunOptimize()
+		}
+		return rb
+	}
+	bitmaps := []*Bitmap{New(), random(), random(), random()}
+
+	for i, a := range bitmaps {
+		for j, b := range bitmaps {
+			and := And(a, b).GetCardinality()
+			assert.Equal(t, Or(a, b).GetCardinality(), a.OrCardinality(b), "bitmaps %d and %d", i, j)
+			assert.Equal(t, AndNot(a, b).GetCardinality(), a.AndNotCardinality(b), "bitmaps %d and %d", i, j)
+			assert.Equal(t, Xor(a, b).GetCardinality(), a.XorCardinality(b), "bitmaps %d and %d", i, j)
+
+			if a.IsEmpty() || b.IsEmpty() {
+				assert.Zero(t, a.OverlapCoefficient(b))
+				continue
+			}
+			card1, card2 := a.GetCardinality(), b.GetCardinality()
+			assert.InDelta(t, float64(and)/float64(card1+card2-and), a.Jaccard(b), 1e-12)
+			assert.InDelta(t, 2*float64(and)/float64(card1+card2), a.Dice(b), 1e-12)
+			assert.InDelta(t, float64(and)/math.Min(float64(card1), float64(card2)), a.OverlapCoefficient(b), 1e-12)
+		}
+	}
+
+	assert.Zero(t, New().Jaccard(New()))
+	assert.Zero(t, New().Dice(New()))
+	assert.Equal(t, 1.0, bitmaps[1].Jaccard(bitmaps[1]))
+	assert.Equal(t, 1.0, BitmapOf(1, 2).OverlapCoefficient(BitmapOf(1, 2, 3)))
+}
+
+func TestIterateRanges(t *testing.T) {
+	rb := BitmapOf(1, 2, 3, 7, 65535, 65536, 65537, 200000, MaxUint32)
+	rb.AddRange(3*65536-10, 5*65536+10)
+	for i := uint32(6 * 65536); i < 6*65536+15000; i += 3 {
+		rb.Add(i)
+		rb.Add(i + 1)
+	}
+	rb.AddRange(7*65536, 7*65536+100)
+	rb.AddRange(MaxUint32-1000, MaxUint32-10)
+	rb.RunOptimize()
+
+	var expected []Interval
+	rb.Iterate(func(x uint32) bool {
+		if n := len(expected); n > 0 && expected[n-1].End == uint64(x) {
+			expected[n-1].End++
+		} else {
+			expected = append(expected, Interval{uint64(x), uint64(x) + 1})
+		}
+		return true
+	})
+
+	var actual []Interval
+	rb.IterateRanges(func(start, endExclusive uint64) bool {
+		actual = append(actual, Interval{start, endExclusive})
+		return true
+	})
+	assert.Equal(t, expected, actual)
+	assert.Equal(t, expected, rb.ToIntervals())
+	assert.Equal(t, Interval{65535, 65538}, expected[2])
+	assert.Equal(t, Interval{MaxUint32, MaxUint32 + 1}, expected[len(expected)-1])
+
+	actual = actual[:0]
+	it := rb.Ranges()
+	for it.HasNext() {
+		start, end := it.Next()
+		actual = append(actual, Interval{start, end})
+	}
+	assert.Equal(t, expected, actual)
+
+	assert.True(t, rb.Equals(FromIntervals(rb.ToIntervals()...)))
+	assert.True(t, FromIntervals(Interval{10, 20}, Interval{0, 5}, Interval{15, 30}).Equals(FromIntervals(Interval{0, 5}, Interval{10, 30})))
+
+	count := 0
+	rb.IterateRanges(func(start, endExclusive uint64) bool {
+		count++
+		return count < 3
+	})
+	assert.Equal(t, 3, count)
+
+	assert.Empty(t, New().ToIntervals())
+	assert.False(t, New().Ranges().HasNext())
+}
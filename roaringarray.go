@@ -41,7 +41,8 @@ type container interface {
 	xor(r container) container
 	getShortIterator() shortPeekable
 	iterate(cb func(x uint16) bool) bool
-	getReverseIterator() shortIterable
+	iterateRuns(cb func(start, endx int) bool) bool // runs of consecutive values as [start, endx)
+	getReverseIterator() shortReversePeekable
 	getManyIterator() manyIterable
 	contains(i uint16) bool
 	maximum() uint16
@@ -93,8 +94,117 @@ var (
 	ErrEmptyKeys             = errors.New("keys were empty")
 	ErrKeySortOrder          = errors.New("keys were out of order")
 	ErrCardinalityConstraint = errors.New("size of arrays was not coherent")
+	// ErrCardinalityMismatch is returned when the content of a container does not match the cardinality of its header.
+	ErrCardinalityMismatch = errors.New("container cardinality did not match its header")
+	// ErrContainerLimit is returned when the input has more containers than ReadOptions.MaxContainers.
+	ErrContainerLimit = errors.New("too many containers")
+	// ErrCardinalityLimit is returned when the input has more values than ReadOptions.MaxCardinality.
+	ErrCardinalityLimit = errors.New("too many values")
+	// ErrAllocationLimit is returned when decoding the input needs more than ReadOptions.MaxAllocatedBytes.
+	ErrAllocationLimit = errors.New("too many bytes to allocate")
 )
 
+// ReadOptions limits the resources used to decode an untrusted serialized bitmap,
+// see ReadFromWithOptions. A zero field means that there is no limit.
+type ReadOptions struct {
+	// MaxContainers is the maximal number of containers.
+	MaxContainers uint64
+	// MaxCardinality is the maximal number of values.
+	MaxCardinality uint64
+	// MaxAllocatedBytes is the maximal size of the decoded bitmap, as reported by GetSizeInBytes.
+	MaxAllocatedBytes uint64
+}
+
+func (opts *ReadOptions) checkContainers(size int, offset int64) error {
+	if opts.MaxContainers > 0 && uint64(size) > opts.MaxContainers {
+		return &DeserializationError{Kind: LimitExceeded, Offset: offset, Container: -1,
+			Err: fmt.Errorf("%d containers, at most %d are allowed: %w", size, opts.MaxContainers, ErrContainerLimit)}
+	}
+	return nil
+}
+
+// checkHeader verifies the descriptive header, found at offset, against the limits and returns
+// the number of bytes that the containers will need, not counting the intervals of the run containers.
+func (opts *ReadOptions) checkHeader(keycard []uint16, isRun func(i int) bool, offset int64) (uint64, error) {
+	size := len(keycard) / 2
+	cardinality := uint64(0)
+	allocated := uint64(8)
+	for i := 0; i < size; i++ {
+		if i > 0 && keycard[2*i] <= keycard[2*i-2] {
+			return 0, &DeserializationError{Kind: UnsortedKeys, Offset: offset + 4*int64(i), Container: i, Err: ErrKeySortOrder}
+		}
+		card := int(keycard[2*i+1]) + 1
+		cardinality += uint64(card)
+		allocated += 2
+		switch {
+		case isRun(i):
+			allocated += baseRc16Size
+		case card > arrayDefaultMaxSize:
+			allocated += 2 * arrayDefaultMaxSize
+		default:
+			allocated += 2 * uint64(card)
+		}
+	}
+
+	if opts.MaxCardinality > 0 && cardinality > opts.MaxCardinality {
+		return 0, &DeserializationError{Kind: LimitExceeded, Offset: offset, Container: -1,
+			Err: fmt.Errorf("%d values, at most %d are allowed: %w", cardinality, opts.MaxCardinality, ErrCardinalityLimit)}
+	}
+	if err := opts.checkAllocated(allocated, offset, -1); err != nil {
+		return 0, err
+	}
+	return allocated, nil
+}
+
+func (opts *ReadOptions) checkAllocated(allocated uint64, offset int64, container int) error {
+	if opts.MaxAllocatedBytes > 0 && allocated > opts.MaxAllocatedBytes {
+		return &DeserializationError{Kind: LimitExceeded, Offset: offset, Container: container,
+			Err: fmt.Errorf("%d bytes, at most %d are allowed: %w", allocated, opts.MaxAllocatedBytes, ErrAllocationLimit)}
+	}
+	return nil
+}
+
+// checkDecoded verifies the invariants of a container decoded from an untrusted input.
+// Unlike validate, it runs in linear time and accepts every valid encoding.
+func checkDecoded(c container, card int) error {
+	switch t := c.(type) {
+	case *arrayContainer:
+		for i := 1; i < len(t.content); i++ {
+			if t.content[i-1] >= t.content[i] {
+				return ErrArrayIncorrectSort
+			}
+		}
+	case *bitmapContainer:
+		if int(popcntSlice(t.bitmap)) != card {
+			return ErrCardinalityMismatch
+		}
+	case *runContainer16:
+		if len(t.iv) == 0 {
+			return ErrRunIntervalsEmpty
+		}
+		sum := 0
+		for i, iv := range t.iv {
+			if int(iv.start)+int(iv.length) > MaxUint16 {
+				return ErrRunIntervalRange
+			}
+			if i > 0 {
+				prev := t.iv[i-1]
+				if iv.start <= prev.start {
+					return ErrRunNonSorted
+				}
+				if int(iv.start) <= int(prev.last())+1 {
+					return ErrRunIntervalOverlap
+				}
+			}
+			sum += iv.runlen()
+		}
+		if sum != card {
+			return ErrCardinalityMismatch
+		}
+	}
+	return nil
+}
+
 // careful: range is [firstOfRange,lastOfRange]
 func rangeOfOnes(start, last int) container {
 	if start > MaxUint16 {
@@ -112,11 +222,48 @@ func rangeOfOnes(start, last int) container {
 	return newRunContainer16Range(uint16(start), uint16(last))
 }
 
+// cardinalityInRange returns the number of values of c in [start,end),
+// where start < end <= 1<<16.
+func cardinalityInRange(c container, start, end int) int {
+	if bc, ok := c.(*bitmapContainer); ok {
+		return bc.getCardinalityInRange(uint(start), uint(end))
+	}
+	answer := c.rank(uint16(end - 1))
+	if start > 0 {
+		answer -= c.rank(uint16(start - 1))
+	}
+	return answer
+}
+
 type roaringArray struct {
 	keys            []uint16
 	containers      []container `msg:"-"` // don't try to serialize directly.
 	needCopyOnWrite []bool
 	copyOnWrite     bool
+
+	// lazy holds the serialized form of the containers of a bitmap read
+	// with ReadFromLazy. A nil entry in containers means that the
+	// container at the same index in lazy has not been decoded yet.
+	lazy []lazyContainer `msg:"-"`
+}
+
+// lazyContainer is a container which has been located in a serialized
+// bitmap but not decoded yet.
+type lazyContainer struct {
+	data []byte
+	card int
+	typ  contype
+}
+
+func (lc *lazyContainer) decode() container {
+	switch lc.typ {
+	case run16Contype:
+		return &runContainer16{iv: byteSliceAsInterval16Slice(lc.data)}
+	case bitmapContype:
+		return &bitmapContainer{cardinality: lc.card, bitmap: byteSliceAsUint64Slice(lc.data)}
+	default:
+		return &arrayContainer{byteSliceAsUint16Slice(lc.data)}
+	}
 }
 
 func newRoaringArray() *roaringArray {
@@ -131,6 +278,7 @@ func newRoaringArray() *roaringArray {
 //	(possibly all) elements of ra.containers in-place with space
 //	optimized versions.
 func (ra *roaringArray) runOptimize() {
+	ra.materialize()
 	for i := range ra.containers {
 		ra.containers[i] = ra.containers[i].toEfficientContainer()
 	}
@@ -144,7 +292,7 @@ func (ra *roaringArray) appendContainer(key uint16, value container, mustCopyOnW
 
 func (ra *roaringArray) appendWithoutCopy(sa roaringArray, startingindex int) {
 	mustCopyOnWrite := sa.needCopyOnWrite[startingindex]
-	ra.appendContainer(sa.keys[startingindex], sa.containers[startingindex], mustCopyOnWrite)
+	ra.appendContainer(sa.keys[startingindex], sa.getContainerAtIndex(startingindex), mustCopyOnWrite)
 }
 
 func (ra *roaringArray) appendCopy(sa roaringArray, startingindex int) {
@@ -152,9 +300,9 @@ func (ra *roaringArray) appendCopy(sa roaringArray, startingindex int) {
 	copyonwrite := (ra.copyOnWrite && sa.copyOnWrite) || sa.needsCopyOnWrite(startingindex)
 	if !copyonwrite {
 		// since there is no copy-on-write, we need to clone the container (this is important)
-		ra.appendContainer(sa.keys[startingindex], sa.containers[startingindex].clone(), copyonwrite)
+		ra.appendContainer(sa.keys[startingindex], sa.getContainerAtIndex(startingindex).clone(), copyonwrite)
 	} else {
-		ra.appendContainer(sa.keys[startingindex], sa.containers[startingindex], copyonwrite)
+		ra.appendContainer(sa.keys[startingindex], sa.getContainerAtIndex(startingindex), copyonwrite)
 		if !sa.needsCopyOnWrite(startingindex) {
 			sa.setNeedsCopyOnWrite(startingindex)
 		}
@@ -183,14 +331,14 @@ func (ra *roaringArray) appendCopiesUntil(sa roaringArray, stoppingKey uint16) {
 		}
 		thiscopyonewrite := copyonwrite || sa.needsCopyOnWrite(i)
 		if thiscopyonewrite {
-			ra.appendContainer(sa.keys[i], sa.containers[i], thiscopyonewrite)
+			ra.appendContainer(sa.keys[i], sa.getContainerAtIndex(i), thiscopyonewrite)
 			if !sa.needsCopyOnWrite(i) {
 				sa.setNeedsCopyOnWrite(i)
 			}
 
 		} else {
 			// since there is no copy-on-write, we need to clone the container (this is important)
-			ra.appendContainer(sa.keys[i], sa.containers[i].clone(), thiscopyonewrite)
+			ra.appendContainer(sa.keys[i], sa.getContainerAtIndex(i).clone(), thiscopyonewrite)
 		}
 	}
 }
@@ -209,13 +357,13 @@ func (ra *roaringArray) appendCopiesAfter(sa roaringArray, beforeStart uint16) {
 	for i := startLocation; i < sa.size(); i++ {
 		thiscopyonewrite := copyonwrite || sa.needsCopyOnWrite(i)
 		if thiscopyonewrite {
-			ra.appendContainer(sa.keys[i], sa.containers[i], thiscopyonewrite)
+			ra.appendContainer(sa.keys[i], sa.getContainerAtIndex(i), thiscopyonewrite)
 			if !sa.needsCopyOnWrite(i) {
 				sa.setNeedsCopyOnWrite(i)
 			}
 		} else {
 			// since there is no copy-on-write, we need to clone the container (this is important)
-			ra.appendContainer(sa.keys[i], sa.containers[i].clone(), thiscopyonewrite)
+			ra.appendContainer(sa.keys[i], sa.getContainerAtIndex(i).clone(), thiscopyonewrite)
 		}
 	}
 }
@@ -225,6 +373,7 @@ func (ra *roaringArray) removeIndexRange(begin, end int) {
 		return
 	}
 
+	ra.materialize()
 	r := end - begin
 
 	copy(ra.keys[begin:], ra.keys[end:])
@@ -242,6 +391,11 @@ func (ra *roaringArray) resize(newsize int) {
 	ra.keys = ra.keys[:newsize]
 	ra.containers = ra.containers[:newsize]
 	ra.needCopyOnWrite = ra.needCopyOnWrite[:newsize]
+	if newsize == 0 {
+		ra.lazy = nil
+	} else if newsize < len(ra.lazy) {
+		ra.lazy = ra.lazy[:newsize]
+	}
 }
 
 func (ra *roaringArray) clear() {
@@ -250,6 +404,7 @@ func (ra *roaringArray) clear() {
 }
 
 func (ra *roaringArray) clone() *roaringArray {
+	ra.materialize()
 	sa := roaringArray{}
 	sa.copyOnWrite = ra.copyOnWrite
 
@@ -287,7 +442,7 @@ func (ra *roaringArray) clone() *roaringArray {
 func (ra *roaringArray) cloneCopyOnWriteContainers() {
 	for i, needCopyOnWrite := range ra.needCopyOnWrite {
 		if needCopyOnWrite {
-			ra.containers[i] = ra.containers[i].clone()
+			ra.containers[i] = ra.getContainerAtIndex(i).clone()
 			ra.needCopyOnWrite[i] = false
 		}
 	}
@@ -305,11 +460,45 @@ func (ra *roaringArray) getContainer(x uint16) container {
 	if i < 0 {
 		return nil
 	}
-	return ra.containers[i]
+	return ra.getContainerAtIndex(i)
 }
 
 func (ra *roaringArray) getContainerAtIndex(i int) container {
-	return ra.containers[i]
+	if c := ra.containers[i]; c != nil {
+		return c
+	}
+	return ra.decodeContainerAtIndex(i)
+}
+
+// decodeContainerAtIndex decodes the lazily read container at index i.
+func (ra *roaringArray) decodeContainerAtIndex(i int) container {
+	c := ra.lazy[i].decode()
+	ra.containers[i] = c
+	return c
+}
+
+// materialize decodes every container which has not been decoded yet.
+// It must be called before the containers are moved around or accessed
+// without going through getContainerAtIndex.
+func (ra *roaringArray) materialize() {
+	if ra.lazy == nil {
+		return
+	}
+	for i := range ra.lazy {
+		if ra.containers[i] == nil {
+			ra.decodeContainerAtIndex(i)
+		}
+	}
+	ra.lazy = nil
+}
+
+// getCardinalityAtIndex returns the cardinality of the container at index i,
+// without decoding it if it was read lazily.
+func (ra *roaringArray) getCardinalityAtIndex(i int) int {
+	if c := ra.containers[i]; c != nil {
+		return c.getCardinality()
+	}
+	return ra.lazy[i].card
 }
 
 func (ra *roaringArray) getFastContainerAtIndex(i int, needsWriteable bool) container {
@@ -323,7 +512,7 @@ func (ra *roaringArray) getFastContainerAtIndex(i int, needsWriteable bool) cont
 		}
 	case *bitmapContainer:
 		if needsWriteable && ra.needCopyOnWrite[i] {
-			c = ra.containers[i].clone()
+			c = t.clone()
 		}
 	}
 	return c
@@ -341,10 +530,10 @@ func (ra *roaringArray) getUnionedWritableContainer(pos int, other container) co
 
 func (ra *roaringArray) getWritableContainerAtIndex(i int) container {
 	if ra.needCopyOnWrite[i] {
-		ra.containers[i] = ra.containers[i].clone()
+		ra.containers[i] = ra.getContainerAtIndex(i).clone()
 		ra.needCopyOnWrite[i] = false
 	}
-	return ra.containers[i]
+	return ra.getContainerAtIndex(i)
 }
 
 // getIndex returns the index of the container with key `x`
@@ -364,6 +553,7 @@ func (ra *roaringArray) getKeyAtIndex(i int) uint16 {
 }
 
 func (ra *roaringArray) insertNewKeyValueAt(i int, key uint16, value container) {
+	ra.materialize()
 	ra.keys = append(ra.keys, 0)
 	ra.containers = append(ra.containers, nil)
 
@@ -388,6 +578,7 @@ func (ra *roaringArray) remove(key uint16) bool {
 }
 
 func (ra *roaringArray) removeAtIndex(i int) {
+	ra.materialize()
 	copy(ra.keys[i:], ra.keys[i+1:])
 	copy(ra.containers[i:], ra.containers[i+1:])
 
@@ -453,8 +644,8 @@ func (ra *roaringArray) equals(o interface{}) bool {
 			}
 		}
 
-		for i, c := range ra.containers {
-			if !c.equals(srb.containers[i]) {
+		for i := range ra.containers {
+			if !ra.getContainerAtIndex(i).equals(srb.getContainerAtIndex(i)) {
 				return false
 			}
 		}
@@ -476,6 +667,7 @@ func (ra *roaringArray) headerSize() uint64 {
 
 // should be dirt cheap
 func (ra *roaringArray) serializedSizeInBytes() uint64 {
+	ra.materialize()
 	answer := ra.headerSize()
 	for _, c := range ra.containers {
 		answer += uint64(c.serializedSizeInBytes())
@@ -485,6 +677,7 @@ func (ra *roaringArray) serializedSizeInBytes() uint64 {
 
 // spec: https://github.com/RoaringBitmap/RoaringFormatSpec
 func (ra *roaringArray) writeTo(w io.Writer) (n int64, err error) {
+	ra.materialize()
 	hasRun := ra.hasRunCompression()
 	isRunSizeInBytes := 0
 	cookieSize := 8
@@ -567,25 +760,46 @@ func (ra *roaringArray) toBytes() ([]byte, error) {
 	return buf.Bytes(), err
 }
 
-// Reads a serialized roaringArray from a byte slice.
-func (ra *roaringArray) readFrom(stream internal.ByteInput, cookieHeader ...byte) (int64, error) {
+// readFrom reads a serialized roaringArray from stream. When opts is not nil the input is not
+// trusted: opts limits the resources used and every container is checked once decoded.
+// When lazy is true and the input has an offset table, the data of every container is read
+// at once and each container is only decoded on first access.
+func (ra *roaringArray) readFrom(stream internal.ByteInput, lazy bool, opts *ReadOptions, cookieHeader ...byte) (int64, error) {
 	var cookie uint32
 	var err error
 	if len(cookieHeader) > 0 && len(cookieHeader) != 4 {
-		return int64(len(cookieHeader)), fmt.Errorf("error in roaringArray.readFrom: could not read initial cookie: incorrect size of cookie header")
+		return int64(len(cookieHeader)), &DeserializationError{Kind: InvalidCookie, Container: -1,
+			Err: fmt.Errorf("error in roaringArray.readFrom: could not read initial cookie: incorrect size of cookie header")}
+	}
+
+	// offset returns the position in the serialized bitmap, including the cookie read by the caller
+	base := int64(len(cookieHeader))
+	offset := func() int64 {
+		return base + stream.GetReadBytes()
 	}
+	// readError reports a failure of the stream while reading what starts at the offset at
+	readError := func(at int64, container int, msg string, err error) (int64, error) {
+		return stream.GetReadBytes(), &DeserializationError{Kind: readFailureKind(err), Offset: at,
+			Container: container, Err: fmt.Errorf("%s: %w", msg, err)}
+	}
+
 	if len(cookieHeader) == 4 {
 		cookie = binary.LittleEndian.Uint32(cookieHeader)
 	} else {
 		cookie, err = stream.ReadUInt32()
+		if err == io.EOF && stream.GetReadBytes() == 0 {
+			// the stream ended cleanly, before the bitmap
+			return 0, io.EOF
+		}
 		if err != nil {
-			return stream.GetReadBytes(), fmt.Errorf("error in roaringArray.readFrom: could not read initial cookie: %s", err)
+			return readError(0, -1, "error in roaringArray.readFrom: could not read initial cookie", err)
 		}
 	}
 	// If NextReturnsSafeSlice is false, then willNeedCopyOnWrite should be true
 	willNeedCopyOnWrite := !stream.NextReturnsSafeSlice()
 
 	var size uint32
+	var sizeOffset int64
 	var isRunBitmap []byte
 
 	if cookie&0x0000FFFF == serialCookie {
@@ -594,32 +808,57 @@ func (ra *roaringArray) readFrom(stream internal.ByteInput, cookieHeader ...byte
 		isRunBitmapSize := (int(size) + 7) / 8
 		isRunBitmap, err = stream.Next(isRunBitmapSize)
 		if err != nil {
-			return stream.GetReadBytes(), fmt.Errorf("malformed bitmap, failed to read is-run bitmap, got: %s", err)
+			return readError(offset(), -1, "malformed bitmap, failed to read is-run bitmap", err)
 		}
 	} else if cookie == serialCookieNoRunContainer {
+		sizeOffset = offset()
 		size, err = stream.ReadUInt32()
 		if err != nil {
-			return stream.GetReadBytes(), fmt.Errorf("malformed bitmap, failed to read a bitmap size: %s", err)
+			return readError(sizeOffset, -1, "malformed bitmap, failed to read a bitmap size", err)
 		}
 	} else {
-		return stream.GetReadBytes(), fmt.Errorf("error in roaringArray.readFrom: did not find expected serialCookie in header")
+		return stream.GetReadBytes(), &DeserializationError{Kind: InvalidCookie, Offset: 0, Container: -1,
+			Err: fmt.Errorf("error in roaringArray.readFrom: did not find expected serialCookie in header")}
 	}
 
 	if size > (1 << 16) {
-		return stream.GetReadBytes(), fmt.Errorf("it is logically impossible to have more than (1<<16) containers")
+		return stream.GetReadBytes(), &DeserializationError{Kind: InvalidContainerCount, Offset: sizeOffset, Container: -1,
+			Err: fmt.Errorf("it is logically impossible to have more than (1<<16) containers")}
+	}
+	if opts != nil {
+		if err := opts.checkContainers(int(size), sizeOffset); err != nil {
+			return stream.GetReadBytes(), err
+		}
 	}
 
 	// descriptive header
+	headerOffset := offset()
 	buf, err := stream.Next(2 * 2 * int(size))
 	if err != nil {
-		return stream.GetReadBytes(), fmt.Errorf("failed to read descriptive header: %s", err)
+		return readError(headerOffset, -1, "failed to read descriptive header", err)
 	}
 
 	keycard := byteSliceAsUint16Slice(buf)
 
+	var allocated uint64
+	if opts != nil {
+		allocated, err = opts.checkHeader(keycard, func(i int) bool {
+			return isRunBitmap != nil && isRunBitmap[i/8]&(1<<(i%8)) != 0
+		}, headerOffset)
+		if err != nil {
+			return stream.GetReadBytes(), err
+		}
+	}
+
+	var offsets []byte
 	if isRunBitmap == nil || size >= noOffsetThreshold {
-		if err := stream.SkipBytes(int(size) * 4); err != nil {
-			return stream.GetReadBytes(), fmt.Errorf("failed to skip bytes: %s", err)
+		if lazy {
+			offsets, err = stream.Next(int(size) * 4)
+			if err != nil {
+				return readError(offset(), -1, "failed to read offsets", err)
+			}
+		} else if err := stream.SkipBytes(int(size) * 4); err != nil {
+			return readError(offset(), -1, "failed to skip bytes", err)
 		}
 	}
 
@@ -642,22 +881,110 @@ func (ra *roaringArray) readFrom(stream internal.ByteInput, cookieHeader ...byte
 		ra.needCopyOnWrite = make([]bool, size)
 	}
 
+	ra.lazy = nil
+	if offsets != nil {
+		// The offsets locate the containers, so that the data of all of them but the last one
+		// is read at once. Each container is then only decoded on first access.
+		ra.lazy = make([]lazyContainer, size)
+		dataOffset := offset()
+		lastOffset := dataOffset
+		var data []byte
+		if size > 0 {
+			lastOffset = int64(binary.LittleEndian.Uint32(offsets[4*(size-1):]))
+			if lastOffset < dataOffset {
+				return stream.GetReadBytes(), &DeserializationError{Kind: CorruptContainer, Offset: dataOffset, Container: int(size - 1),
+					Err: fmt.Errorf("container offset %d is before the containers", lastOffset)}
+			}
+			data, err = stream.Next(int(lastOffset - dataOffset))
+			if err != nil {
+				return readError(dataOffset, 0, "failed to read containers", err)
+			}
+		}
+
+		expectedOffset := dataOffset
+		for i := uint32(0); i < size; i++ {
+			card := int(keycard[2*i+1]) + 1
+			ra.keys[i] = keycard[2*i]
+			ra.needCopyOnWrite[i] = willNeedCopyOnWrite
+			ra.containers[i] = nil
+
+			lc := lazyContainer{card: card, typ: arrayContype}
+			length := card * 2
+			if isRunBitmap != nil && isRunBitmap[i/8]&(1<<(i%8)) != 0 {
+				lc.typ = run16Contype
+				length = -1
+			} else if card > arrayDefaultMaxSize {
+				lc.typ = bitmapContype
+				length = arrayDefaultMaxSize * 2
+			}
+
+			containerOffset := int64(binary.LittleEndian.Uint32(offsets[4*i:]))
+			if containerOffset != expectedOffset {
+				return stream.GetReadBytes(), &DeserializationError{Kind: CorruptContainer, Offset: expectedOffset, Container: int(i),
+					Err: fmt.Errorf("container offset %d, expected %d", containerOffset, expectedOffset)}
+			}
+			if i == size-1 {
+				// the last container is read on its own, as the offsets do not give its size
+				if lc.typ == run16Contype {
+					nr, err := stream.ReadUInt16()
+					if err != nil {
+						return readError(containerOffset, int(i), "failed to read runtime container size", err)
+					}
+					length = int(nr) * 4
+				}
+				lc.data, err = stream.Next(length)
+				if err != nil {
+					return readError(containerOffset, int(i), "failed to read container", err)
+				}
+				ra.lazy[i] = lc
+				break
+			}
+
+			next := int64(binary.LittleEndian.Uint32(offsets[4*(i+1):]))
+			if next < containerOffset || next > lastOffset {
+				return stream.GetReadBytes(), &DeserializationError{Kind: CorruptContainer, Offset: containerOffset, Container: int(i + 1),
+					Err: fmt.Errorf("container offset %d is out of order", next)}
+			}
+			// the capacity is limited so that appending to the container does not overwrite the next one
+			lc.data = data[containerOffset-dataOffset : next-dataOffset : next-dataOffset]
+			if lc.typ == run16Contype && len(lc.data) >= 2 {
+				// skip the number of runs, which follows from the size of the container
+				lc.data = lc.data[2:]
+				length = len(lc.data) - len(lc.data)%4
+			}
+			if len(lc.data) != length {
+				return stream.GetReadBytes(), &DeserializationError{Kind: CorruptContainer, Offset: containerOffset, Container: int(i),
+					Err: fmt.Errorf("container of %d bytes, expected %d", next-containerOffset, length)}
+			}
+			ra.lazy[i] = lc
+			expectedOffset = next
+		}
+		return stream.GetReadBytes(), nil
+	}
+
 	for i := uint32(0); i < size; i++ {
 		key := keycard[2*i]
 		card := int(keycard[2*i+1]) + 1
 		ra.keys[i] = key
 		ra.needCopyOnWrite[i] = willNeedCopyOnWrite
 
+		containerOffset := offset()
 		if isRunBitmap != nil && isRunBitmap[i/8]&(1<<(i%8)) != 0 {
 			// run container
 			nr, err := stream.ReadUInt16()
 			if err != nil {
-				return 0, fmt.Errorf("failed to read runtime container size: %s", err)
+				return readError(containerOffset, int(i), "failed to read runtime container size", err)
 			}
 
+			if opts != nil {
+				allocated += perIntervalRc16Size * uint64(nr)
+				if err := opts.checkAllocated(allocated, containerOffset, int(i)); err != nil {
+					return stream.GetReadBytes(), err
+				}
+			}
 			buf, err := stream.Next(int(nr) * 4)
 			if err != nil {
-				return stream.GetReadBytes(), fmt.Errorf("failed to read runtime container content: %s", err)
+				return readError(containerOffset, int(i), "failed to read runtime container content", err)
 			}
 
 			nb := runContainer16{
@@ -669,7 +996,7 @@ func (ra *roaringArray) readFrom(stream internal.ByteInput, cookieHeader ...byte
 			// bitmap container
 			buf, err := stream.Next(arrayDefaultMaxSize * 2)
 			if err != nil {
-				return stream.GetReadBytes(), fmt.Errorf("failed to read bitmap container: %s", err)
+				return readError(containerOffset, int(i), "failed to read bitmap container", err)
 			}
 
 			nb := bitmapContainer{
@@ -682,7 +1009,7 @@ func (ra *roaringArray) readFrom(stream internal.ByteInput, cookieHeader ...byte
 			// array container
 			buf, err := stream.Next(card * 2)
 			if err != nil {
-				return stream.GetReadBytes(), fmt.Errorf("failed to read array container: %s", err)
+				return readError(containerOffset, int(i), "failed to read array container", err)
 			}
 
 			nb := arrayContainer{
@@ -691,16 +1018,26 @@ func (ra *roaringArray) readFrom(stream internal.ByteInput, cookieHeader ...byte
 
 			ra.containers[i] = &nb
 		}
+
+		if opts != nil {
+			if err := checkDecoded(ra.containers[i], card); err != nil {
+				return stream.GetReadBytes(), &DeserializationError{Kind: CorruptContainer, Offset: containerOffset, Container: int(i), Err: err}
+			}
+		}
 	}
 
 	return stream.GetReadBytes(), nil
 }
 
 func (ra *roaringArray) hasRunCompression() bool {
-	for _, c := range ra.containers {
+	for i, c := range ra.containers {
 		switch c.(type) {
 		case *runContainer16:
 			return true
+		case nil:
+			if ra.lazy[i].typ == run16Contype {
+				return true
+			}
 		}
 	}
 	return false
@@ -798,6 +1135,7 @@ func (ra *roaringArray) checkKeysSorted() bool {
 // validate checks the referential integrity
 // ensures len(keys) == len(containers), recurses and checks each container type
 func (ra *roaringArray) validate() error {
+	ra.materialize()
 	if len(ra.keys) == 0 {
 		return ErrEmptyKeys
 	}
@@ -66,6 +66,7 @@ var (
 	ErrRunIntervalEqual   = errors.New("intervals were equal")
 	ErrRunIntervalOverlap = errors.New("intervals overlapped or were continguous")
 	ErrRunIntervalSize    = errors.New("too many intervals relative to data")
+	ErrRunIntervalRange   = errors.New("interval extended beyond the container")
 	MaxNumIntervals       = 2048
 	MaxIntervalsSum       = 2048
 )
@@ -1136,6 +1137,15 @@ func (rc *runContainer16) iterate(cb func(x uint16) bool) bool {
 	return true
 }
 
+func (rc *runContainer16) iterateRuns(cb func(start, endx int) bool) bool {
+	for _, iv := range rc.iv {
+		if !cb(int(iv.start), int(iv.last())+1) {
+			return false
+		}
+	}
+	return true
+}
+
 // hasNext returns false if calling next will panic. It
 // returns true when there is at least one more value
 // available in the iteration sequence.
@@ -1232,6 +1242,91 @@ func (ri *runReverseIterator16) next() uint16 {
 	return next
 }
 
+// peekNext returns the next value in the iteration sequence without advancing the iterator
+func (ri *runReverseIterator16) peekNext() uint16 {
+	return ri.rc.iv[ri.curIndex].start + ri.curPosInIndex
+}
+
+// retreatIfNeeded retreats as long as the next value is larger than maxval
+func (ri *runReverseIterator16) retreatIfNeeded(maxval uint16) {
+	if !ri.hasNext() || ri.peekNext() <= maxval {
+		return
+	}
+
+	interval, isPresent, _ := ri.rc.searchRange(int(maxval), 0, ri.curIndex+1)
+
+	// if the maxval is present, set the curPosIndex at the right position
+	if isPresent {
+		ri.curIndex = interval
+		ri.curPosInIndex = maxval - ri.rc.iv[ri.curIndex].start
+	} else {
+		// otherwise interval is the last index of rc.iv which comes
+		// strictly before maxval, or -1 if there is none
+		ri.curIndex = interval
+		if ri.curIndex >= 0 {
+			ri.curPosInIndex = ri.rc.iv[ri.curIndex].length
+		}
+	}
+}
+
+// nextMany fills buf with values in decreasing order, returns how many values were written
+func (ri *runReverseIterator16) nextMany(hs uint32, buf []uint32) int {
+	n := 0
+
+	for n < len(buf) && ri.curIndex >= 0 {
+		// add as many as you can from this seq, going down to its start
+		moreVals := minOfInt(int(ri.curPosInIndex)+1, len(buf)-n)
+		base := uint32(ri.rc.iv[ri.curIndex].start+ri.curPosInIndex) | hs
+
+		// allows BCE
+		buf2 := buf[n : n+moreVals]
+		for i := range buf2 {
+			buf2[i] = base - uint32(i)
+		}
+		n += moreVals
+
+		if moreVals > int(ri.curPosInIndex) {
+			ri.curIndex--
+			if ri.curIndex >= 0 {
+				ri.curPosInIndex = ri.rc.iv[ri.curIndex].length
+			}
+		} else {
+			ri.curPosInIndex -= uint16(moreVals)
+		}
+	}
+
+	return n
+}
+
+// nextMany64 fills buf with values in decreasing order, returns how many values were written
+func (ri *runReverseIterator16) nextMany64(hs uint64, buf []uint64) int {
+	n := 0
+
+	for n < len(buf) && ri.curIndex >= 0 {
+		// add as many as you can from this seq, going down to its start
+		moreVals := minOfInt(int(ri.curPosInIndex)+1, len(buf)-n)
+		base := uint64(ri.rc.iv[ri.curIndex].start+ri.curPosInIndex) | hs
+
+		// allows BCE
+		buf2 := buf[n : n+moreVals]
+		for i := range buf2 {
+			buf2[i] = base - uint64(i)
+		}
+		n += moreVals
+
+		if moreVals > int(ri.curPosInIndex) {
+			ri.curIndex--
+			if ri.curIndex >= 0 {
+				ri.curPosInIndex = ri.rc.iv[ri.curIndex].length
+			}
+		} else {
+			ri.curPosInIndex -= uint16(moreVals)
+		}
+	}
+
+	return n
+}
+
 func (rc *runContainer16) newManyRunIterator16() *runIterator16 {
 	return rc.newRunIterator16()
 }
@@ -1967,7 +2062,7 @@ func (rc *runContainer16) getShortIterator() shortPeekable {
 	return rc.newRunIterator16()
 }
 
-func (rc *runContainer16) getReverseIterator() shortIterable {
+func (rc *runContainer16) getReverseIterator() shortReversePeekable {
 	return rc.newRunReverseIterator16()
 }
 
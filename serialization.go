@@ -2,7 +2,9 @@ package roaring
 
 import (
 	"encoding/binary"
+	"errors"
 	"io"
+	"strconv"
 )
 
 // writeTo for runContainer16 follows this
@@ -16,3 +18,101 @@ func (b *runContainer16) writeTo(stream io.Writer) (int, error) {
 	}
 	return stream.Write(buf)
 }
+
+// DeserializationErrorKind tells why a serialized bitmap was rejected, see DeserializationError.
+type DeserializationErrorKind int
+
+const (
+	// TruncatedInput means that the input ended before the bitmap was complete.
+	TruncatedInput DeserializationErrorKind = iota + 1
+	// ReadFailure means that the underlying reader returned an error other than io.EOF.
+	ReadFailure
+	// InvalidCookie means that the input does not start (or, for frozen bitmaps, end) with a known header.
+	InvalidCookie
+	// InvalidContainerCount means that the header announces more containers than possible.
+	InvalidContainerCount
+	// UnsortedKeys means that the keys of the containers are not strictly increasing.
+	UnsortedKeys
+	// CorruptContainer means that the content of a container is invalid.
+	CorruptContainer
+	// LimitExceeded means that the input exceeds one of the limits of ReadOptions.
+	LimitExceeded
+	// TrailingData means that the input has unexpected data after the bitmap.
+	TrailingData
+)
+
+// String returns the name of the kind
+func (k DeserializationErrorKind) String() string {
+	switch k {
+	case TruncatedInput:
+		return "truncated input"
+	case ReadFailure:
+		return "read failure"
+	case InvalidCookie:
+		return "invalid cookie"
+	case InvalidContainerCount:
+		return "invalid container count"
+	case UnsortedKeys:
+		return "unsorted keys"
+	case CorruptContainer:
+		return "corrupt container"
+	case LimitExceeded:
+		return "limit exceeded"
+	case TrailingData:
+		return "trailing data"
+	}
+	return "DeserializationErrorKind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// DeserializationError is the error returned when a serialized bitmap cannot be read
+// (ReadFrom, FromBuffer, FromUnsafeBytes, FrozenView and their roaring64 and BSI equivalents).
+// Use errors.As to inspect it; Err is the underlying cause, so that errors.Is also matches
+// the more specific errors such as io.ErrUnexpectedEOF, ErrKeySortOrder or ErrFrozenBitmapIncomplete.
+//
+// A stream which ends before the first byte of the bitmap is not reported as a DeserializationError:
+// ReadFrom and ReadFromWithOptions return io.EOF itself, as other io.Reader consumers do.
+//
+// When the bitmap is made of several bitmaps (roaring64.Bitmap, BSI), Container is the index
+// of the inner bitmap and Err is the DeserializationError of that bitmap, with offsets
+// relative to its own start.
+type DeserializationError struct {
+	// Kind tells what invariant failed.
+	Kind DeserializationErrorKind
+	// Offset is the byte offset in the input at which the problem was detected.
+	Offset int64
+	// Container is the index of the container concerned, or -1.
+	Container int
+	// Err is the underlying cause.
+	Err error
+}
+
+func (e *DeserializationError) Error() string {
+	msg := "roaring: " + e.Kind.String() + " at byte " + strconv.FormatInt(e.Offset, 10)
+	if e.Container >= 0 {
+		msg += " (container " + strconv.Itoa(e.Container) + ")"
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap returns the underlying cause
+func (e *DeserializationError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is a *DeserializationError of the same Kind, so that, e.g.,
+// errors.Is(err, &DeserializationError{Kind: TruncatedInput}) tests the kind of err.
+func (e *DeserializationError) Is(target error) bool {
+	t, ok := target.(*DeserializationError)
+	return ok && t.Kind == e.Kind
+}
+
+// readFailureKind classifies an error returned while reading the input
+func readFailureKind(err error) DeserializationErrorKind {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return TruncatedInput
+	}
+	return ReadFailure
+}
@@ -0,0 +1,241 @@
+package roaring
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// FrozenView creates a static view of a serialized bitmap stored in buf.
+// It uses CRoaring's frozen bitmap format.
+//
+// The format specification is available here:
+// https://github.com/RoaringBitmap/CRoaring/blob/2c867e9f9c9e2a3a7032791f94c4c7ae3013f6e0/src/roaring.c#L2756-L2783
+//
+// The provided byte array (buf) is expected to be a constant.
+// The function makes the best effort attempt not to copy data.
+// A big endian serialized file, or any file on a big endian platform, cannot
+// be used in place: its content is then byte-swapped into memory owned by the
+// bitmap and buf is not referenced after the call.
+// You should take care not to modify buff as it will likely result in
+// unexpected program behavior.
+// If said buffer comes from a memory map, it's advisable to give it read
+// only permissions, either at creation or by calling Mprotect from the
+// golang.org/x/sys/unix package.
+//
+// Resulting bitmaps are effectively immutable in the following sense:
+// a copy-on-write marker is used so that when you modify the resulting
+// bitmap, copies of selected data (containers) are made.
+// You should *not* change the copy-on-write status of the resulting
+// bitmaps (SetCopyOnWrite).
+//
+// If buf becomes unavailable, then a bitmap created with
+// FromBuffer would be effectively broken. Furthermore, any
+// bitmap derived from this bitmap (e.g., via Or, And) might
+// also be broken. Thus, before making buf unavailable, you should
+// call CloneCopyOnWriteContainers on all such bitmaps.
+func (rb *Bitmap) FrozenView(buf []byte) error {
+	return rb.highlowcontainer.frozenView(buf)
+}
+
+func (rb *Bitmap) MustFrozenView(buf []byte) error {
+	if err := rb.FrozenView(buf); err != nil {
+		return err
+	}
+	err := rb.Validate()
+
+	return err
+}
+
+/* Verbatim specification from CRoaring.
+ *
+ * FROZEN SERIALIZATION FORMAT DESCRIPTION
+ *
+ * -- (beginning must be aligned by 32 bytes) --
+ * <bitset_data> uint64_t[BITSET_CONTAINER_SIZE_IN_WORDS * num_bitset_containers]
+ * <run_data>    rle16_t[total number of rle elements in all run containers]
+ * <array_data>  uint16_t[total number of array elements in all array containers]
+ * <keys>        uint16_t[num_containers]
+ * <counts>      uint16_t[num_containers]
+ * <typecodes>   uint8_t[num_containers]
+ * <header>      uint32_t
+ *
+ * <header> is a 4-byte value which is a bit union of frozenCookie (15 bits)
+ * and the number of containers (17 bits).
+ *
+ * <counts> stores number of elements for every container.
+ * Its meaning depends on container type.
+ * For array and bitset containers, this value is the container cardinality minus one.
+ * For run container, it is the number of rle_t elements (n_runs).
+ *
+ * <bitset_data>,<array_data>,<run_data> are flat arrays of elements of
+ * all containers of respective type.
+ *
+ * <*_data> and <keys> are kept close together because they are not accessed
+ * during deserilization. This may reduce IO in case of large mmaped bitmaps.
+ * All members have their native alignments during deserilization except <header>,
+ * which is not guaranteed to be aligned by 4 bytes.
+ */
+const frozenCookie = 13766
+
+var (
+	// ErrFrozenBitmapInvalidCookie is returned when the header does not contain the frozenCookie.
+	ErrFrozenBitmapInvalidCookie = errors.New("header does not contain the frozenCookie")
+	// ErrFrozenBitmapBigEndian is returned when the header is big endian.
+	//
+	// Deprecated: big endian frozen bitmaps are now byte-swapped on load,
+	// FrozenView no longer returns this error.
+	ErrFrozenBitmapBigEndian = errors.New("loading big endian frozen bitmaps is not supported")
+	// ErrFrozenBitmapIncomplete is returned when the buffer is too small to contain a frozen bitmap.
+	ErrFrozenBitmapIncomplete = errors.New("input buffer too small to contain a frozen bitmap")
+	// ErrFrozenBitmapOverpopulated is returned when the number of containers is too large.
+	ErrFrozenBitmapOverpopulated = errors.New("too many containers")
+	// ErrFrozenBitmapUnexpectedData is returned when the buffer contains unexpected data.
+	ErrFrozenBitmapUnexpectedData = errors.New("spurious data in input")
+	// ErrFrozenBitmapInvalidTypecode is returned when the typecode is invalid.
+	ErrFrozenBitmapInvalidTypecode = errors.New("unrecognized typecode")
+	// ErrFrozenBitmapBufferTooSmall is returned when the buffer is too small.
+	ErrFrozenBitmapBufferTooSmall = errors.New("buffer too small")
+)
+
+// GetFrozenSizeInBytes returns the size in bytes of the frozen bitmap.
+func (rb *Bitmap) GetFrozenSizeInBytes() uint64 {
+	rb.highlowcontainer.materialize()
+	nBits, nArrayEl, nRunEl := uint64(0), uint64(0), uint64(0)
+	for _, c := range rb.highlowcontainer.containers {
+		switch v := c.(type) {
+		case *bitmapContainer:
+			nBits++
+		case *arrayContainer:
+			nArrayEl += uint64(len(v.content))
+		case *runContainer16:
+			nRunEl += uint64(len(v.iv))
+		}
+	}
+	return 4 + 5*uint64(len(rb.highlowcontainer.containers)) +
+		(nBits << 13) + 2*nArrayEl + 4*nRunEl
+}
+
+// Freeze serializes the bitmap in the CRoaring's frozen format.
+func (rb *Bitmap) Freeze() ([]byte, error) {
+	sz := rb.GetFrozenSizeInBytes()
+	buf := make([]byte, sz)
+	_, err := rb.FreezeTo(buf)
+	return buf, err
+}
+
+// frozenError reports a problem found at the given offset of a frozen bitmap.
+func frozenError(kind DeserializationErrorKind, offset, container int, err error) error {
+	return &DeserializationError{Kind: kind, Offset: int64(offset), Container: container, Err: err}
+}
+
+// frozenCopy loads a frozen bitmap whose integers are stored with the given byte order.
+// Unlike frozenView, the content of buf is copied so that the resulting bitmap owns its memory.
+func (ra *roaringArray) frozenCopy(buf []byte, order binary.ByteOrder) error {
+	if len(buf) < 4 {
+		return frozenError(TruncatedInput, len(buf), -1, ErrFrozenBitmapIncomplete)
+	}
+	size := len(buf)
+
+	header := order.Uint32(buf[len(buf)-4:])
+	buf = buf[:len(buf)-4]
+
+	if header&0x7fff != frozenCookie {
+		return frozenError(InvalidCookie, size-4, -1, ErrFrozenBitmapInvalidCookie)
+	}
+
+	nCont := int(header >> 15)
+	if nCont > (1 << 16) {
+		return frozenError(InvalidContainerCount, size-4, -1, ErrFrozenBitmapOverpopulated)
+	}
+
+	// 1 byte per type, 2 bytes per key, 2 bytes per count.
+	if len(buf) < 5*nCont {
+		return frozenError(TruncatedInput, size, -1, ErrFrozenBitmapIncomplete)
+	}
+
+	types := buf[len(buf)-nCont:]
+	buf = buf[:len(buf)-nCont]
+
+	countsBuf := buf[len(buf)-2*nCont:]
+	buf = buf[:len(buf)-2*nCont]
+
+	keysBuf := buf[len(buf)-2*nCont:]
+	buf = buf[:len(buf)-2*nCont]
+
+	keys := make([]uint16, nCont)
+	counts := make([]uint16, nCont)
+	for i := range keys {
+		keys[i] = order.Uint16(keysBuf[2*i:])
+		counts[i] = order.Uint16(countsBuf[2*i:])
+	}
+
+	nBitmap, nArrayEl, nRunEl := 0, 0, 0
+	for i, t := range types {
+		switch t {
+		case 1:
+			nBitmap++
+		case 2:
+			nArrayEl += int(counts[i]) + 1
+		case 3:
+			nRunEl += int(counts[i])
+		default:
+			return frozenError(CorruptContainer, size-4-nCont+i, i, ErrFrozenBitmapInvalidTypecode)
+		}
+	}
+
+	if len(buf) < (1<<13)*nBitmap+4*nRunEl+2*nArrayEl {
+		return frozenError(TruncatedInput, size, -1, ErrFrozenBitmapIncomplete)
+	}
+
+	bitsetsArena := make([]uint64, 1024*nBitmap)
+	for i := range bitsetsArena {
+		bitsetsArena[i] = order.Uint64(buf[8*i:])
+	}
+	buf = buf[(1<<13)*nBitmap:]
+
+	runsArena := make([]interval16, nRunEl)
+	for i := range runsArena {
+		runsArena[i].start = order.Uint16(buf[4*i:])
+		runsArena[i].length = order.Uint16(buf[4*i+2:])
+	}
+	buf = buf[4*nRunEl:]
+
+	arraysArena := make([]uint16, nArrayEl)
+	for i := range arraysArena {
+		arraysArena[i] = order.Uint16(buf[2*i:])
+	}
+	buf = buf[2*nArrayEl:]
+
+	if len(buf) != 0 {
+		return frozenError(TrailingData, (1<<13)*nBitmap+4*nRunEl+2*nArrayEl, -1, ErrFrozenBitmapUnexpectedData)
+	}
+
+	// the arenas are owned by the bitmap, but containers are capped so that
+	// growing one of them never overwrites its neighbours
+	containers := make([]container, nCont)
+	for i, t := range types {
+		switch t {
+		case 1:
+			containers[i] = &bitmapContainer{
+				cardinality: int(counts[i]) + 1,
+				bitmap:      bitsetsArena[:1024:1024],
+			}
+			bitsetsArena = bitsetsArena[1024:]
+		case 2:
+			sz := int(counts[i]) + 1
+			containers[i] = &arrayContainer{content: arraysArena[:sz:sz]}
+			arraysArena = arraysArena[sz:]
+		case 3:
+			sz := int(counts[i])
+			containers[i] = &runContainer16{iv: runsArena[:sz:sz]}
+			runsArena = runsArena[sz:]
+		}
+	}
+
+	ra.keys = keys
+	ra.containers = containers
+	ra.needCopyOnWrite = make([]bool, nCont)
+	ra.lazy = nil
+
+	return nil
+}
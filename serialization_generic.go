@@ -143,3 +143,102 @@ func byteSliceAsInterval16Slice(byteSlice []byte) []interval16 {
 
 	return intervalSlice
 }
+
+func (ra *roaringArray) frozenView(buf []byte) error {
+	if len(buf) < 4 {
+		return frozenError(TruncatedInput, len(buf), -1, ErrFrozenBitmapIncomplete)
+	}
+
+	// the data cannot be used in place on this platform, it is always copied
+	if binary.LittleEndian.Uint32(buf[len(buf)-4:])&0x7fff == frozenCookie {
+		return ra.frozenCopy(buf, binary.LittleEndian)
+	}
+	if binary.BigEndian.Uint32(buf[len(buf)-4:])&0x7fff == frozenCookie {
+		return ra.frozenCopy(buf, binary.BigEndian)
+	}
+	return frozenError(InvalidCookie, len(buf)-4, -1, ErrFrozenBitmapInvalidCookie)
+}
+
+// FreezeTo serializes the bitmap in the CRoaring's frozen format.
+func (rb *Bitmap) FreezeTo(buf []byte) (int, error) {
+	rb.highlowcontainer.materialize()
+	containers := rb.highlowcontainer.containers
+	nCont := len(containers)
+
+	nBits, nArrayEl, nRunEl := 0, 0, 0
+	for _, c := range containers {
+		switch v := c.(type) {
+		case *bitmapContainer:
+			nBits++
+		case *arrayContainer:
+			nArrayEl += len(v.content)
+		case *runContainer16:
+			nRunEl += len(v.iv)
+		}
+	}
+
+	serialSize := 4 + 5*nCont + (1<<13)*nBits + 4*nRunEl + 2*nArrayEl
+	if len(buf) < serialSize {
+		return 0, ErrFrozenBitmapBufferTooSmall
+	}
+
+	bitsArena := buf[:(1<<13)*nBits]
+	buf = buf[(1<<13)*nBits:]
+
+	runsArena := buf[:4*nRunEl]
+	buf = buf[4*nRunEl:]
+
+	arraysArena := buf[:2*nArrayEl]
+	buf = buf[2*nArrayEl:]
+
+	keys := buf[:2*nCont]
+	buf = buf[2*nCont:]
+
+	counts := buf[:2*nCont]
+	buf = buf[2*nCont:]
+
+	types := buf[:nCont]
+	buf = buf[nCont:]
+
+	header := uint32(frozenCookie | (nCont << 15))
+	binary.LittleEndian.PutUint32(buf[:4], header)
+
+	for i, c := range containers {
+		binary.LittleEndian.PutUint16(keys[2*i:], rb.highlowcontainer.keys[i])
+		switch v := c.(type) {
+		case *bitmapContainer:
+			for _, w := range v.bitmap {
+				binary.LittleEndian.PutUint64(bitsArena, w)
+				bitsArena = bitsArena[8:]
+			}
+			binary.LittleEndian.PutUint16(counts[2*i:], uint16(v.cardinality-1))
+			types[i] = 1
+		case *arrayContainer:
+			for _, x := range v.content {
+				binary.LittleEndian.PutUint16(arraysArena, x)
+				arraysArena = arraysArena[2:]
+			}
+			binary.LittleEndian.PutUint16(counts[2*i:], uint16(len(v.content)-1))
+			types[i] = 2
+		case *runContainer16:
+			for _, iv := range v.iv {
+				binary.LittleEndian.PutUint16(runsArena, iv.start)
+				binary.LittleEndian.PutUint16(runsArena[2:], iv.length)
+				runsArena = runsArena[4:]
+			}
+			binary.LittleEndian.PutUint16(counts[2*i:], uint16(len(v.iv)))
+			types[i] = 3
+		}
+	}
+
+	return serialSize, nil
+}
+
+// WriteFrozenTo serializes the bitmap in the CRoaring's frozen format.
+func (rb *Bitmap) WriteFrozenTo(wr io.Writer) (int, error) {
+	buf, err := rb.Freeze()
+	if err != nil {
+		return 0, err
+	}
+	return wr.Write(buf)
+}
@@ -268,119 +268,33 @@ func byteSliceAsBoolSlice(slice []byte) (result []bool) {
 	return
 }
 
-// FrozenView creates a static view of a serialized bitmap stored in buf.
-// It uses CRoaring's frozen bitmap format.
-//
-// The format specification is available here:
-// https://github.com/RoaringBitmap/CRoaring/blob/2c867e9f9c9e2a3a7032791f94c4c7ae3013f6e0/src/roaring.c#L2756-L2783
-//
-// The provided byte array (buf) is expected to be a constant.
-// The function makes the best effort attempt not to copy data.
-// Only little endian is supported. The function will err if it detects a big
-// endian serialized file.
-// You should take care not to modify buff as it will likely result in
-// unexpected program behavior.
-// If said buffer comes from a memory map, it's advisable to give it read
-// only permissions, either at creation or by calling Mprotect from the
-// golang.org/x/sys/unix package.
-//
-// Resulting bitmaps are effectively immutable in the following sense:
-// a copy-on-write marker is used so that when you modify the resulting
-// bitmap, copies of selected data (containers) are made.
-// You should *not* change the copy-on-write status of the resulting
-// bitmaps (SetCopyOnWrite).
-//
-// If buf becomes unavailable, then a bitmap created with
-// FromBuffer would be effectively broken. Furthermore, any
-// bitmap derived from this bitmap (e.g., via Or, And) might
-// also be broken. Thus, before making buf unavailable, you should
-// call CloneCopyOnWriteContainers on all such bitmaps.
-func (rb *Bitmap) FrozenView(buf []byte) error {
-	return rb.highlowcontainer.frozenView(buf)
-}
-
-func (rb *Bitmap) MustFrozenView(buf []byte) error {
-	if err := rb.FrozenView(buf); err != nil {
-		return err
-	}
-	err := rb.Validate()
-
-	return err
-}
-
-/* Verbatim specification from CRoaring.
- *
- * FROZEN SERIALIZATION FORMAT DESCRIPTION
- *
- * -- (beginning must be aligned by 32 bytes) --
- * <bitset_data> uint64_t[BITSET_CONTAINER_SIZE_IN_WORDS * num_bitset_containers]
- * <run_data>    rle16_t[total number of rle elements in all run containers]
- * <array_data>  uint16_t[total number of array elements in all array containers]
- * <keys>        uint16_t[num_containers]
- * <counts>      uint16_t[num_containers]
- * <typecodes>   uint8_t[num_containers]
- * <header>      uint32_t
- *
- * <header> is a 4-byte value which is a bit union of frozenCookie (15 bits)
- * and the number of containers (17 bits).
- *
- * <counts> stores number of elements for every container.
- * Its meaning depends on container type.
- * For array and bitset containers, this value is the container cardinality minus one.
- * For run container, it is the number of rle_t elements (n_runs).
- *
- * <bitset_data>,<array_data>,<run_data> are flat arrays of elements of
- * all containers of respective type.
- *
- * <*_data> and <keys> are kept close together because they are not accessed
- * during deserilization. This may reduce IO in case of large mmaped bitmaps.
- * All members have their native alignments during deserilization except <header>,
- * which is not guaranteed to be aligned by 4 bytes.
- */
-const frozenCookie = 13766
-
-var (
-	// ErrFrozenBitmapInvalidCookie is returned when the header does not contain the frozenCookie.
-	ErrFrozenBitmapInvalidCookie = errors.New("header does not contain the frozenCookie")
-	// ErrFrozenBitmapBigEndian is returned when the header is big endian.
-	ErrFrozenBitmapBigEndian = errors.New("loading big endian frozen bitmaps is not supported")
-	// ErrFrozenBitmapIncomplete is returned when the buffer is too small to contain a frozen bitmap.
-	ErrFrozenBitmapIncomplete = errors.New("input buffer too small to contain a frozen bitmap")
-	// ErrFrozenBitmapOverpopulated is returned when the number of containers is too large.
-	ErrFrozenBitmapOverpopulated = errors.New("too many containers")
-	// ErrFrozenBitmapUnexpectedData is returned when the buffer contains unexpected data.
-	ErrFrozenBitmapUnexpectedData = errors.New("spurious data in input")
-	// ErrFrozenBitmapInvalidTypecode is returned when the typecode is invalid.
-	ErrFrozenBitmapInvalidTypecode = errors.New("unrecognized typecode")
-	// ErrFrozenBitmapBufferTooSmall is returned when the buffer is too small.
-	ErrFrozenBitmapBufferTooSmall = errors.New("buffer too small")
-)
-
 func (ra *roaringArray) frozenView(buf []byte) error {
 	if len(buf) < 4 {
-		return ErrFrozenBitmapIncomplete
+		return frozenError(TruncatedInput, len(buf), -1, ErrFrozenBitmapIncomplete)
 	}
+	size := len(buf)
 
 	headerBE := binary.BigEndian.Uint32(buf[len(buf)-4:])
 	if headerBE&0x7fff == frozenCookie {
-		return ErrFrozenBitmapBigEndian
+		// the data cannot be used in place, byte-swap it into owned memory
+		return ra.frozenCopy(buf, binary.BigEndian)
 	}
 
 	header := binary.LittleEndian.Uint32(buf[len(buf)-4:])
 	buf = buf[:len(buf)-4]
 
 	if header&0x7fff != frozenCookie {
-		return ErrFrozenBitmapInvalidCookie
+		return frozenError(InvalidCookie, size-4, -1, ErrFrozenBitmapInvalidCookie)
 	}
 
 	nCont := int(header >> 15)
 	if nCont > (1 << 16) {
-		return ErrFrozenBitmapOverpopulated
+		return frozenError(InvalidContainerCount, size-4, -1, ErrFrozenBitmapOverpopulated)
 	}
 
 	// 1 byte per type, 2 bytes per key, 2 bytes per count.
 	if len(buf) < 5*nCont {
-		return ErrFrozenBitmapIncomplete
+		return frozenError(TruncatedInput, size, -1, ErrFrozenBitmapIncomplete)
 	}
 
 	types := buf[len(buf)-nCont:]
@@ -405,12 +319,12 @@ func (ra *roaringArray) frozenView(buf []byte) error {
 			nRun++
 			nRunEl += int(counts[i])
 		default:
-			return ErrFrozenBitmapInvalidTypecode
+			return frozenError(CorruptContainer, size-4-nCont+i, i, ErrFrozenBitmapInvalidTypecode)
 		}
 	}
 
 	if len(buf) < (1<<13)*nBitmap+4*nRunEl+2*nArrayEl {
-		return ErrFrozenBitmapIncomplete
+		return frozenError(TruncatedInput, size, -1, ErrFrozenBitmapIncomplete)
 	}
 
 	bitsetsArena := byteSliceAsUint64Slice(buf[:(1<<13)*nBitmap])
@@ -423,7 +337,7 @@ func (ra *roaringArray) frozenView(buf []byte) error {
 	buf = buf[2*nArrayEl:]
 
 	if len(buf) != 0 {
-		return ErrFrozenBitmapUnexpectedData
+		return frozenError(TrailingData, (1<<13)*nBitmap+4*nRunEl+2*nArrayEl, -1, ErrFrozenBitmapUnexpectedData)
 	}
 
 	var c container
@@ -486,37 +400,14 @@ func (ra *roaringArray) frozenView(buf []byte) error {
 	ra.containers = containers
 	ra.needCopyOnWrite = needCOW
 	ra.copyOnWrite = true
+	ra.lazy = nil
 
 	return nil
 }
 
-// GetFrozenSizeInBytes returns the size in bytes of the frozen bitmap.
-func (rb *Bitmap) GetFrozenSizeInBytes() uint64 {
-	nBits, nArrayEl, nRunEl := uint64(0), uint64(0), uint64(0)
-	for _, c := range rb.highlowcontainer.containers {
-		switch v := c.(type) {
-		case *bitmapContainer:
-			nBits++
-		case *arrayContainer:
-			nArrayEl += uint64(len(v.content))
-		case *runContainer16:
-			nRunEl += uint64(len(v.iv))
-		}
-	}
-	return 4 + 5*uint64(len(rb.highlowcontainer.containers)) +
-		(nBits << 13) + 2*nArrayEl + 4*nRunEl
-}
-
-// Freeze serializes the bitmap in the CRoaring's frozen format.
-func (rb *Bitmap) Freeze() ([]byte, error) {
-	sz := rb.GetFrozenSizeInBytes()
-	buf := make([]byte, sz)
-	_, err := rb.FreezeTo(buf)
-	return buf, err
-}
-
 // FreezeTo serializes the bitmap in the CRoaring's frozen format.
 func (rb *Bitmap) FreezeTo(buf []byte) (int, error) {
+	rb.highlowcontainer.materialize()
 	containers := rb.highlowcontainer.containers
 	nCont := len(containers)
 
@@ -587,6 +478,7 @@ func (rb *Bitmap) FreezeTo(buf []byte) (int, error) {
 
 // WriteFrozenTo serializes the bitmap in the CRoaring's frozen format.
 func (rb *Bitmap) WriteFrozenTo(wr io.Writer) (int, error) {
+	rb.highlowcontainer.materialize()
 	// FIXME: this is a naive version that iterates 4 times through the
 	// containers and allocates 3*len(containers) bytes; it's quite likely
 	// it can be done more efficiently.
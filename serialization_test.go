@@ -6,7 +6,9 @@ import (
 	"bytes"
 	"encoding/binary"
 	"encoding/gob"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -563,6 +565,187 @@ func TestBitmap_FromBuffer(t *testing.T) {
 	})
 }
 
+func TestReadFromLazy(t *testing.T) {
+	rb := NewBitmap()
+	rb.AddRange(0, 100)                  // run
+	rb.AddMany([]uint32{1 << 16, 70000}) // array
+	for i := uint32(0); i < 10000; i++ {
+		rb.Add(3<<16 + 2*i) // bitmap
+	}
+	rb.AddRange(5<<16, 6<<16) // full run
+	rb.Add(9 << 16)
+	rb.RunOptimize()
+
+	data, err := rb.ToBytes()
+	require.NoError(t, err)
+
+	newLazy := func(t *testing.T) *Bitmap {
+		lazy := NewBitmap()
+		n, err := lazy.ReadFromLazy(bytes.NewReader(data))
+		require.NoError(t, err)
+		assert.EqualValues(t, len(data), n)
+		return lazy
+	}
+	decoded := func(lazy *Bitmap) int {
+		count := 0
+		for _, c := range lazy.highlowcontainer.containers {
+			if c != nil {
+				count++
+			}
+		}
+		return count
+	}
+
+	t.Run("cardinality from header", func(t *testing.T) {
+		lazy := newLazy(t)
+		assert.Equal(t, rb.GetCardinality(), lazy.GetCardinality())
+		assert.True(t, lazy.HasRunCompression())
+		assert.Equal(t, 0, decoded(lazy))
+	})
+
+	t.Run("decode on access", func(t *testing.T) {
+		lazy := newLazy(t)
+		assert.True(t, lazy.Contains(3<<16+2))
+		assert.False(t, lazy.Contains(3<<16+3))
+		assert.False(t, lazy.Contains(7<<16))
+		assert.Equal(t, 1, decoded(lazy))
+
+		assert.EqualValues(t, 0, lazy.Minimum())
+		assert.EqualValues(t, 9<<16, lazy.Maximum())
+		assert.Equal(t, 3, decoded(lazy))
+	})
+
+	t.Run("same as eager", func(t *testing.T) {
+		lazy := newLazy(t)
+		assert.True(t, lazy.Equals(rb))
+		assert.Equal(t, rb.ToArray(), newLazy(t).ToArray())
+		assert.Equal(t, rb.Checksum(), newLazy(t).Checksum())
+		assert.True(t, newLazy(t).Clone().Equals(rb))
+		assert.NoError(t, newLazy(t).Validate())
+
+		other := BitmapOf(5, 70000, 3<<16+4, 5<<16+1, 1<<30)
+		assert.True(t, And(newLazy(t), other).Equals(And(rb, other)))
+		assert.True(t, Or(other, newLazy(t)).Equals(Or(rb, other)))
+		assert.True(t, AndNot(newLazy(t), other).Equals(AndNot(rb, other)))
+		assert.True(t, ParOr(2, newLazy(t), other).Equals(Or(rb, other)))
+
+		serialized, err := newLazy(t).ToBytes()
+		require.NoError(t, err)
+		assert.Equal(t, data, serialized)
+	})
+
+	t.Run("mutations", func(t *testing.T) {
+		lazy := newLazy(t)
+		expected := rb.Clone()
+
+		lazy.Add(2 << 16)
+		expected.Add(2 << 16)
+		lazy.Remove(9 << 16)
+		expected.Remove(9 << 16)
+		lazy.RemoveRange(0, 1<<16)
+		expected.RemoveRange(0, 1<<16)
+		assert.True(t, lazy.Equals(expected))
+		assert.Equal(t, expected.GetCardinality(), lazy.GetCardinality())
+
+		// the array container shares its buffer with the bitmap container which follows it
+		lazy = newLazy(t)
+		lazy.Add(1<<16 + 1)
+		assert.True(t, lazy.Contains(3<<16+2))
+		assert.Equal(t, rb.GetCardinality()+1, lazy.GetCardinality())
+
+		lazy = newLazy(t)
+		lazy.Add(12 << 16) // appended after the lazy containers
+		expected = rb.Clone()
+		expected.Add(12 << 16)
+		assert.True(t, ParAndNot(4, lazy, BitmapOf(5)).Equals(AndNot(expected, BitmapOf(5))))
+
+		lazy = newLazy(t)
+		lazy.And(BitmapOf(5, 3<<16+4))
+		assert.Equal(t, []uint32{5, 3<<16 + 4}, lazy.ToArray())
+
+		lazy = newLazy(t)
+		lazy.Clear()
+		assert.True(t, lazy.IsEmpty())
+		lazy.Add(1)
+		assert.EqualValues(t, 1, lazy.GetCardinality())
+	})
+
+	t.Run("read again eagerly", func(t *testing.T) {
+		lazy := newLazy(t)
+		_, err := lazy.ReadFrom(bytes.NewReader(data))
+		require.NoError(t, err)
+		assert.Equal(t, rb.highlowcontainer.size(), decoded(lazy))
+		assert.True(t, lazy.Equals(rb))
+	})
+
+	t.Run("one read for the containers", func(t *testing.T) {
+		many := NewBitmap()
+		for i := uint32(0); i < 200; i++ {
+			many.Add(i<<16 + i)
+		}
+		many.AddRange(300<<16, 300<<16+10)
+		many.RunOptimize()
+		manyData, err := many.ToBytes()
+		require.NoError(t, err)
+
+		reader := &countingReader{r: bytes.NewReader(manyData)}
+		lazy := NewBitmap()
+		_, err = lazy.ReadFromLazy(reader)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, reader.reads, 8)
+		assert.Equal(t, 0, decoded(lazy))
+		assert.True(t, lazy.Equals(many))
+	})
+
+	t.Run("no offsets", func(t *testing.T) {
+		// bitmaps with runs and less than noOffsetThreshold containers are decoded upfront
+		small := BitmapOf(1, 1<<16)
+		small.AddRange(5<<16, 5<<16+100)
+		small.RunOptimize()
+		smallData, err := small.ToBytes()
+		require.NoError(t, err)
+
+		lazy := NewBitmap()
+		_, err = lazy.ReadFromLazy(bytes.NewReader(smallData))
+		require.NoError(t, err)
+		assert.Equal(t, 3, decoded(lazy))
+		assert.True(t, lazy.Equals(small))
+	})
+
+	t.Run("corrupt offsets", func(t *testing.T) {
+		size := rb.highlowcontainer.size()
+		offsetsAt := 4 + (size+7)/8 + 4*size
+		for _, i := range []int{0, 2, size - 1} {
+			bad := append([]byte(nil), data...)
+			binary.LittleEndian.PutUint32(bad[offsetsAt+4*i:], binary.LittleEndian.Uint32(bad[offsetsAt+4*i:])+2)
+			_, err := NewBitmap().ReadFromLazy(bytes.NewReader(bad))
+			var de *DeserializationError
+			require.True(t, errors.As(err, &de), "offset %d", i)
+			assert.Equal(t, CorruptContainer, de.Kind, "offset %d", i)
+		}
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		for _, l := range []int{len(data) - 1, len(data) / 2, 40} {
+			_, err := NewBitmap().ReadFromLazy(bytes.NewReader(data[:l]))
+			var de *DeserializationError
+			require.True(t, errors.As(err, &de), "length %d", l)
+			assert.Equal(t, TruncatedInput, de.Kind, "length %d", l)
+		}
+	})
+}
+
+// countingReader counts the calls to Read
+type countingReader struct {
+	r     io.Reader
+	reads int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	c.reads++
+	return c.r.Read(p)
+}
+
 func TestSerializationCrashers(t *testing.T) {
 	crashers, err := filepath.Glob("testdata/crash*")
 
@@ -590,6 +773,9 @@ func TestSerializationCrashers(t *testing.T) {
 		_, err = NewBitmap().ReadFrom(bytes.NewReader(data))
 
 		assert.Error(t, err)
+
+		_, err = NewBitmap().ReadFromWithOptions(bytes.NewReader(orig), ReadOptions{})
+		assert.Error(t, err)
 	}
 }
 
@@ -750,3 +936,303 @@ func BenchmarkUnserializeFromBuffer(b *testing.B) {
 		})
 	}
 }
+
+// frozenToBigEndian byte-swaps a little endian frozen bitmap in place
+func frozenToBigEndian(buf []byte) {
+	swap := func(b []byte, width int) {
+		for i := 0; i+width <= len(b); i += width {
+			for j, k := i, i+width-1; j < k; j, k = j+1, k-1 {
+				b[j], b[k] = b[k], b[j]
+			}
+		}
+	}
+
+	nCont := int(binary.LittleEndian.Uint32(buf[len(buf)-4:]) >> 15)
+	typesAt := len(buf) - 4 - nCont
+	countsAt := typesAt - 2*nCont
+	keysAt := countsAt - 2*nCont
+
+	nBitmap, nArrayEl, nRunEl := 0, 0, 0
+	for i, t := range buf[typesAt : typesAt+nCont] {
+		count := int(binary.LittleEndian.Uint16(buf[countsAt+2*i:]))
+		switch t {
+		case 1:
+			nBitmap++
+		case 2:
+			nArrayEl += count + 1
+		case 3:
+			nRunEl += count
+		}
+	}
+
+	swap(buf[:8192*nBitmap], 8)
+	swap(buf[8192*nBitmap:keysAt], 2)
+	swap(buf[keysAt:typesAt], 2)
+	swap(buf[len(buf)-4:], 4)
+	if 8192*nBitmap+4*nRunEl+2*nArrayEl != keysAt {
+		panic("unexpected frozen layout")
+	}
+}
+
+func TestFrozenViewByteOrder(t *testing.T) {
+	rb := New()
+	rb.AddRange(0, 100)
+	rb.AddRange(70000, 70500)
+	for i := uint32(0); i < 10000; i += 2 {
+		rb.Add(3<<16 + i)
+	}
+	rb.AddMany([]uint32{5 << 16, 5<<16 + 3, MaxUint32})
+	rb.RunOptimize()
+
+	t.Run("little endian", func(t *testing.T) {
+		buf, err := rb.Freeze()
+		require.NoError(t, err)
+		assert.EqualValues(t, rb.GetFrozenSizeInBytes(), len(buf))
+
+		var wr bytes.Buffer
+		n, err := rb.WriteFrozenTo(&wr)
+		require.NoError(t, err)
+		assert.Equal(t, len(buf), n)
+		assert.Equal(t, buf, wr.Bytes())
+
+		view := New()
+		require.NoError(t, view.MustFrozenView(buf))
+		assert.True(t, view.Equals(rb))
+	})
+
+	t.Run("big endian", func(t *testing.T) {
+		buf, err := rb.Freeze()
+		require.NoError(t, err)
+		frozenToBigEndian(buf)
+
+		view := New()
+		require.NoError(t, view.MustFrozenView(buf))
+		assert.True(t, view.Equals(rb))
+
+		// the content was copied, buf can be reused
+		for i := range buf {
+			buf[i] = 0xff
+		}
+		assert.True(t, view.Equals(rb))
+
+		// and the containers do not overlap when they grow
+		view.Add(50)
+		view.Add(5<<16 + 1)
+		view.AddRange(200, 300)
+		require.NoError(t, view.Validate())
+		expected := rb.Clone()
+		expected.Add(5<<16 + 1)
+		expected.AddRange(200, 300)
+		assert.True(t, view.Equals(expected))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		buf, err := rb.Freeze()
+		require.NoError(t, err)
+		frozenToBigEndian(buf)
+
+		assert.ErrorIs(t, New().FrozenView(buf[:3]), ErrFrozenBitmapIncomplete)
+		assert.ErrorIs(t, New().FrozenView(buf[1:]), ErrFrozenBitmapIncomplete)
+		assert.ErrorIs(t, New().FrozenView(append([]byte{0}, buf...)), ErrFrozenBitmapUnexpectedData)
+		assert.ErrorIs(t, New().FrozenView([]byte{0, 0, 0, 0}), ErrFrozenBitmapInvalidCookie)
+	})
+}
+
+func TestReadFromWithOptions(t *testing.T) {
+	rb := New()
+	rb.AddRange(1, 100)
+	rb.Add(200)
+	rb.AddRange(1<<16, 1<<16+10)
+	for i := uint32(0); i < 10000; i += 2 {
+		rb.Add(3<<16 + i)
+	}
+	rb.AddMany([]uint32{5 << 16, 5<<16 + 7, MaxUint32})
+	rb.RunOptimize()
+	buf, err := rb.ToBytes()
+	require.NoError(t, err)
+
+	t.Run("valid input", func(t *testing.T) {
+		for _, opts := range []ReadOptions{
+			{},
+			{
+				MaxContainers:     uint64(rb.Stats().Containers),
+				MaxCardinality:    rb.GetCardinality(),
+				MaxAllocatedBytes: rb.GetSizeInBytes(),
+			},
+		} {
+			b := New()
+			n, err := b.ReadFromWithOptions(bytes.NewReader(buf), opts)
+			require.NoError(t, err)
+			assert.EqualValues(t, len(buf), n)
+			assert.True(t, b.Equals(rb))
+		}
+	})
+
+	t.Run("limits", func(t *testing.T) {
+		_, err := New().ReadFromWithOptions(bytes.NewReader(buf), ReadOptions{MaxContainers: uint64(rb.Stats().Containers) - 1})
+		assert.ErrorIs(t, err, ErrContainerLimit)
+		_, err = New().ReadFromWithOptions(bytes.NewReader(buf), ReadOptions{MaxCardinality: rb.GetCardinality() - 1})
+		assert.ErrorIs(t, err, ErrCardinalityLimit)
+		_, err = New().ReadFromWithOptions(bytes.NewReader(buf), ReadOptions{MaxAllocatedBytes: rb.GetSizeInBytes() - 1})
+		assert.ErrorIs(t, err, ErrAllocationLimit)
+	})
+
+	// corrupt serializes a bitmap, changes it and returns the error of ReadFromWithOptions
+	corrupt := func(bm *Bitmap, corruptor func(s []byte)) error {
+		s, err := bm.ToBytes()
+		require.NoError(t, err)
+		corruptor(s)
+		_, err = New().ReadFromWithOptions(bytes.NewReader(s), ReadOptions{})
+		return err
+	}
+
+	t.Run("invalid input", func(t *testing.T) {
+		// no run container: 8 bytes of cookie and size, then 4 bytes of key and cardinality
+		// and 4 bytes of offset per container
+		err := corrupt(BitmapOf(1, 2, 3), func(s []byte) { s[18] = 5 })
+		assert.ErrorIs(t, err, ErrArrayIncorrectSort)
+
+		err = corrupt(BitmapOf(1, 1<<16+1), func(s []byte) { s[12] = 0 })
+		assert.ErrorIs(t, err, ErrKeySortOrder)
+
+		bm := New()
+		for i := uint32(0); i < 10000; i += 2 {
+			bm.Add(i)
+		}
+		err = corrupt(bm, func(s []byte) { s[10]++ })
+		assert.ErrorIs(t, err, ErrCardinalityMismatch)
+
+		// a single run container: 4 bytes of cookie, 1 byte of run flags, 4 bytes of key and
+		// cardinality, then the number of runs and the runs [10, 19], [30, 39]
+		runs := New()
+		runs.AddRange(10, 20)
+		runs.AddRange(30, 40)
+		runs.RunOptimize()
+		assert.NoError(t, corrupt(runs, func(s []byte) {}))
+
+		err = corrupt(runs, func(s []byte) { s[15] = 15 })
+		assert.ErrorIs(t, err, ErrRunIntervalOverlap)
+		err = corrupt(runs, func(s []byte) { s[15] = 5 })
+		assert.ErrorIs(t, err, ErrRunNonSorted)
+		err = corrupt(runs, func(s []byte) { s[17], s[18] = 0xff, 0xff })
+		assert.ErrorIs(t, err, ErrRunIntervalRange)
+		err = corrupt(runs, func(s []byte) { s[17] = 8 })
+		assert.ErrorIs(t, err, ErrCardinalityMismatch)
+	})
+}
+
+func TestDeserializationError(t *testing.T) {
+	// deserializationError returns the *DeserializationError of err
+	deserializationError := func(t *testing.T, err error) *DeserializationError {
+		var de *DeserializationError
+		require.True(t, errors.As(err, &de), "%v", err)
+		assert.True(t, errors.Is(err, &DeserializationError{Kind: de.Kind}))
+		return de
+	}
+
+	buf, err := BitmapOf(1, 2, 3).ToBytes()
+	require.NoError(t, err)
+
+	t.Run("truncated input", func(t *testing.T) {
+		for _, read := range []func(b []byte) error{
+			func(b []byte) error { _, err := New().ReadFrom(bytes.NewReader(b)); return err },
+			func(b []byte) error { _, err := New().FromBuffer(b); return err },
+			func(b []byte) error { _, err := New().FromUnsafeBytes(b); return err },
+		} {
+			de := deserializationError(t, read(buf[:18]))
+			assert.Equal(t, TruncatedInput, de.Kind)
+			assert.Equal(t, 0, de.Container)
+			assert.EqualValues(t, 16, de.Offset)
+
+			de = deserializationError(t, read(buf[:2]))
+			assert.Equal(t, TruncatedInput, de.Kind)
+			assert.Equal(t, -1, de.Container)
+		}
+	})
+
+	t.Run("end of stream", func(t *testing.T) {
+		// a stream ending before the bitmap is not a truncated bitmap
+		_, err := New().ReadFrom(bytes.NewReader(nil))
+		assert.Equal(t, io.EOF, err)
+		_, err = New().ReadFromWithOptions(bytes.NewReader(nil), ReadOptions{})
+		assert.Equal(t, io.EOF, err)
+
+		stream := bytes.NewReader(append(append([]byte(nil), buf...), buf...))
+		for i := 0; i < 2; i++ {
+			_, err = New().ReadFrom(stream)
+			require.NoError(t, err)
+		}
+		_, err = New().ReadFrom(stream)
+		assert.Equal(t, io.EOF, err)
+	})
+
+	t.Run("invalid cookie", func(t *testing.T) {
+		_, err := New().ReadFrom(bytes.NewReader([]byte{1, 2, 3, 4, 5, 6, 7, 8}))
+		de := deserializationError(t, err)
+		assert.Equal(t, InvalidCookie, de.Kind)
+		assert.EqualValues(t, 0, de.Offset)
+		assert.False(t, errors.Is(err, &DeserializationError{Kind: TruncatedInput}))
+	})
+
+	t.Run("unsorted keys", func(t *testing.T) {
+		s, err := BitmapOf(1, 1<<16+1).ToBytes()
+		require.NoError(t, err)
+		s[12] = 0
+		_, err = New().ReadFromWithOptions(bytes.NewReader(s), ReadOptions{})
+		de := deserializationError(t, err)
+		assert.Equal(t, UnsortedKeys, de.Kind)
+		assert.Equal(t, 1, de.Container)
+		assert.EqualValues(t, 12, de.Offset)
+		assert.ErrorIs(t, err, ErrKeySortOrder)
+	})
+
+	t.Run("corrupt run container", func(t *testing.T) {
+		rb := BitmapOf(1)
+		rb.AddRange(1<<16+10, 1<<16+20)
+		rb.AddRange(1<<16+30, 1<<16+40)
+		rb.RunOptimize()
+		s, err := rb.ToBytes()
+		require.NoError(t, err)
+		// 4 bytes of cookie, 1 byte of run flags, 2*4 bytes of keys and cardinalities,
+		// 2 bytes of the array container, then the number of runs and the runs
+		// [1<<16+10, 1<<16+19], [1<<16+30, 1<<16+39] of the run container
+		s[21] = 15
+		_, err = New().ReadFromWithOptions(bytes.NewReader(s), ReadOptions{})
+		de := deserializationError(t, err)
+		assert.Equal(t, CorruptContainer, de.Kind)
+		assert.Equal(t, 1, de.Container)
+		assert.EqualValues(t, 15, de.Offset)
+		assert.ErrorIs(t, err, ErrRunIntervalOverlap)
+	})
+
+	t.Run("limit exceeded", func(t *testing.T) {
+		_, err := New().ReadFromWithOptions(bytes.NewReader(buf), ReadOptions{MaxCardinality: 2})
+		assert.Equal(t, LimitExceeded, deserializationError(t, err).Kind)
+		assert.ErrorIs(t, err, ErrCardinalityLimit)
+	})
+
+	t.Run("frozen", func(t *testing.T) {
+		frozen, err := BitmapOf(1, 2, 3).Freeze()
+		require.NoError(t, err)
+
+		err = New().FrozenView(frozen[:3])
+		assert.Equal(t, TruncatedInput, deserializationError(t, err).Kind)
+		assert.ErrorIs(t, err, ErrFrozenBitmapIncomplete)
+
+		err = New().FrozenView([]byte{0, 0, 0, 0})
+		assert.Equal(t, InvalidCookie, deserializationError(t, err).Kind)
+		assert.ErrorIs(t, err, ErrFrozenBitmapInvalidCookie)
+
+		err = New().FrozenView(append([]byte{0}, frozen...))
+		de := deserializationError(t, err)
+		assert.Equal(t, TrailingData, de.Kind)
+		assert.EqualValues(t, 6, de.Offset)
+
+		bad := append([]byte(nil), frozen...)
+		bad[len(bad)-5] = 7
+		de = deserializationError(t, New().FrozenView(bad))
+		assert.Equal(t, CorruptContainer, de.Kind)
+		assert.Equal(t, 0, de.Container)
+		assert.EqualValues(t, len(bad)-5, de.Offset)
+	})
+}
@@ -0,0 +1,412 @@
+package roaring
+
+// AndIterator returns an IntPeekable over the intersection of the values of the given iterators,
+// in sorted order. When all the iterators are bitmap iterators (see Bitmap.Iterator), the result
+// is computed one container at a time: the keys missing from one of the bitmaps are skipped
+// without decoding their containers, and the containers found in all of them are intersected.
+// Otherwise the result is computed one value at a time, the inputs leapfrogging each other
+// with AdvanceIfNeeded.
+// The input iterators are consumed by the returned iterator.
+func AndIterator(iterators ...IntPeekable) IntPeekable {
+	if cursors, ok := newContainerCursors(iterators); ok && len(cursors) > 0 {
+		return newContainerSetIterator(andContainers, cursors)
+	}
+	ai := &andIterator{iterators: iterators}
+	ai.settle()
+	return ai
+}
+
+// OrIterator returns an IntPeekable over the union of the values of the given iterators,
+// in sorted order and without duplicates. When all the iterators are bitmap iterators, the
+// containers of every key are merged, one key at a time.
+// The input iterators are consumed by the returned iterator.
+func OrIterator(iterators ...IntPeekable) IntPeekable {
+	if cursors, ok := newContainerCursors(iterators); ok && len(cursors) > 0 {
+		return newContainerSetIterator(orContainers, cursors)
+	}
+	// the exhausted iterators are dropped from the slice, which belongs to the caller
+	oi := &orIterator{iterators: append([]IntPeekable(nil), iterators...)}
+	oi.settle()
+	return oi
+}
+
+// AndNotIterator returns an IntPeekable over the values of it which are in none of the excluded
+// iterators, in sorted order. The excluded iterators are only advanced as far as needed.
+// When all the iterators are bitmap iterators, the containers of the excluded bitmaps are
+// subtracted from the containers of it, one key at a time.
+// The input iterators are consumed by the returned iterator.
+func AndNotIterator(it IntPeekable, excluded ...IntPeekable) IntPeekable {
+	if cursors, ok := newContainerCursors(append([]IntPeekable{it}, excluded...)); ok {
+		return newContainerSetIterator(andNotContainers, cursors)
+	}
+	ani := &andNotIterator{it: it, excluded: excluded}
+	ani.settle()
+	return ani
+}
+
+type andIterator struct {
+	iterators []IntPeekable
+	hasNext   bool
+	next      uint32
+}
+
+// settle moves all the iterators to the smallest value they have in common
+func (ai *andIterator) settle() {
+	ai.hasNext = false
+	if len(ai.iterators) == 0 {
+		return
+	}
+	for _, it := range ai.iterators {
+		if !it.HasNext() {
+			return
+		}
+	}
+	candidate := ai.iterators[0].PeekNext()
+	for i := 0; i < len(ai.iterators); {
+		it := ai.iterators[i]
+		it.AdvanceIfNeeded(candidate)
+		if !it.HasNext() {
+			return
+		}
+		if v := it.PeekNext(); v > candidate {
+			// leapfrog: all iterators must now catch up with v
+			candidate = v
+			i = 0
+			continue
+		}
+		i++
+	}
+	ai.hasNext = true
+	ai.next = candidate
+}
+
+// HasNext returns true if there are more integers to iterate over
+func (ai *andIterator) HasNext() bool {
+	return ai.hasNext
+}
+
+// Next returns the next integer
+func (ai *andIterator) Next() uint32 {
+	x := ai.next
+	for _, it := range ai.iterators {
+		it.Next()
+	}
+	ai.settle()
+	return x
+}
+
+// PeekNext peeks the next value without advancing the iterator
+func (ai *andIterator) PeekNext() uint32 {
+	return ai.next
+}
+
+// AdvanceIfNeeded advances as long as the next value is smaller than minval
+func (ai *andIterator) AdvanceIfNeeded(minval uint32) {
+	if !ai.hasNext || ai.next >= minval {
+		return
+	}
+	ai.iterators[0].AdvanceIfNeeded(minval)
+	ai.settle()
+}
+
+type orIterator struct {
+	iterators []IntPeekable
+	hasNext   bool
+	next      uint32
+}
+
+// settle drops the exhausted iterators and finds the smallest next value
+func (oi *orIterator) settle() {
+	live := oi.iterators[:0]
+	oi.hasNext = false
+	for _, it := range oi.iterators {
+		if !it.HasNext() {
+			continue
+		}
+		live = append(live, it)
+		if v := it.PeekNext(); !oi.hasNext || v < oi.next {
+			oi.hasNext = true
+			oi.next = v
+		}
+	}
+	oi.iterators = live
+}
+
+// HasNext returns true if there are more integers to iterate over
+func (oi *orIterator) HasNext() bool {
+	return oi.hasNext
+}
+
+// Next returns the next integer
+func (oi *orIterator) Next() uint32 {
+	x := oi.next
+	for _, it := range oi.iterators {
+		if it.PeekNext() == x {
+			it.Next()
+		}
+	}
+	oi.settle()
+	return x
+}
+
+// PeekNext peeks the next value without advancing the iterator
+func (oi *orIterator) PeekNext() uint32 {
+	return oi.next
+}
+
+// AdvanceIfNeeded advances as long as the next value is smaller than minval
+func (oi *orIterator) AdvanceIfNeeded(minval uint32) {
+	if !oi.hasNext || oi.next >= minval {
+		return
+	}
+	for _, it := range oi.iterators {
+		it.AdvanceIfNeeded(minval)
+	}
+	oi.settle()
+}
+
+type andNotIterator struct {
+	it       IntPeekable
+	excluded []IntPeekable
+}
+
+// settle advances it past the values found in one of the excluded iterators
+func (ani *andNotIterator) settle() {
+	for ani.it.HasNext() {
+		v := ani.it.PeekNext()
+		found := false
+		for _, ex := range ani.excluded {
+			ex.AdvanceIfNeeded(v)
+			if ex.HasNext() && ex.PeekNext() == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return
+		}
+		ani.it.Next()
+	}
+}
+
+// HasNext returns true if there are more integers to iterate over
+func (ani *andNotIterator) HasNext() bool {
+	return ani.it.HasNext()
+}
+
+// Next returns the next integer
+func (ani *andNotIterator) Next() uint32 {
+	x := ani.it.Next()
+	ani.settle()
+	return x
+}
+
+// PeekNext peeks the next value without advancing the iterator
+func (ani *andNotIterator) PeekNext() uint32 {
+	return ani.it.PeekNext()
+}
+
+// AdvanceIfNeeded advances as long as the next value is smaller than minval
+func (ani *andNotIterator) AdvanceIfNeeded(minval uint32) {
+	ani.it.AdvanceIfNeeded(minval)
+	ani.settle()
+}
+
+type containerSetOp int
+
+const (
+	andContainers containerSetOp = iota
+	orContainers
+	andNotContainers // the containers of the first input minus the others
+)
+
+// containerCursor walks over the containers of a bitmap, from where a bitmap iterator stands
+type containerCursor struct {
+	ra  *roaringArray
+	pos int
+	// first holds the values left in the container at pos when the iterator had
+	// already consumed some of them, nil otherwise
+	first container
+}
+
+// newContainerCursors returns a cursor for every iterator, if they are all bitmap iterators
+func newContainerCursors(iterators []IntPeekable) ([]containerCursor, bool) {
+	cursors := make([]containerCursor, len(iterators))
+	for i, it := range iterators {
+		ii, ok := it.(*intIterator)
+		if !ok {
+			return nil, false
+		}
+		cc := &cursors[i]
+		cc.ra, cc.pos = ii.highlowcontainer, ii.pos
+		if ii.HasNext() {
+			c := cc.ra.getContainerAtIndex(cc.pos)
+			if low := ii.iter.peekNext(); c.minimum() < low {
+				cc.first = c.clone().iremoveRange(0, int(low))
+			}
+		}
+	}
+	return cursors, true
+}
+
+func (cc *containerCursor) hasKey() bool {
+	return cc.pos < cc.ra.size()
+}
+
+func (cc *containerCursor) key() uint16 {
+	return cc.ra.getKeyAtIndex(cc.pos)
+}
+
+// container returns the container at the current key, it must not be modified
+func (cc *containerCursor) container() container {
+	if cc.first != nil {
+		return cc.first
+	}
+	return cc.ra.getContainerAtIndex(cc.pos)
+}
+
+// advanceUntil moves to the first key which is at least key, skipped containers are not decoded
+func (cc *containerCursor) advanceUntil(key uint16) {
+	if cc.hasKey() && cc.key() < key {
+		cc.pos = cc.ra.advanceUntil(key, cc.pos)
+		cc.first = nil
+	}
+}
+
+// containerSetIterator combines bitmap iterators one key at a time: the containers of
+// the inputs are combined with the container operations, and the values of the resulting
+// container are then iterated over.
+type containerSetIterator struct {
+	op      containerSetOp
+	cursors []containerCursor
+	hs      uint32
+	iter    shortPeekable // nil once exhausted, has a next value otherwise
+}
+
+func newContainerSetIterator(op containerSetOp, cursors []containerCursor) *containerSetIterator {
+	si := &containerSetIterator{op: op, cursors: cursors}
+	si.load(0)
+	return si
+}
+
+// load moves to the first key, from minkey, where the combined container has values
+func (si *containerSetIterator) load(minkey int) {
+	si.iter = nil
+	for minkey <= MaxUint16 {
+		key, c, ok := si.combine(uint16(minkey))
+		if !ok {
+			return
+		}
+		if !c.isEmpty() {
+			si.hs = uint32(key) << 16
+			si.iter = c.getShortIterator()
+			return
+		}
+		minkey = int(key) + 1
+	}
+}
+
+// combine returns the first key, from minkey, where the result may have values and the
+// combined container of that key. It returns false when there is no such key.
+func (si *containerSetIterator) combine(minkey uint16) (uint16, container, bool) {
+	cursors := si.cursors
+	switch si.op {
+	case andContainers:
+		// leapfrog: every cursor must reach the key of the cursor which is the furthest ahead
+		key := minkey
+		for i := 0; i < len(cursors); {
+			cc := &cursors[i]
+			cc.advanceUntil(key)
+			if !cc.hasKey() {
+				return 0, nil, false
+			}
+			if k := cc.key(); k > key {
+				key = k
+				i = 0
+				continue
+			}
+			i++
+		}
+		c := cursors[0].container()
+		for i := 1; i < len(cursors); i++ {
+			c = c.and(cursors[i].container())
+		}
+		return key, c, true
+	case orContainers:
+		key, found := uint16(0), false
+		for i := range cursors {
+			cc := &cursors[i]
+			cc.advanceUntil(minkey)
+			if cc.hasKey() && (!found || cc.key() < key) {
+				key, found = cc.key(), true
+			}
+		}
+		if !found {
+			return 0, nil, false
+		}
+		var c container
+		for i := range cursors {
+			cc := &cursors[i]
+			if !cc.hasKey() || cc.key() != key {
+				continue
+			}
+			if c == nil {
+				c = cc.container()
+			} else {
+				c = c.or(cc.container())
+			}
+		}
+		return key, c, true
+	default:
+		cc := &cursors[0]
+		cc.advanceUntil(minkey)
+		if !cc.hasKey() {
+			return 0, nil, false
+		}
+		key, c := cc.key(), cc.container()
+		for i := 1; i < len(cursors); i++ {
+			ex := &cursors[i]
+			ex.advanceUntil(key)
+			if ex.hasKey() && ex.key() == key {
+				c = c.andNot(ex.container())
+			}
+		}
+		return key, c, true
+	}
+}
+
+// HasNext returns true if there are more integers to iterate over
+func (si *containerSetIterator) HasNext() bool {
+	return si.iter != nil
+}
+
+// Next returns the next integer
+func (si *containerSetIterator) Next() uint32 {
+	x := si.hs | uint32(si.iter.next())
+	if !si.iter.hasNext() {
+		si.load(int(si.hs>>16) + 1)
+	}
+	return x
+}
+
+// PeekNext peeks the next value without advancing the iterator
+func (si *containerSetIterator) PeekNext() uint32 {
+	return si.hs | uint32(si.iter.peekNext())
+}
+
+// AdvanceIfNeeded advances as long as the next value is smaller than minval
+func (si *containerSetIterator) AdvanceIfNeeded(minval uint32) {
+	if si.iter == nil || si.PeekNext() >= minval {
+		return
+	}
+	if hs := minval & 0xffff0000; hs != si.hs {
+		si.load(int(highbits(minval)))
+		if si.iter == nil || si.hs != hs {
+			return
+		}
+	}
+	si.iter.advanceIfNeeded(lowbits(minval))
+	if !si.iter.hasNext() {
+		si.load(int(si.hs>>16) + 1)
+	}
+}
@@ -0,0 +1,166 @@
+package roaring
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func drainIterator(it IntIterable) []uint32 {
+	var values []uint32
+	for it.HasNext() {
+		values = append(values, it.Next())
+	}
+	return values
+}
+
+// valueIterator hides the bitmap iterator it wraps, so that the set iterators combine its values one by one
+type valueIterator struct {
+	IntPeekable
+}
+
+func TestSetIterators(t *testing.T) {
+	r := rand.New(rand.NewSource(42))
+	bitmaps := make([]*Bitmap, 4)
+	for i := range bitmaps {
+		bitmaps[i] = New()
+		for j := 0; j < 20000; j++ {
+			bitmaps[i].Add(uint32(r.Intn(1 << 20)))
+		}
+		bitmaps[i].AddRange(uint64(i)<<16, uint64(i+2)<<16)
+		bitmaps[i].Add(MaxUint32)
+	}
+	bitmaps[0].RunOptimize()
+	empty := New()
+
+	iterators := func(bms ...*Bitmap) []IntPeekable {
+		its := make([]IntPeekable, len(bms))
+		for i, bm := range bms {
+			its[i] = bm.Iterator()
+		}
+		return its
+	}
+
+	t.Run("and", func(t *testing.T) {
+		assert.Equal(t, FastAnd(bitmaps...).ToArray(), drainIterator(AndIterator(iterators(bitmaps...)...)))
+		assert.Equal(t, And(bitmaps[1], bitmaps[2]).ToArray(), drainIterator(AndIterator(iterators(bitmaps[1], bitmaps[2])...)))
+		assert.Empty(t, drainIterator(AndIterator(iterators(bitmaps[0], empty)...)))
+		assert.False(t, AndIterator().HasNext())
+	})
+
+	t.Run("or", func(t *testing.T) {
+		assert.Equal(t, FastOr(bitmaps...).ToArray(), drainIterator(OrIterator(iterators(bitmaps...)...)))
+		assert.Equal(t, bitmaps[3].ToArray(), drainIterator(OrIterator(iterators(empty, bitmaps[3])...)))
+		assert.False(t, OrIterator().HasNext())
+
+		// the slice of the caller is left untouched
+		its := iterators(empty, bitmaps[3], empty)
+		saved := append([]IntPeekable(nil), its...)
+		drainIterator(OrIterator(its...))
+		assert.Equal(t, saved, its)
+	})
+
+	t.Run("andnot", func(t *testing.T) {
+		expected := AndNot(bitmaps[0], Or(bitmaps[1], bitmaps[2]))
+		assert.Equal(t, expected.ToArray(), drainIterator(AndNotIterator(bitmaps[0].Iterator(), iterators(bitmaps[1], bitmaps[2])...)))
+		assert.Equal(t, bitmaps[0].ToArray(), drainIterator(AndNotIterator(bitmaps[0].Iterator())))
+		assert.Empty(t, drainIterator(AndNotIterator(bitmaps[0].Iterator(), bitmaps[0].Iterator())))
+	})
+
+	t.Run("nested", func(t *testing.T) {
+		// (b0 & b1) | (b2 andnot b3)
+		it := OrIterator(
+			AndIterator(iterators(bitmaps[0], bitmaps[1])...),
+			AndNotIterator(bitmaps[2].Iterator(), bitmaps[3].Iterator()),
+		)
+		expected := Or(And(bitmaps[0], bitmaps[1]), AndNot(bitmaps[2], bitmaps[3]))
+		assert.Equal(t, expected.ToArray(), drainIterator(it))
+	})
+
+	t.Run("peek and advance", func(t *testing.T) {
+		expected := FastAnd(bitmaps...)
+		it := AndIterator(iterators(bitmaps...)...)
+		for _, minval := range []uint32{0, 1000, 1 << 16, 3<<16 + 7, 1 << 19, 1 << 19, MaxUint32} {
+			it.AdvanceIfNeeded(minval)
+			want := expected.Clone()
+			want.RemoveRange(0, uint64(minval))
+			if assert.True(t, it.HasNext()) {
+				assert.Equal(t, want.Minimum(), it.PeekNext())
+			}
+		}
+		assert.Equal(t, uint32(MaxUint32), it.Next())
+		assert.False(t, it.HasNext())
+
+		or := OrIterator(iterators(bitmaps...)...)
+		or.AdvanceIfNeeded(5 << 16)
+		want := FastOr(bitmaps...)
+		want.RemoveRange(0, 5<<16)
+		assert.Equal(t, want.ToArray(), drainIterator(or))
+
+		andNot := AndNotIterator(bitmaps[1].Iterator(), bitmaps[0].Iterator())
+		andNot.AdvanceIfNeeded(1 << 17)
+		want = AndNot(bitmaps[1], bitmaps[0])
+		want.RemoveRange(0, 1<<17)
+		assert.Equal(t, want.ToArray(), drainIterator(andNot))
+	})
+
+	t.Run("containers", func(t *testing.T) {
+		values := func(bms ...*Bitmap) []IntPeekable {
+			its := iterators(bms...)
+			for i, it := range its {
+				its[i] = valueIterator{it}
+			}
+			return its
+		}
+		assert.IsType(t, &containerSetIterator{}, AndIterator(iterators(bitmaps...)...))
+		assert.IsType(t, &containerSetIterator{}, OrIterator(iterators(bitmaps...)...))
+		assert.IsType(t, &containerSetIterator{}, AndNotIterator(bitmaps[0].Iterator(), iterators(bitmaps[1:]...)...))
+		assert.IsType(t, &andIterator{}, AndIterator(append(values(bitmaps[0]), iterators(bitmaps[1:]...)...)...))
+		assert.IsType(t, &orIterator{}, OrIterator(values(bitmaps...)...))
+		assert.IsType(t, &andNotIterator{}, AndNotIterator(bitmaps[0].Iterator(), values(bitmaps[1:]...)...))
+
+		assert.Equal(t, drainIterator(AndIterator(values(bitmaps...)...)), drainIterator(AndIterator(iterators(bitmaps...)...)))
+		assert.Equal(t, drainIterator(OrIterator(values(bitmaps...)...)), drainIterator(OrIterator(iterators(bitmaps...)...)))
+		assert.Equal(t, drainIterator(AndNotIterator(valueIterator{bitmaps[0].Iterator()}, values(bitmaps[1:]...)...)),
+			drainIterator(AndNotIterator(bitmaps[0].Iterator(), iterators(bitmaps[1:]...)...)))
+
+		// iterators which have already consumed part of their container
+		for _, minval := range []uint32{3, 1<<16 + 1000, 3<<16 + 7} {
+			partial := func(its []IntPeekable) []IntPeekable {
+				its[0].AdvanceIfNeeded(minval)
+				its[0].Next()
+				return its
+			}
+			assert.Equal(t, drainIterator(AndIterator(partial(values(bitmaps...))...)),
+				drainIterator(AndIterator(partial(iterators(bitmaps...))...)), "and from %d", minval)
+			assert.Equal(t, drainIterator(OrIterator(partial(values(bitmaps...))...)),
+				drainIterator(OrIterator(partial(iterators(bitmaps...))...)), "or from %d", minval)
+		}
+	})
+
+	t.Run("lazy containers", func(t *testing.T) {
+		many := New()
+		for key := uint32(0); key < 100; key++ {
+			many.Add(key<<16 + key)
+		}
+		buf, err := many.ToBytes()
+		require.NoError(t, err)
+		lazy := New()
+		_, err = lazy.ReadFromLazy(bytes.NewReader(buf))
+		require.NoError(t, err)
+
+		it := AndIterator(lazy.Iterator(), BitmapOf(5<<16+5, 50<<16, 50<<16+50, 200<<16).Iterator())
+		assert.Equal(t, []uint32{5<<16 + 5, 50<<16 + 50}, drainIterator(it))
+		decoded := 0
+		for _, c := range lazy.highlowcontainer.containers {
+			if c != nil {
+				decoded++
+			}
+		}
+		// the first container, decoded by the iterator, and the containers of keys 5 and 50
+		assert.Equal(t, 3, decoded)
+	})
+}
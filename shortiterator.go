@@ -11,6 +11,12 @@ type shortPeekable interface {
 	advanceIfNeeded(minval uint16)
 }
 
+type shortReversePeekable interface {
+	shortIterable
+	peekNext() uint16
+	retreatIfNeeded(maxval uint16)
+}
+
 type shortIterator struct {
 	slice []uint16
 	loc   int
@@ -50,3 +56,25 @@ func (si *reverseIterator) next() uint16 {
 	si.loc--
 	return a
 }
+
+func (si *reverseIterator) peekNext() uint16 {
+	return si.slice[si.loc]
+}
+
+// retreatIfNeeded retreats as long as the next value is larger than maxval
+func (si *reverseIterator) retreatIfNeeded(maxval uint16) {
+	if !si.hasNext() || si.peekNext() <= maxval {
+		return
+	}
+	// find the first value larger than maxval, the iterator stops just before it
+	low, high := 0, si.loc
+	for low < high {
+		mid := int(uint(low+high) >> 1)
+		if si.slice[mid] <= maxval {
+			low = mid + 1
+		} else {
+			high = mid
+		}
+	}
+	si.loc = low - 1
+}
@@ -0,0 +1,278 @@
+package roaring
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+var (
+	// ErrWriterOutOfOrder is returned by a Writer when a value is smaller than a value already added.
+	ErrWriterOutOfOrder = errors.New("values must be added in increasing order")
+	// ErrWriterClosed is returned by a Writer used after Close or Flush.
+	ErrWriterClosed = errors.New("writer was closed")
+	// ErrWriterNoOutput is returned by Flush when the Writer was created with NewWriter.
+	ErrWriterNoOutput = errors.New("writer has no output, use Bitmap")
+)
+
+// Writer builds a bitmap from values added in increasing order. Only the block of 65536
+// values being filled is kept in a mutable container: when a value of the next block is
+// added, the block is closed and converted to its most compact container type
+// (see RunOptimize).
+//
+// A Writer created with NewWriter appends the closed containers to a Bitmap, obtained with
+// Bitmap, without ever searching or inserting in the bitmap. A Writer created with
+// NewStreamWriter produces the portable format (see WriteTo) instead, without building the bitmap.
+type Writer struct {
+	rb *Bitmap
+
+	out     io.Writer
+	scratch io.ReadWriter
+	staged  int64
+	keys    []uint16
+	cards   []uint16
+	sizes   []uint32
+	isRun   []bool
+
+	key     uint16
+	block   container
+	last    uint32
+	started bool
+	closed  bool
+	flushed bool
+	err     error
+}
+
+// NewWriter returns a Writer building a Bitmap.
+func NewWriter() *Writer {
+	return &Writer{rb: NewBitmap()}
+}
+
+// NewStreamWriter returns a Writer serializing the bitmap to out, in the portable format.
+// The format starts with a header describing every container, which is only known once
+// all the values are added: closed containers are therefore staged in scratch (e.g., a
+// temporary file or a bytes.Buffer) and only the header is kept in memory, about 8 bytes
+// per container. Flush writes the header followed by the staged containers to out.
+// If scratch is an io.Seeker, Flush seeks back to where the Writer started writing to it.
+func NewStreamWriter(out io.Writer, scratch io.ReadWriter) *Writer {
+	return &Writer{out: out, scratch: scratch}
+}
+
+// Add adds x to the bitmap. x must not be smaller than the values already added.
+func (wr *Writer) Add(x uint32) error {
+	if err := wr.check(uint64(x)); err != nil {
+		return err
+	}
+	if err := wr.open(highbits(x)); err != nil {
+		return err
+	}
+	wr.block = wr.block.iaddReturnMinimized(lowbits(x))
+	wr.last = x
+	return nil
+}
+
+// AddMany adds all the values of dat, which must be sorted, to the bitmap.
+func (wr *Writer) AddMany(dat []uint32) error {
+	for _, x := range dat {
+		if err := wr.Add(x); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddRange adds the integers in [rangeStart, rangeEnd) to the bitmap. rangeStart must not
+// be smaller than the values already added.
+func (wr *Writer) AddRange(rangeStart, rangeEnd uint64) error {
+	if rangeStart >= rangeEnd {
+		return wr.err
+	}
+	if rangeEnd-1 > MaxUint32 {
+		panic("rangeEnd-1 > MaxUint32")
+	}
+	if err := wr.check(rangeStart); err != nil {
+		return err
+	}
+
+	hbStart := uint32(highbits(uint32(rangeStart)))
+	lbStart := int(lowbits(uint32(rangeStart)))
+	hbLast := uint32(highbits(uint32(rangeEnd - 1)))
+	lbLast := int(lowbits(uint32(rangeEnd - 1)))
+	for hb := hbStart; hb <= hbLast; hb++ {
+		containerStart := 0
+		if hb == hbStart {
+			containerStart = lbStart
+		}
+		containerLast := maxLowBit
+		if hb == hbLast {
+			containerLast = lbLast
+		}
+
+		if err := wr.open(uint16(hb)); err != nil {
+			return err
+		}
+		if wr.block.isEmpty() {
+			wr.block = rangeOfOnes(containerStart, containerLast)
+		} else {
+			wr.block = wr.block.iaddRange(containerStart, containerLast+1)
+		}
+	}
+	wr.last = uint32(rangeEnd - 1)
+	return nil
+}
+
+// Close closes the block being filled, so that no value can be added afterwards.
+// The result is then obtained with Bitmap or Flush, which call Close if needed.
+// Closing a stream Writer writes its last container to the scratch.
+func (wr *Writer) Close() error {
+	if wr.closed {
+		return wr.err
+	}
+	wr.closed = true
+	return wr.close()
+}
+
+// Bitmap closes the Writer and returns the bitmap built by a Writer created with NewWriter.
+// The Writer is then reset and can be used to build another bitmap.
+// Bitmap returns nil for a Writer created with NewStreamWriter.
+func (wr *Writer) Bitmap() *Bitmap {
+	if wr.rb == nil {
+		return nil
+	}
+	wr.Close()
+	rb := wr.rb
+	*wr = *NewWriter()
+	return rb
+}
+
+// Flush closes the Writer and writes the serialized bitmap to the output of a Writer created
+// with NewStreamWriter. It returns the number of bytes written. The Writer must not be used afterwards.
+func (wr *Writer) Flush() (n int64, err error) {
+	if wr.rb != nil {
+		return 0, ErrWriterNoOutput
+	}
+	if wr.flushed {
+		return 0, ErrWriterClosed
+	}
+	if err := wr.Close(); err != nil {
+		return 0, err
+	}
+	wr.flushed = true
+
+	size := len(wr.keys)
+	hasRun := false
+	for _, run := range wr.isRun {
+		hasRun = hasRun || run
+	}
+	isRunSizeInBytes := 0
+	cookieSize := 8
+	if hasRun {
+		cookieSize = 4
+		isRunSizeInBytes = (size + 7) / 8
+	}
+	preambleSize := cookieSize + isRunSizeInBytes + 4*size
+	buf := make([]byte, preambleSize+4*size)
+
+	nw := 0
+	if hasRun {
+		binary.LittleEndian.PutUint16(buf[0:], uint16(serialCookie))
+		binary.LittleEndian.PutUint16(buf[2:], uint16(size-1))
+		nw += 4
+		for i, run := range wr.isRun {
+			if run {
+				buf[nw+i/8] |= 1 << (uint(i) % 8)
+			}
+		}
+		nw += isRunSizeInBytes
+	} else {
+		binary.LittleEndian.PutUint32(buf[0:], uint32(serialCookieNoRunContainer))
+		binary.LittleEndian.PutUint32(buf[4:], uint32(size))
+		nw += 8
+	}
+
+	// descriptive header
+	for i, key := range wr.keys {
+		binary.LittleEndian.PutUint16(buf[nw:], key)
+		binary.LittleEndian.PutUint16(buf[nw+2:], wr.cards[i])
+		nw += 4
+	}
+
+	if !hasRun || size >= noOffsetThreshold {
+		// offset header
+		startOffset := uint32(preambleSize + 4*size)
+		for _, sz := range wr.sizes {
+			binary.LittleEndian.PutUint32(buf[nw:], startOffset)
+			nw += 4
+			startOffset += sz
+		}
+	}
+
+	written, err := wr.out.Write(buf[:nw])
+	n += int64(written)
+	if err != nil {
+		return n, err
+	}
+
+	if seeker, ok := wr.scratch.(io.Seeker); ok {
+		if _, err := seeker.Seek(-wr.staged, io.SeekCurrent); err != nil {
+			return n, err
+		}
+	}
+	copied, err := io.CopyN(wr.out, wr.scratch, wr.staged)
+	n += copied
+	return n, err
+}
+
+// check verifies that the Writer can accept a value or a range starting at x
+func (wr *Writer) check(x uint64) error {
+	if wr.err != nil {
+		return wr.err
+	}
+	if wr.closed {
+		return ErrWriterClosed
+	}
+	if wr.started && x < uint64(wr.last) {
+		return ErrWriterOutOfOrder
+	}
+	return nil
+}
+
+// open makes sure that the current block is the block of the given key
+func (wr *Writer) open(key uint16) error {
+	if wr.started && wr.key == key {
+		return nil
+	}
+	if err := wr.close(); err != nil {
+		return err
+	}
+	wr.key = key
+	wr.block = newArrayContainer()
+	wr.started = true
+	return nil
+}
+
+// close converts the current block to its most compact type and appends it to the result
+func (wr *Writer) close() error {
+	if wr.block == nil {
+		return wr.err
+	}
+	c := wr.block.toEfficientContainer()
+	wr.block = nil
+	if wr.rb != nil {
+		wr.rb.highlowcontainer.appendContainer(wr.key, c, false)
+		return nil
+	}
+
+	written, err := c.writeTo(wr.scratch)
+	wr.staged += int64(written)
+	if err != nil {
+		wr.err = err
+		return err
+	}
+	_, isRun := c.(*runContainer16)
+	wr.keys = append(wr.keys, wr.key)
+	wr.cards = append(wr.cards, uint16(c.getCardinality()-1))
+	wr.sizes = append(wr.sizes, uint32(written))
+	wr.isRun = append(wr.isRun, isRun)
+	return nil
+}
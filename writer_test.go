@@ -0,0 +1,136 @@
+package roaring
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writerInput adds sorted values and ranges to w and to the returned bitmap
+func writerInput(t *testing.T, w *Writer) *Bitmap {
+	r := rand.New(rand.NewSource(1234))
+	expected := New()
+	add := func(x uint32) {
+		require.NoError(t, w.Add(x))
+		expected.Add(x)
+	}
+	addRange := func(start, end uint64) {
+		require.NoError(t, w.AddRange(start, end))
+		expected.AddRange(start, end)
+	}
+
+	// sparse block, duplicate values
+	add(3)
+	add(3)
+	add(1000)
+	// dense block
+	for x := uint32(1 << 16); x < 1<<16+20000; x += 1 + uint32(r.Intn(3)) {
+		add(x)
+	}
+	// runs, spanning several blocks
+	addRange(5<<16+10, 5<<16+100)
+	addRange(5<<16+200, 8<<16+300)
+	add(8<<16 + 300)
+	// single values, a run extended by values and an empty range
+	for x := uint32(100 << 16); x < 200<<16; x += 1 << 16 {
+		add(x)
+	}
+	addRange(300<<16, 300<<16+10)
+	add(300<<16 + 10)
+	add(300<<16 + 12)
+	addRange(300<<16+12, 300<<16+12)
+	add(MaxUint32)
+
+	expected.RunOptimize()
+	return expected
+}
+
+func TestWriter(t *testing.T) {
+	t.Run("bitmap", func(t *testing.T) {
+		w := NewWriter()
+		expected := writerInput(t, w)
+		rb := w.Bitmap()
+		assert.True(t, rb.Equals(expected))
+		assert.Equal(t, expected.Stats(), rb.Stats())
+
+		// the writer is reset
+		require.NoError(t, w.Add(1))
+		assert.Equal(t, []uint32{1}, w.Bitmap().ToArray())
+		assert.True(t, w.Bitmap().IsEmpty())
+		assert.True(t, rb.Equals(expected))
+
+		// the same values again, after an error and Close
+		require.NoError(t, w.AddRange(5, 10))
+		assert.ErrorIs(t, w.Add(1), ErrWriterOutOfOrder)
+		require.NoError(t, w.Close())
+		assert.Equal(t, []uint32{5, 6, 7, 8, 9}, w.Bitmap().ToArray())
+		assert.True(t, w.Bitmap().IsEmpty())
+		second := writerInput(t, w)
+		assert.True(t, w.Bitmap().Equals(second))
+		assert.True(t, rb.Equals(expected))
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		tmp, err := os.Create(filepath.Join(t.TempDir(), "scratch"))
+		require.NoError(t, err)
+		defer tmp.Close()
+		// the scratch of the writer may not start at the beginning of the file
+		_, err = tmp.Write([]byte("header"))
+		require.NoError(t, err)
+
+		for name, scratch := range map[string]io.ReadWriter{"buffer": &bytes.Buffer{}, "file": tmp} {
+			t.Run(name, func(t *testing.T) {
+				var out bytes.Buffer
+				w := NewStreamWriter(&out, scratch)
+				expected := writerInput(t, w)
+				n, err := w.Flush()
+				require.NoError(t, err)
+				assert.EqualValues(t, out.Len(), n)
+
+				buf, err := expected.ToBytes()
+				require.NoError(t, err)
+				assert.Equal(t, buf, out.Bytes())
+				assert.Nil(t, w.Bitmap())
+			})
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		var out bytes.Buffer
+		_, err := NewStreamWriter(&out, &bytes.Buffer{}).Flush()
+		require.NoError(t, err)
+		buf, err := New().ToBytes()
+		require.NoError(t, err)
+		assert.Equal(t, buf, out.Bytes())
+		assert.True(t, NewWriter().Bitmap().IsEmpty())
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		w := NewWriter()
+		require.NoError(t, w.Add(10))
+		assert.ErrorIs(t, w.Add(9), ErrWriterOutOfOrder)
+		assert.ErrorIs(t, w.AddRange(5, 20), ErrWriterOutOfOrder)
+		require.NoError(t, w.AddRange(10, 20))
+		assert.ErrorIs(t, w.Add(18), ErrWriterOutOfOrder)
+		_, err := w.Flush()
+		assert.ErrorIs(t, err, ErrWriterNoOutput)
+		require.NoError(t, w.Close())
+		assert.ErrorIs(t, w.Add(30), ErrWriterClosed)
+		assert.EqualValues(t, 10, w.Bitmap().GetCardinality())
+
+		var out bytes.Buffer
+		w = NewStreamWriter(&out, &bytes.Buffer{})
+		require.NoError(t, w.Add(1))
+		_, err = w.Flush()
+		require.NoError(t, err)
+		assert.ErrorIs(t, w.Add(2), ErrWriterClosed)
+		_, err = w.Flush()
+		assert.ErrorIs(t, err, ErrWriterClosed)
+	})
+}